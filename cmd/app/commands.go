@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/encryption"
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/seed"
+	"github.com/tediscript/gostarterkit/internal/transfer"
+)
+
+const (
+	// defaultMigrationsDir is where migration files are read from and created in
+	defaultMigrationsDir = "./migrations"
+	// defaultSeedsDir is where seed files are read from
+	defaultSeedsDir = "./seeds"
+)
+
+// command is a CLI subcommand of the application binary
+type command struct {
+	Usage string
+	Run   func(args []string) error
+}
+
+// commands returns the available subcommands keyed by name
+func commands() map[string]command {
+	return map[string]command{
+		"backup": {
+			Usage: "backup [-o PATH] [-gzip=BOOL]",
+			Run:   runBackupCommand,
+		},
+		"encryption": {
+			Usage: "encryption add-key [-file PATH] [-id ID] | encryption rotate [-batch N] [table.column]...",
+			Run:   runEncryptionCommand,
+		},
+		"export": {
+			Usage: "export [-o PATH] [-batch N] <model>",
+			Run:   runExportCommand,
+		},
+		"import": {
+			Usage: "import [-f PATH] [-mode insert|upsert] [-dry-run] [-batch N] <model>",
+			Run:   runImportCommand,
+		},
+		"migrate": {
+			Usage: "migrate create <name> | migrate validate",
+			Run:   runMigrateCommand,
+		},
+		"replica": {
+			Usage: "replica list [-dir DIR] | replica restore [-dir DIR] [-to TIME]",
+			Run:   runReplicaCommand,
+		},
+		"restore": {
+			Usage: "restore -from PATH",
+			Run:   runRestoreCommand,
+		},
+		"seed": {
+			Usage: "seed list [-dir DIR] | seed run [-dir DIR] <set>...",
+			Run:   runSeedCommand,
+		},
+	}
+}
+
+// runCommand dispatches args to the matching subcommand and returns the process exit code
+func runCommand(args []string) int {
+	cmds := commands()
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout, cmds)
+		return 0
+	}
+
+	cmd, ok := cmds[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr, cmds)
+		return 2
+	}
+
+	if err := cmd.Run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// printUsage lists the available subcommands
+func printUsage(w io.Writer, cmds map[string]command) {
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Usage: app [command]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Without a command the HTTP server is started.")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", cmds[name].Usage)
+	}
+}
+
+// runMigrateCommand handles the migrate subcommands
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: create or validate")
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := fs.String("dir", defaultMigrationsDir, "migrations directory")
+		timestamp := fs.Bool("timestamp", false, "number the migration by UTC timestamp instead of sequentially")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: migrate create [-dir DIR] [-timestamp] <name>")
+		}
+
+		style := database.SequentialNumbering
+		if *timestamp {
+			style = database.TimestampNumbering
+		}
+
+		paths, err := database.CreateMigration(*dir, fs.Arg(0), style)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			fmt.Printf("Created %s\n", path)
+		}
+		return nil
+
+	case "validate":
+		fs := flag.NewFlagSet("migrate validate", flag.ContinueOnError)
+		dir := fs.String("dir", defaultMigrationsDir, "migrations directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		if err := database.ValidateMigrations(*dir); err != nil {
+			return err
+		}
+		fmt.Printf("Migrations in %s are valid\n", *dir)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subcommand %q: expected create or validate", args[0])
+	}
+}
+
+// loadCommandConfig loads configuration and initializes logging for commands
+// that need them
+func loadCommandConfig() *config.Config {
+	cfg := config.Load(".env")
+	logger.InitWithOptions(cfg.App.LogLevel, cfg.App.LogFormat, logRedactOptions(cfg))
+	return cfg
+}
+
+// requireSQLite fails for commands that work on the SQLite database file
+func requireSQLite(cfg *config.Config, command string) error {
+	if cfg.Database.Driver != "sqlite" {
+		return fmt.Errorf("%s: %w", command, database.ErrUnsupported)
+	}
+	return nil
+}
+
+// databaseName describes the configured database without its credentials
+func databaseName(cfg *config.Config) string {
+	if cfg.Database.Driver == "postgres" {
+		return "the PostgreSQL database"
+	}
+	return cfg.SQLite.DBFile
+}
+
+// runBackupCommand writes a verified snapshot of the database
+func runBackupCommand(args []string) error {
+	cfg := loadCommandConfig()
+
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("o", "", "backup file (default: a timestamped file in BACKUP_DIR)")
+	compress := fs.Bool("gzip", cfg.Backup.Compress, "gzip the backup")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = filepath.Join(cfg.Backup.Dir, database.BackupFileName(time.Now(), *compress))
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	info, err := db.Backup(context.Background(), path, database.BackupOptions{Compress: *compress})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %s to %s (%d bytes)\n", cfg.SQLite.DBFile, info.Path, info.Size)
+	return nil
+}
+
+// runRestoreCommand replaces the database with a backup. The server must be
+// stopped while restoring.
+func runRestoreCommand(args []string) error {
+	cfg := loadCommandConfig()
+	if err := requireSQLite(cfg, "restore"); err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	from := fs.String("from", "", "backup file to restore (.db or .db.gz)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("usage: restore -from PATH")
+	}
+
+	if err := database.Restore(context.Background(), *from, cfg.SQLite.DBFile); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s\n", cfg.SQLite.DBFile, *from)
+	return nil
+}
+
+// runReplicaCommand handles the replica subcommands
+func runReplicaCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: list or restore")
+	}
+
+	cfg := loadCommandConfig()
+	if err := requireSQLite(cfg, "replica"); err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("replica list", flag.ContinueOnError)
+		dir := fs.String("dir", cfg.Replica.Dir, "replica directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *dir == "" {
+			return fmt.Errorf("no replica directory: set REPLICA_DIR or pass -dir")
+		}
+
+		generations, err := database.ListReplicaGenerations(ctx, database.NewFileSink(*dir))
+		if err != nil {
+			return err
+		}
+		for _, g := range generations {
+			fmt.Printf("%s  %s .. %s  %d segments\n", g.ID, g.Start.Format(time.RFC3339), g.End.Format(time.RFC3339), g.Segments)
+		}
+		return nil
+
+	case "restore":
+		fs := flag.NewFlagSet("replica restore", flag.ContinueOnError)
+		dir := fs.String("dir", cfg.Replica.Dir, "replica directory")
+		to := fs.String("to", "", "restore to this RFC 3339 time (default: latest)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *dir == "" {
+			return fmt.Errorf("no replica directory: set REPLICA_DIR or pass -dir")
+		}
+
+		var target time.Time
+		if *to != "" {
+			var err error
+			if target, err = time.Parse(time.RFC3339, *to); err != nil {
+				return fmt.Errorf("invalid -to time: %w", err)
+			}
+		}
+
+		if err := database.RestoreReplica(ctx, database.NewFileSink(*dir), cfg.SQLite.DBFile, target); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s from %s\n", cfg.SQLite.DBFile, *dir)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subcommand %q: expected list or restore", args[0])
+	}
+}
+
+// runSeedCommand handles the seed subcommands
+func runSeedCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: list or run")
+	}
+
+	cfg := loadCommandConfig()
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("seed list", flag.ContinueOnError)
+		dir := fs.String("dir", defaultSeedsDir, "seeds directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		sets, err := seed.NewSeeder(nil, *dir, cfg.App.Env).Sets()
+		if err != nil {
+			return err
+		}
+		for _, name := range sets {
+			fmt.Println(name)
+		}
+		return nil
+
+	case "run":
+		fs := flag.NewFlagSet("seed run", flag.ContinueOnError)
+		dir := fs.String("dir", defaultSeedsDir, "seeds directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: seed run [-dir DIR] <set>...")
+		}
+		if cfg.App.Env == "production" {
+			return seed.ErrProduction
+		}
+
+		db, err := database.New(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		ctx := context.Background()
+		if err := database.NewMigrationRunner(db, defaultMigrationsDir).Migrate(ctx); err != nil {
+			return err
+		}
+		if err := seed.NewSeeder(db, *dir, cfg.App.Env).Run(ctx, fs.Args()...); err != nil {
+			return err
+		}
+		fmt.Printf("Seeded %s into %s\n", strings.Join(fs.Args(), ", "), databaseName(cfg))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subcommand %q: expected list or run", args[0])
+	}
+}
+
+// runEncryptionCommand handles the encryption subcommands
+func runEncryptionCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: add-key or rotate")
+	}
+
+	cfg := loadCommandConfig()
+
+	switch args[0] {
+	case "add-key":
+		fs := flag.NewFlagSet("encryption add-key", flag.ContinueOnError)
+		file := fs.String("file", cfg.Encryption.KeyringFile, "keyring file")
+		id := fs.String("id", "", "key ID (default: the current UTC time)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *file == "" {
+			return fmt.Errorf("no keyring file: set ENCRYPTION_KEYRING_FILE or pass -file")
+		}
+
+		keyID, err := encryption.AddKey(*file, *id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added key %s to %s as the primary key\n", keyID, *file)
+		fmt.Println("Restart the application, then run 'encryption rotate' to re-encrypt existing values")
+		return nil
+
+	case "rotate":
+		fs := flag.NewFlagSet("encryption rotate", flag.ContinueOnError)
+		batch := fs.Int("batch", 500, "rows re-encrypted per transaction")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if cfg.Encryption.KeyringFile == "" {
+			return fmt.Errorf("no keyring file: set ENCRYPTION_KEYRING_FILE")
+		}
+
+		columns := encryption.Columns()
+		if fs.NArg() > 0 {
+			columns = columns[:0]
+			for _, name := range fs.Args() {
+				c, ok := encryption.LookupColumn(name)
+				if !ok {
+					return fmt.Errorf("unknown encrypted column %q", name)
+				}
+				columns = append(columns, c)
+			}
+		}
+		if len(columns) == 0 {
+			fmt.Println("No encrypted columns are registered: call encryption.RegisterColumn for each EncryptedString column")
+			return nil
+		}
+
+		keyring, err := encryption.LoadKeyring(cfg.Encryption.KeyringFile)
+		if err != nil {
+			return err
+		}
+		encryption.SetKeyring(keyring)
+
+		db, err := database.New(cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		ctx := context.Background()
+		for _, c := range columns {
+			result, err := encryption.Rotate(ctx, db, keyring, c, *batch, func(r encryption.RotateResult) {
+				fmt.Printf("%s: scanned %d, re-encrypted %d\n", c, r.Scanned, r.Rotated)
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Rotated %s to key %s (%d of %d values re-encrypted)\n", c, keyring.PrimaryKeyID(), result.Rotated, result.Scanned)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown subcommand %q: expected add-key or rotate", args[0])
+	}
+}
+
+// runExportCommand writes every row of a model as JSON Lines to a file or
+// stdout, reporting progress on stderr
+func runExportCommand(args []string) error {
+	cfg := loadCommandConfig()
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("o", "", "output file (default: stdout)")
+	batch := fs.Int("batch", transfer.DefaultBatchSize, "rows read per query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: export [-o PATH] [-batch N] <model> (models: %s)", strings.Join(transfer.Models(), ", "))
+	}
+	name := fs.Arg(0)
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+
+	n, err := transfer.Export(context.Background(), db, name, buf, *batch, func(n int) {
+		fmt.Fprintf(os.Stderr, "Exported %d %s\n", n, name)
+	})
+	if err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d %s from %s\n", n, name, databaseName(cfg))
+	return nil
+}
+
+// runImportCommand reads JSON Lines rows of a model from a file or stdin and
+// writes them to the database
+func runImportCommand(args []string) error {
+	cfg := loadCommandConfig()
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	in := fs.String("f", "", "input file (default: stdin)")
+	mode := fs.String("mode", string(transfer.ModeInsert), "insert fails on existing rows, upsert overwrites them")
+	dryRun := fs.Bool("dry-run", false, "validate every row and roll back instead of writing")
+	batch := fs.Int("batch", transfer.DefaultBatchSize, "rows written per transaction")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import [-f PATH] [-mode insert|upsert] [-dry-run] [-batch N] <model> (models: %s)", strings.Join(transfer.Models(), ", "))
+	}
+	name := fs.Arg(0)
+
+	r := io.Reader(os.Stdin)
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := database.NewMigrationRunner(db, defaultMigrationsDir).Migrate(ctx); err != nil {
+		return err
+	}
+
+	stats, err := transfer.Import(ctx, db, name, r, transfer.Options{
+		Mode:      transfer.Mode(*mode),
+		BatchSize: *batch,
+		DryRun:    *dryRun,
+		Progress: func(s transfer.Stats) {
+			fmt.Fprintf(os.Stderr, "Read %d lines, %d rows ok, %d invalid\n", s.Lines, s.Written, s.Invalid)
+		},
+	})
+	if stats != nil {
+		for _, e := range stats.Errors {
+			if e.Field != "" {
+				fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", e.Line, e.Field, e.Message)
+			} else {
+				fmt.Fprintf(os.Stderr, "line %d: %s\n", e.Line, e.Message)
+			}
+		}
+	}
+	if err != nil {
+		if stats != nil && stats.Written > 0 {
+			return fmt.Errorf("%w (%d rows were imported before the error)", err, stats.Written)
+		}
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d of %d %s would be imported into %s, %d invalid\n", stats.Written, stats.Lines, name, databaseName(cfg), stats.Invalid)
+		if stats.Invalid > 0 {
+			return fmt.Errorf("%d invalid rows", stats.Invalid)
+		}
+		return nil
+	}
+	fmt.Printf("Imported %d %s into %s\n", stats.Written, name, databaseName(cfg))
+	return nil
+}
@@ -1,40 +1,120 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/tediscript/gostarterkit/internal/auth"
 	"github.com/tediscript/gostarterkit/internal/config"
 	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/encryption"
 	"github.com/tediscript/gostarterkit/internal/handlers"
 	"github.com/tediscript/gostarterkit/internal/health"
 	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/metrics"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+	"github.com/tediscript/gostarterkit/internal/privacy"
 	"github.com/tediscript/gostarterkit/internal/routes"
 	"github.com/tediscript/gostarterkit/internal/server"
 	"github.com/tediscript/gostarterkit/internal/templates"
+	"github.com/tediscript/gostarterkit/internal/tracing"
 )
 
 func main() {
+	// Run a CLI subcommand instead of the server if one was given
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Load configuration
 	cfg := config.Load(".env")
 
+	// Also log to a rotating file if configured
+	logSinks, closeLogFile, err := openLogFile(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeLogFile()
+
 	// Initialize logger
-	log := logger.Init(cfg.App.LogLevel, cfg.App.LogFormat)
+	log := logger.InitWithOptions(cfg.App.LogLevel, cfg.App.LogFormat, logRedactOptions(cfg), logSinks...)
+	// Toggle debug logging with SIGUSR1, without restarting
+	defer logger.ToggleDebugOnSignal()()
 
 	log.Info("Go Starter Kit is starting",
 		"version", "1.0.0",
 		"environment", cfg.App.Env,
 		"log_level", cfg.App.LogLevel,
 		"log_format", cfg.App.LogFormat,
+		"log_file", cfg.LogFile.Path,
 	)
 
+	// Export spans to an OpenTelemetry collector. Without an endpoint spans
+	// are not recorded, but trace IDs are still propagated and logged.
+	if cfg.Tracing.OTLPEndpoint != "" {
+		headers, err := tracing.ParseHeaders(cfg.Tracing.OTLPHeaders)
+		if err != nil {
+			log.Error("Invalid tracing headers",
+				"error", err.Error(),
+			)
+			os.Exit(1)
+		}
+		log.Info("Exporting traces",
+			"endpoint", cfg.Tracing.OTLPEndpoint,
+			"service_name", cfg.Tracing.ServiceName,
+			"sample_ratio", cfg.Tracing.SampleRatio,
+		)
+		exporter := tracing.NewOTLPExporter(cfg.Tracing.OTLPEndpoint, cfg.Tracing.ServiceName, headers)
+		spanProcessor := tracing.NewBatchProcessor(exporter, 5*time.Second, 512, 2048, 10*time.Second)
+		spanProcessor.Start(context.Background())
+		defer spanProcessor.Stop()
+		tracing.SetTracer(tracing.NewTracer(cfg.Tracing.SampleRatio, spanProcessor))
+	} else {
+		tracing.SetTracer(tracing.NewTracer(cfg.Tracing.SampleRatio, nil))
+	}
+
+	// Load the keyring for encrypted columns
+	if cfg.Encryption.KeyringFile != "" {
+		keyring, err := encryption.LoadKeyring(cfg.Encryption.KeyringFile)
+		if err != nil {
+			log.Error("Failed to load encryption keyring",
+				"error", err.Error(),
+			)
+			os.Exit(1)
+		}
+		encryption.SetKeyring(keyring)
+		log.Info("Loaded encryption keyring",
+			"keyring_file", cfg.Encryption.KeyringFile,
+			"primary_key", keyring.PrimaryKeyID(),
+			"keys", len(keyring.KeyIDs()),
+		)
+	}
+
 	// Initialize database
-	log.Info("Initializing database",
-		"db_file", cfg.SQLite.DBFile,
-		"max_open_connections", cfg.SQLite.MaxOpenConnections,
-		"max_idle_connections", cfg.SQLite.MaxIdleConnections,
-	)
+	if cfg.Database.Driver == "postgres" {
+		log.Info("Initializing database",
+			"driver", cfg.Database.Driver,
+			"max_open_connections", cfg.Postgres.MaxOpenConnections,
+			"max_idle_connections", cfg.Postgres.MaxIdleConnections,
+			"slow_query_threshold", cfg.Postgres.SlowQueryThreshold,
+		)
+	} else {
+		log.Info("Initializing database",
+			"driver", cfg.Database.Driver,
+			"db_file", cfg.SQLite.DBFile,
+			"max_open_connections", cfg.SQLite.MaxOpenConnections,
+			"max_idle_connections", cfg.SQLite.MaxIdleConnections,
+			"busy_timeout", cfg.SQLite.BusyTimeout,
+			"synchronous", cfg.SQLite.Synchronous,
+			"slow_query_threshold", cfg.SQLite.SlowQueryThreshold,
+		)
+	}
 	db, err := database.New(cfg)
 	if err != nil {
 		log.Error("Failed to initialize database",
@@ -44,12 +124,26 @@ func main() {
 	}
 	defer db.Close()
 
+	// Report database pool and Go runtime statistics at /metrics
+	if cfg.Metrics.Enabled {
+		metrics.Default.Register(metrics.NewDBStatsCollector("read", db.Stats))
+		// A shared pool would otherwise be reported twice
+		if db.SplitPools() {
+			metrics.Default.Register(metrics.NewDBStatsCollector("write", db.WriterStats))
+		}
+		metrics.Default.Register(metrics.NewRuntimeCollector())
+	}
+
 	// Run migrations
-	migrationsDir := "./migrations"
+	migrationsDir := defaultMigrationsDir
 	log.Info("Running database migrations",
 		"migrations_dir", migrationsDir,
+		"lock_timeout", cfg.Database.MigrationLockTimeout,
 	)
-	if err := database.RunMigrations(db, migrationsDir); err != nil {
+	migrationRunner := database.NewMigrationRunner(db, migrationsDir)
+	migrationRunner.SetLockTimeout(cfg.Database.MigrationLockTimeout)
+	migrationRunner.SetLockLease(cfg.Database.MigrationLockLease)
+	if err := migrationRunner.Migrate(context.Background()); err != nil {
 		log.Error("Failed to run migrations",
 			"error", err.Error(),
 		)
@@ -57,6 +151,72 @@ func main() {
 	}
 	log.Info("Database migrations completed successfully")
 
+	// Start continuous WAL replication
+	if cfg.Replica.Dir != "" {
+		log.Info("Starting WAL replication",
+			"replica_dir", cfg.Replica.Dir,
+			"sync_interval", cfg.Replica.SyncInterval,
+			"snapshot_interval", cfg.Replica.SnapshotInterval,
+			"retention", cfg.Replica.Retention,
+		)
+		replicator := database.NewReplicator(db, database.NewFileSink(cfg.Replica.Dir), cfg.Replica.SyncInterval, cfg.Replica.SnapshotInterval, cfg.Replica.Retention)
+		if err := replicator.Start(context.Background()); err != nil {
+			log.Error("Failed to start WAL replication",
+				"error", err.Error(),
+			)
+			os.Exit(1)
+		}
+		defer replicator.Stop()
+	}
+
+	// Start scheduled backups
+	if cfg.Backup.Interval > 0 {
+		log.Info("Starting scheduled backups",
+			"backup_dir", cfg.Backup.Dir,
+			"interval", cfg.Backup.Interval,
+			"keep_daily", cfg.Backup.KeepDaily,
+			"keep_weekly", cfg.Backup.KeepWeekly,
+		)
+		backupScheduler := database.NewBackupScheduler(db, cfg.Backup.Dir, cfg.Backup.Interval, cfg.Backup.Compress, cfg.Backup.KeepDaily, cfg.Backup.KeepWeekly)
+		backupScheduler.Start(context.Background())
+		defer backupScheduler.Stop()
+	}
+
+	// Start the outbox relay. User events are only logged for now; register
+	// handlers that send notifications or call webhooks here.
+	if cfg.Outbox.PollInterval > 0 {
+		log.Info("Starting outbox relay",
+			"poll_interval", cfg.Outbox.PollInterval,
+			"batch_size", cfg.Outbox.BatchSize,
+			"max_attempts", cfg.Outbox.MaxAttempts,
+			"retention", cfg.Outbox.Retention,
+		)
+		relay := outbox.NewRelay(db, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize, cfg.Outbox.MaxAttempts, cfg.Outbox.Retention)
+		for _, topic := range []string{models.UserCreatedEvent, models.UserUpdatedEvent, models.UserDeletedEvent} {
+			relay.Handle(topic, func(ctx context.Context, event outbox.Event) error {
+				logger.FromContext(ctx).Info("Outbox event published",
+					"event_id", event.ID,
+					"topic", event.Topic,
+				)
+				return nil
+			})
+		}
+		relay.Start(context.Background())
+		defer relay.Stop()
+	}
+
+	// Start carrying out account erasures once their grace period has passed
+	privacyStore := privacy.NewStore(db, cfg.Privacy.ErasureGracePeriod)
+	if cfg.Privacy.ErasureInterval > 0 {
+		log.Info("Starting account erasure worker",
+			"interval", cfg.Privacy.ErasureInterval,
+			"grace_period", cfg.Privacy.ErasureGracePeriod,
+		)
+		erasureWorker := privacy.NewWorker(privacyStore, cfg.Privacy.ErasureInterval)
+		erasureWorker.Start(context.Background())
+		defer erasureWorker.Stop()
+	}
+
 	// Initialize template cache
 	templatesDir := "./templates"
 	templateCache := templates.NewCache(cfg.App.Env == "development")
@@ -88,6 +248,10 @@ func main() {
 
 	// Initialize handlers
 	handlersInstance := handlers.New(templateCache, templatesDir, healthChecker)
+	handlersInstance.Users = models.NewUserRepository(db)
+	handlersInstance.Outbox = outbox.NewStore(db)
+	handlersInstance.Transfer = db
+	handlersInstance.Privacy = privacyStore
 
 	// Get the template for auth routes
 	tpl, err := templateCache.GetTemplate("base.html")
@@ -119,3 +283,40 @@ func main() {
 	srv.WaitForShutdown()
 	log.Info("Application shutdown complete")
 }
+
+// logRedactOptions returns the secrets to redact from logs
+func logRedactOptions(cfg *config.Config) logger.RedactOptions {
+	opts := logger.DefaultRedactOptions()
+	opts.Keys = strings.Split(cfg.App.LogRedactKeys, ",")
+	if cfg.App.LogRedactEmails {
+		opts.Rules = append(opts.Rules, logger.EmailRule)
+	}
+	return opts
+}
+
+// openLogFile opens the rotating log file set by APP_LOG_FILE as a sink,
+// returning no sinks without one, and a func that closes the file
+func openLogFile(cfg *config.Config) ([]logger.Sink, func(), error) {
+	if cfg.LogFile.Path == "" {
+		return nil, func() {}, nil
+	}
+
+	file, err := logger.OpenRotatingFile(cfg.LogFile.Path, logger.RotateOptions{
+		MaxSize:    int64(cfg.LogFile.MaxSizeMB) << 20,
+		Interval:   cfg.LogFile.RotateInterval,
+		Compress:   cfg.LogFile.Compress,
+		MaxBackups: cfg.LogFile.MaxBackups,
+		MaxAge:     cfg.LogFile.MaxAge,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Without its own level the file follows the runtime level
+	level := logger.Level()
+	if cfg.LogFile.Level != "" {
+		level = logger.ParseLogLevel(cfg.LogFile.Level)
+	}
+	sink := logger.NewWriterSink(file, cfg.LogFile.Format, level)
+	return []logger.Sink{sink}, func() { file.Close() }, nil
+}
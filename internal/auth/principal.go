@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// principalKey is the context key for the authenticated principal
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the ID of the authenticated
+// principal, e.g. the username from a session or JWT
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal set by
+// WithPrincipal, if any
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok && principal != ""
+}
+
+// IsAdmin reports whether principal is listed in AUTH_ADMIN_USERS
+func IsAdmin(principal string) bool {
+	if cfg == nil || principal == "" {
+		return false
+	}
+	for _, admin := range strings.Split(cfg.Auth.AdminUsers, ",") {
+		if strings.TrimSpace(admin) == principal {
+			return true
+		}
+	}
+	return false
+}
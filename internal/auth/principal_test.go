@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrincipalContext(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		ctx := WithPrincipal(context.Background(), "ada")
+		principal, ok := PrincipalFromContext(ctx)
+		if !ok || principal != "ada" {
+			t.Errorf("Expected principal ada, got %q (%v)", principal, ok)
+		}
+	})
+
+	t.Run("missing or empty", func(t *testing.T) {
+		if _, ok := PrincipalFromContext(context.Background()); ok {
+			t.Error("Expected no principal in an empty context")
+		}
+		if _, ok := PrincipalFromContext(WithPrincipal(context.Background(), "")); ok {
+			t.Error("Expected an empty principal to be ignored")
+		}
+	})
+
+	t.Run("set by RequireAuth", func(t *testing.T) {
+		Initialize(setupTestConfig())
+
+		login := httptest.NewRecorder()
+		if err := SetUserSession(login, httptest.NewRequest(http.MethodGet, "/", nil), "ada"); err != nil {
+			t.Fatalf("Failed to set session: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+		for _, c := range login.Result().Cookies() {
+			req.AddCookie(c)
+		}
+
+		var principal string
+		handler := RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = PrincipalFromContext(r.Context())
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if principal != "ada" {
+			t.Errorf("Expected principal ada, got %q", principal)
+		}
+	})
+}
+
+func TestIsAdmin(t *testing.T) {
+	cfg := setupTestConfig()
+	cfg.Auth.AdminUsers = "root, ada"
+	SetConfigForTesting(cfg)
+	defer ResetConfigForTesting()
+
+	tests := map[string]bool{"ada": true, "root": true, "alan": false, "": false, "root, ada": false}
+	for principal, want := range tests {
+		if got := IsAdmin(principal); got != want {
+			t.Errorf("IsAdmin(%q) = %v, want %v", principal, got, want)
+		}
+	}
+}
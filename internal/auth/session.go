@@ -116,12 +116,12 @@ func ClearSession(w http.ResponseWriter, r *http.Request) error {
 // RequireAuth is middleware that ensures the user is authenticated
 func RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authenticated, _ := IsAuthenticated(r)
+		authenticated, userID := IsAuthenticated(r)
 		if !authenticated {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), userID)))
 	})
 }
 
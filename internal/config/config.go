@@ -3,6 +3,7 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -20,12 +21,66 @@ type Config struct {
 		IdleTimeout     time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"60s"`
 	}
 
+	// Database Driver Configuration
+	Database struct {
+		Driver string `env:"DB_DRIVER" default:"sqlite"`
+		// MigrationLockTimeout and MigrationLockLease apply to both drivers
+		MigrationLockTimeout time.Duration `env:"DB_MIGRATION_LOCK_TIMEOUT" default:"60s"`
+		MigrationLockLease   time.Duration `env:"DB_MIGRATION_LOCK_LEASE" default:"30s"`
+	}
+
 	// Database Configuration
 	SQLite struct {
-		DBFile                       string `env:"SQLITE_DB_FILE" default:"./app.db"`
-		MaxOpenConnections           int    `env:"SQLITE_MAX_OPEN_CONNECTIONS" default:"25"`
-		MaxIdleConnections           int    `env:"SQLITE_MAX_IDLE_CONNECTIONS" default:"25"`
-		ConnectionMaxLifetimeSeconds int    `env:"SQLITE_CONNECTION_MAX_LIFETIME_SECONDS" default:"300"`
+		DBFile                       string        `env:"SQLITE_DB_FILE" default:"./app.db"`
+		MaxOpenConnections           int           `env:"SQLITE_MAX_OPEN_CONNECTIONS" default:"25"`
+		MaxIdleConnections           int           `env:"SQLITE_MAX_IDLE_CONNECTIONS" default:"25"`
+		ConnectionMaxLifetimeSeconds int           `env:"SQLITE_CONNECTION_MAX_LIFETIME_SECONDS" default:"300"`
+		BusyTimeout                  time.Duration `env:"SQLITE_BUSY_TIMEOUT" default:"5s"`
+		Synchronous                  string        `env:"SQLITE_SYNCHRONOUS" default:"NORMAL"`
+		CacheSize                    int           `env:"SQLITE_CACHE_SIZE" default:"-2000"`
+		MmapSize                     int64         `env:"SQLITE_MMAP_SIZE" default:"0"`
+		SlowQueryThreshold           time.Duration `env:"SQLITE_SLOW_QUERY_THRESHOLD" default:"200ms"`
+	}
+
+	// PostgreSQL Configuration
+	Postgres struct {
+		URL                          string        `env:"POSTGRES_URL"`
+		MaxOpenConnections           int           `env:"POSTGRES_MAX_OPEN_CONNECTIONS" default:"25"`
+		MaxIdleConnections           int           `env:"POSTGRES_MAX_IDLE_CONNECTIONS" default:"5"`
+		ConnectionMaxLifetimeSeconds int           `env:"POSTGRES_CONNECTION_MAX_LIFETIME_SECONDS" default:"300"`
+		SlowQueryThreshold           time.Duration `env:"POSTGRES_SLOW_QUERY_THRESHOLD" default:"200ms"`
+	}
+
+	// Backup Configuration
+	Backup struct {
+		Dir        string        `env:"BACKUP_DIR" default:"./backups"`
+		Interval   time.Duration `env:"BACKUP_INTERVAL" default:"0s"`
+		Compress   bool          `env:"BACKUP_COMPRESS" default:"true"`
+		KeepDaily  int           `env:"BACKUP_KEEP_DAILY" default:"7"`
+		KeepWeekly int           `env:"BACKUP_KEEP_WEEKLY" default:"4"`
+	}
+
+	// WAL Replication Configuration
+	Replica struct {
+		Dir              string        `env:"REPLICA_DIR"`
+		SyncInterval     time.Duration `env:"REPLICA_SYNC_INTERVAL" default:"1s"`
+		SnapshotInterval time.Duration `env:"REPLICA_SNAPSHOT_INTERVAL" default:"24h"`
+		Retention        time.Duration `env:"REPLICA_RETENTION" default:"72h"`
+	}
+
+	// Outbox Relay Configuration
+	Outbox struct {
+		PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" default:"1s"`
+		BatchSize    int           `env:"OUTBOX_BATCH_SIZE" default:"100"`
+		MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" default:"10"`
+		Retention    time.Duration `env:"OUTBOX_RETENTION" default:"168h"`
+	}
+
+	// Personal Data Configuration
+	Privacy struct {
+		// ErasureGracePeriod is how long an account erasure can be cancelled
+		ErasureGracePeriod time.Duration `env:"PRIVACY_ERASURE_GRACE_PERIOD" default:"720h"`
+		ErasureInterval    time.Duration `env:"PRIVACY_ERASURE_INTERVAL" default:"1h"`
 	}
 
 	// JWT Authentication Configuration
@@ -35,6 +90,18 @@ type Config struct {
 		ExpirationSeconds int    `env:"JWT_EXPIRATION_SECONDS" default:"3600"`
 	}
 
+	// Encryption Configuration
+	Encryption struct {
+		// KeyringFile is a JSON keyring for encrypting columns at rest
+		KeyringFile string `env:"ENCRYPTION_KEYRING_FILE"`
+	}
+
+	// Authorization Configuration
+	Auth struct {
+		// AdminUsers is a comma-separated list of principals with admin rights
+		AdminUsers string `env:"AUTH_ADMIN_USERS"`
+	}
+
 	// Session Authentication Configuration
 	Session struct {
 		CookieSecret   string `env:"SESSION_COOKIE_SECRET"`
@@ -50,7 +117,27 @@ type Config struct {
 		Env       string `env:"APP_ENV" default:"development"`
 		LogLevel  string `env:"APP_LOG_LEVEL" default:"info"`
 		LogFormat string `env:"APP_LOG_FORMAT" default:"json"`
-		Name      string `env:"APP_NAME" default:"Go Starter Kit"`
+		// LogRedactKeys is a comma-separated list of attribute keys whose
+		// values are redacted from logs
+		LogRedactKeys string `env:"APP_LOG_REDACT_KEYS" default:"password,passwd,secret,token,authorization,cookie,apikey"`
+		// LogRedactEmails redacts email addresses from logged strings
+		LogRedactEmails bool   `env:"APP_LOG_REDACT_EMAILS" default:"false"`
+		Name            string `env:"APP_NAME" default:"Go Starter Kit"`
+	}
+
+	// Log File Configuration
+	LogFile struct {
+		// Path is the file logs are also written to; empty disables it
+		Path   string `env:"APP_LOG_FILE" default:""`
+		Format string `env:"APP_LOG_FILE_FORMAT" default:"json"`
+		// Level is the file's minimum level; empty follows APP_LOG_LEVEL and
+		// runtime level changes
+		Level          string        `env:"APP_LOG_FILE_LEVEL" default:""`
+		MaxSizeMB      int           `env:"APP_LOG_FILE_MAX_SIZE_MB" default:"100"`
+		RotateInterval time.Duration `env:"APP_LOG_FILE_ROTATE_INTERVAL" default:"24h"`
+		MaxBackups     int           `env:"APP_LOG_FILE_MAX_BACKUPS" default:"7"`
+		MaxAge         time.Duration `env:"APP_LOG_FILE_MAX_AGE" default:"0"`
+		Compress       bool          `env:"APP_LOG_FILE_COMPRESS" default:"true"`
 	}
 
 	// Rate Limiting Configuration
@@ -59,6 +146,27 @@ type Config struct {
 		WindowSeconds     int `env:"RATE_LIMIT_WINDOW_SECONDS" default:"60"`
 	}
 
+	// Metrics Configuration
+	Metrics struct {
+		Enabled bool `env:"METRICS_ENABLED" default:"true"`
+		// Token, if set, must be sent as a bearer token to scrape /metrics
+		Token     string `env:"METRICS_TOKEN"`
+		TokenFile string `env:"METRICS_TOKEN_FILE"`
+	}
+
+	// Tracing Configuration
+	Tracing struct {
+		// OTLPEndpoint is the OTLP/HTTP traces URL spans are exported to;
+		// empty disables export
+		OTLPEndpoint string `env:"TRACING_OTLP_ENDPOINT"`
+		// OTLPHeaders is a comma-separated list of name=value headers sent
+		// with every export, e.g. for collector authentication
+		OTLPHeaders     string  `env:"TRACING_OTLP_HEADERS"`
+		OTLPHeadersFile string  `env:"TRACING_OTLP_HEADERS_FILE"`
+		ServiceName     string  `env:"TRACING_SERVICE_NAME" default:"gostarterkit"`
+		SampleRatio     float64 `env:"TRACING_SAMPLE_RATIO" default:"1"`
+	}
+
 	// CORS Configuration
 	CORS struct {
 		AllowedOrigins string `env:"CORS_ALLOWED_ORIGINS" default:"*"`
@@ -100,16 +208,62 @@ func loadConfig(cfg *Config) {
 	cfg.HTTP.WriteTimeout = getEnvDuration("HTTP_WRITE_TIMEOUT", 15*time.Second)
 	cfg.HTTP.IdleTimeout = getEnvDuration("HTTP_IDLE_TIMEOUT", 60*time.Second)
 
+	// Database Driver Configuration
+	cfg.Database.Driver = getEnvString("DB_DRIVER", "sqlite")
+	cfg.Database.MigrationLockTimeout = getEnvDuration("DB_MIGRATION_LOCK_TIMEOUT", 60*time.Second)
+	cfg.Database.MigrationLockLease = getEnvDuration("DB_MIGRATION_LOCK_LEASE", 30*time.Second)
+
 	// SQLite Configuration
 	cfg.SQLite.DBFile = getEnvString("SQLITE_DB_FILE", "./app.db")
 	cfg.SQLite.MaxOpenConnections = getEnvInt("SQLITE_MAX_OPEN_CONNECTIONS", 25)
 	cfg.SQLite.MaxIdleConnections = getEnvInt("SQLITE_MAX_IDLE_CONNECTIONS", 25)
 	cfg.SQLite.ConnectionMaxLifetimeSeconds = getEnvInt("SQLITE_CONNECTION_MAX_LIFETIME_SECONDS", 300)
+	cfg.SQLite.BusyTimeout = getEnvDuration("SQLITE_BUSY_TIMEOUT", 5*time.Second)
+	cfg.SQLite.Synchronous = getEnvString("SQLITE_SYNCHRONOUS", "NORMAL")
+	cfg.SQLite.CacheSize = getEnvInt("SQLITE_CACHE_SIZE", -2000)
+	cfg.SQLite.MmapSize = int64(getEnvInt("SQLITE_MMAP_SIZE", 0))
+	cfg.SQLite.SlowQueryThreshold = getEnvDuration("SQLITE_SLOW_QUERY_THRESHOLD", 200*time.Millisecond)
+
+	// PostgreSQL Configuration
+	cfg.Postgres.URL = getEnvString("POSTGRES_URL", "")
+	cfg.Postgres.MaxOpenConnections = getEnvInt("POSTGRES_MAX_OPEN_CONNECTIONS", 25)
+	cfg.Postgres.MaxIdleConnections = getEnvInt("POSTGRES_MAX_IDLE_CONNECTIONS", 5)
+	cfg.Postgres.ConnectionMaxLifetimeSeconds = getEnvInt("POSTGRES_CONNECTION_MAX_LIFETIME_SECONDS", 300)
+	cfg.Postgres.SlowQueryThreshold = getEnvDuration("POSTGRES_SLOW_QUERY_THRESHOLD", 200*time.Millisecond)
+
+	// Backup Configuration
+	cfg.Backup.Dir = getEnvString("BACKUP_DIR", "./backups")
+	cfg.Backup.Interval = getEnvDuration("BACKUP_INTERVAL", 0)
+	cfg.Backup.Compress = getEnvBool("BACKUP_COMPRESS", true)
+	cfg.Backup.KeepDaily = getEnvInt("BACKUP_KEEP_DAILY", 7)
+	cfg.Backup.KeepWeekly = getEnvInt("BACKUP_KEEP_WEEKLY", 4)
+
+	// WAL Replication Configuration
+	cfg.Replica.Dir = getEnvString("REPLICA_DIR", "")
+	cfg.Replica.SyncInterval = getEnvDuration("REPLICA_SYNC_INTERVAL", time.Second)
+	cfg.Replica.SnapshotInterval = getEnvDuration("REPLICA_SNAPSHOT_INTERVAL", 24*time.Hour)
+	cfg.Replica.Retention = getEnvDuration("REPLICA_RETENTION", 72*time.Hour)
+
+	// Outbox Relay Configuration
+	cfg.Outbox.PollInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	cfg.Outbox.BatchSize = getEnvInt("OUTBOX_BATCH_SIZE", 100)
+	cfg.Outbox.MaxAttempts = getEnvInt("OUTBOX_MAX_ATTEMPTS", 10)
+	cfg.Outbox.Retention = getEnvDuration("OUTBOX_RETENTION", 168*time.Hour)
+
+	// Personal Data Configuration
+	cfg.Privacy.ErasureGracePeriod = getEnvDuration("PRIVACY_ERASURE_GRACE_PERIOD", 720*time.Hour)
+	cfg.Privacy.ErasureInterval = getEnvDuration("PRIVACY_ERASURE_INTERVAL", time.Hour)
 
 	// JWT Configuration
 	cfg.JWT.SigningSecret = getEnvOrFile("JWT_SIGNING_SECRET", "JWT_SIGNING_SECRET_FILE")
 	cfg.JWT.ExpirationSeconds = getEnvInt("JWT_EXPIRATION_SECONDS", 3600)
 
+	// Encryption Configuration
+	cfg.Encryption.KeyringFile = getEnvString("ENCRYPTION_KEYRING_FILE", "")
+
+	// Authorization Configuration
+	cfg.Auth.AdminUsers = getEnvString("AUTH_ADMIN_USERS", "")
+
 	// Session Configuration
 	cfg.Session.CookieSecret = getEnvString("SESSION_COOKIE_SECRET", "")
 	cfg.Session.CookieName = getEnvString("SESSION_COOKIE_NAME", "session")
@@ -122,12 +276,34 @@ func loadConfig(cfg *Config) {
 	cfg.App.Env = getEnvString("APP_ENV", "development")
 	cfg.App.LogLevel = getEnvString("APP_LOG_LEVEL", "info")
 	cfg.App.LogFormat = getEnvString("APP_LOG_FORMAT", cfg.getAppDefaultLogFormat())
+	cfg.App.LogRedactKeys = getEnvString("APP_LOG_REDACT_KEYS", "password,passwd,secret,token,authorization,cookie,apikey")
+	cfg.App.LogRedactEmails = getEnvBool("APP_LOG_REDACT_EMAILS", false)
 	cfg.App.Name = getEnvString("APP_NAME", "Go Starter Kit")
 
+	// Log File Configuration
+	cfg.LogFile.Path = getEnvString("APP_LOG_FILE", "")
+	cfg.LogFile.Format = getEnvString("APP_LOG_FILE_FORMAT", "json")
+	cfg.LogFile.Level = getEnvString("APP_LOG_FILE_LEVEL", "")
+	cfg.LogFile.MaxSizeMB = getEnvInt("APP_LOG_FILE_MAX_SIZE_MB", 100)
+	cfg.LogFile.RotateInterval = getEnvDuration("APP_LOG_FILE_ROTATE_INTERVAL", 24*time.Hour)
+	cfg.LogFile.MaxBackups = getEnvInt("APP_LOG_FILE_MAX_BACKUPS", 7)
+	cfg.LogFile.MaxAge = getEnvDuration("APP_LOG_FILE_MAX_AGE", 0)
+	cfg.LogFile.Compress = getEnvBool("APP_LOG_FILE_COMPRESS", true)
+
 	// Rate Limiting Configuration
 	cfg.RateLimit.RequestsPerWindow = getEnvInt("RATE_LIMIT_REQUESTS_PER_WINDOW", 100)
 	cfg.RateLimit.WindowSeconds = getEnvInt("RATE_LIMIT_WINDOW_SECONDS", 60)
 
+	// Metrics Configuration
+	cfg.Metrics.Enabled = getEnvBool("METRICS_ENABLED", true)
+	cfg.Metrics.Token = getEnvOrFile("METRICS_TOKEN", "METRICS_TOKEN_FILE")
+
+	// Tracing Configuration
+	cfg.Tracing.OTLPEndpoint = getEnvString("TRACING_OTLP_ENDPOINT", "")
+	cfg.Tracing.OTLPHeaders = getEnvOrFile("TRACING_OTLP_HEADERS", "TRACING_OTLP_HEADERS_FILE")
+	cfg.Tracing.ServiceName = getEnvString("TRACING_SERVICE_NAME", "gostarterkit")
+	cfg.Tracing.SampleRatio = getEnvFloat("TRACING_SAMPLE_RATIO", 1)
+
 	// CORS Configuration
 	cfg.CORS.AllowedOrigins = getEnvString("CORS_ALLOWED_ORIGINS", "*")
 	cfg.CORS.AllowedMethods = getEnvString("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")
@@ -176,12 +352,37 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("APP_LOG_FORMAT must be 'json' or 'text', got: %s", c.App.LogFormat)
 	}
 
+	// Validate Log File
+	if c.LogFile.Format != "json" && c.LogFile.Format != "text" {
+		return fmt.Errorf("APP_LOG_FILE_FORMAT must be 'json' or 'text', got: %s", c.LogFile.Format)
+	}
+	if c.LogFile.Level != "" && !validLogLevels[c.LogFile.Level] {
+		return fmt.Errorf("APP_LOG_FILE_LEVEL must be empty, 'debug', 'info', 'warn', or 'error', got: %s", c.LogFile.Level)
+	}
+	if c.LogFile.MaxSizeMB < 0 {
+		return fmt.Errorf("APP_LOG_FILE_MAX_SIZE_MB must not be negative, got: %d", c.LogFile.MaxSizeMB)
+	}
+	if c.LogFile.RotateInterval < 0 {
+		return fmt.Errorf("APP_LOG_FILE_ROTATE_INTERVAL must not be negative, got: %s", c.LogFile.RotateInterval)
+	}
+	if c.LogFile.MaxBackups < 0 {
+		return fmt.Errorf("APP_LOG_FILE_MAX_BACKUPS must not be negative, got: %d", c.LogFile.MaxBackups)
+	}
+	if c.LogFile.MaxAge < 0 {
+		return fmt.Errorf("APP_LOG_FILE_MAX_AGE must not be negative, got: %s", c.LogFile.MaxAge)
+	}
+
 	// Validate Cookie SameSite
 	validSameSiteValues := map[string]bool{"Strict": true, "Lax": true, "None": true}
 	if !validSameSiteValues[c.Session.CookieSameSite] {
 		return fmt.Errorf("SESSION_COOKIE_SAMESITE must be 'Strict', 'Lax', or 'None', got: %s", c.Session.CookieSameSite)
 	}
 
+	// Validate Database Driver
+	if c.Database.Driver != "sqlite" && c.Database.Driver != "postgres" {
+		return fmt.Errorf("DB_DRIVER must be 'sqlite' or 'postgres', got: %s", c.Database.Driver)
+	}
+
 	// Validate SQLite Max Open Connections
 	if c.SQLite.MaxOpenConnections <= 0 {
 		return fmt.Errorf("SQLITE_MAX_OPEN_CONNECTIONS must be positive, got: %d", c.SQLite.MaxOpenConnections)
@@ -192,6 +393,116 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("SQLITE_MAX_IDLE_CONNECTIONS must be non-negative, got: %d", c.SQLite.MaxIdleConnections)
 	}
 
+	// Validate SQLite pragmas
+	if c.SQLite.BusyTimeout < 0 {
+		return fmt.Errorf("SQLITE_BUSY_TIMEOUT must be non-negative, got: %v", c.SQLite.BusyTimeout)
+	}
+	validSynchronousValues := map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+	if c.SQLite.Synchronous != "" && !validSynchronousValues[strings.ToUpper(c.SQLite.Synchronous)] {
+		return fmt.Errorf("SQLITE_SYNCHRONOUS must be 'OFF', 'NORMAL', 'FULL', or 'EXTRA', got: %s", c.SQLite.Synchronous)
+	}
+	if c.SQLite.MmapSize < 0 {
+		return fmt.Errorf("SQLITE_MMAP_SIZE must be non-negative, got: %d", c.SQLite.MmapSize)
+	}
+
+	if c.SQLite.SlowQueryThreshold < 0 {
+		return fmt.Errorf("SQLITE_SLOW_QUERY_THRESHOLD must be non-negative, got: %v", c.SQLite.SlowQueryThreshold)
+	}
+
+	// Validate migration lock settings
+	if c.Database.MigrationLockTimeout <= 0 {
+		return fmt.Errorf("DB_MIGRATION_LOCK_TIMEOUT must be positive, got: %v", c.Database.MigrationLockTimeout)
+	}
+	if c.Database.MigrationLockLease <= 0 {
+		return fmt.Errorf("DB_MIGRATION_LOCK_LEASE must be positive, got: %v", c.Database.MigrationLockLease)
+	}
+
+	// Validate PostgreSQL settings
+	if c.Database.Driver == "postgres" {
+		if c.Postgres.URL == "" {
+			return fmt.Errorf("POSTGRES_URL is required when DB_DRIVER is 'postgres'")
+		}
+		if c.Postgres.MaxOpenConnections <= 0 {
+			return fmt.Errorf("POSTGRES_MAX_OPEN_CONNECTIONS must be positive, got: %d", c.Postgres.MaxOpenConnections)
+		}
+		if c.Postgres.MaxIdleConnections < 0 {
+			return fmt.Errorf("POSTGRES_MAX_IDLE_CONNECTIONS must be non-negative, got: %d", c.Postgres.MaxIdleConnections)
+		}
+		if c.Postgres.SlowQueryThreshold < 0 {
+			return fmt.Errorf("POSTGRES_SLOW_QUERY_THRESHOLD must be non-negative, got: %v", c.Postgres.SlowQueryThreshold)
+		}
+		// Backups and WAL replication copy the SQLite file; PostgreSQL has its
+		// own tools for both
+		if c.Backup.Interval > 0 {
+			return fmt.Errorf("BACKUP_INTERVAL is only supported with DB_DRIVER 'sqlite'")
+		}
+		if c.Replica.Dir != "" {
+			return fmt.Errorf("REPLICA_DIR is only supported with DB_DRIVER 'sqlite'")
+		}
+	}
+
+	// Validate Backup settings
+	if c.Backup.Interval < 0 {
+		return fmt.Errorf("BACKUP_INTERVAL must be non-negative, got: %v", c.Backup.Interval)
+	}
+	if c.Backup.KeepDaily < 0 {
+		return fmt.Errorf("BACKUP_KEEP_DAILY must be non-negative, got: %d", c.Backup.KeepDaily)
+	}
+	if c.Backup.KeepWeekly < 0 {
+		return fmt.Errorf("BACKUP_KEEP_WEEKLY must be non-negative, got: %d", c.Backup.KeepWeekly)
+	}
+
+	// Validate WAL replication settings
+	if c.Replica.Dir != "" {
+		if c.Replica.SyncInterval <= 0 {
+			return fmt.Errorf("REPLICA_SYNC_INTERVAL must be positive, got: %v", c.Replica.SyncInterval)
+		}
+		if c.Replica.SnapshotInterval < 0 {
+			return fmt.Errorf("REPLICA_SNAPSHOT_INTERVAL must be non-negative, got: %v", c.Replica.SnapshotInterval)
+		}
+		if c.Replica.Retention < 0 {
+			return fmt.Errorf("REPLICA_RETENTION must be non-negative, got: %v", c.Replica.Retention)
+		}
+	}
+
+	// Validate Outbox relay settings
+	if c.Outbox.PollInterval < 0 {
+		return fmt.Errorf("OUTBOX_POLL_INTERVAL must be non-negative, got: %v", c.Outbox.PollInterval)
+	}
+	if c.Outbox.BatchSize <= 0 {
+		return fmt.Errorf("OUTBOX_BATCH_SIZE must be positive, got: %d", c.Outbox.BatchSize)
+	}
+	if c.Outbox.MaxAttempts <= 0 {
+		return fmt.Errorf("OUTBOX_MAX_ATTEMPTS must be positive, got: %d", c.Outbox.MaxAttempts)
+	}
+	if c.Outbox.Retention < 0 {
+		return fmt.Errorf("OUTBOX_RETENTION must be non-negative, got: %v", c.Outbox.Retention)
+	}
+
+	// Validate personal data settings
+	if c.Privacy.ErasureGracePeriod < 0 {
+		return fmt.Errorf("PRIVACY_ERASURE_GRACE_PERIOD must be non-negative, got: %v", c.Privacy.ErasureGracePeriod)
+	}
+	if c.Privacy.ErasureInterval < 0 {
+		return fmt.Errorf("PRIVACY_ERASURE_INTERVAL must be non-negative, got: %v", c.Privacy.ErasureInterval)
+	}
+
+	// Validate tracing settings
+	if c.Tracing.OTLPEndpoint != "" {
+		u, err := url.Parse(c.Tracing.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("TRACING_OTLP_ENDPOINT must be an http or https URL, got: %s", c.Tracing.OTLPEndpoint)
+		}
+	}
+	for _, header := range strings.Split(c.Tracing.OTLPHeaders, ",") {
+		if name, _, ok := strings.Cut(header, "="); strings.TrimSpace(header) != "" && (!ok || strings.TrimSpace(name) == "") {
+			return fmt.Errorf("TRACING_OTLP_HEADERS must be a comma-separated list of name=value pairs")
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1, got: %v", c.Tracing.SampleRatio)
+	}
+
 	// Validate Rate Limiting
 	if c.RateLimit.RequestsPerWindow <= 0 {
 		return fmt.Errorf("RATE_LIMIT_REQUESTS_PER_WINDOW must be positive, got: %d", c.RateLimit.RequestsPerWindow)
@@ -283,6 +594,15 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+	}
+	return defaultValue
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if durationVal, err := time.ParseDuration(value); err == nil {
@@ -14,10 +14,24 @@ func TestLoadConfig(t *testing.T) {
 	originalEnv := make(map[string]string)
 	envVars := []string{
 		"HTTP_PORT", "HTTP_SHUTDOWN_TIMEOUT", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_IDLE_TIMEOUT",
+		"DB_DRIVER", "DB_MIGRATION_LOCK_TIMEOUT", "DB_MIGRATION_LOCK_LEASE",
 		"SQLITE_DB_FILE", "SQLITE_MAX_OPEN_CONNECTIONS", "SQLITE_MAX_IDLE_CONNECTIONS", "SQLITE_CONNECTION_MAX_LIFETIME_SECONDS",
+		"SQLITE_BUSY_TIMEOUT", "SQLITE_SYNCHRONOUS", "SQLITE_CACHE_SIZE", "SQLITE_MMAP_SIZE",
+		"SQLITE_SLOW_QUERY_THRESHOLD",
+		"POSTGRES_URL", "POSTGRES_MAX_OPEN_CONNECTIONS", "POSTGRES_MAX_IDLE_CONNECTIONS",
+		"POSTGRES_CONNECTION_MAX_LIFETIME_SECONDS", "POSTGRES_SLOW_QUERY_THRESHOLD",
+		"BACKUP_DIR", "BACKUP_INTERVAL", "BACKUP_COMPRESS", "BACKUP_KEEP_DAILY", "BACKUP_KEEP_WEEKLY",
+		"REPLICA_DIR", "REPLICA_SYNC_INTERVAL", "REPLICA_SNAPSHOT_INTERVAL", "REPLICA_RETENTION",
+		"OUTBOX_POLL_INTERVAL", "OUTBOX_BATCH_SIZE", "OUTBOX_MAX_ATTEMPTS", "OUTBOX_RETENTION",
+		"PRIVACY_ERASURE_GRACE_PERIOD", "PRIVACY_ERASURE_INTERVAL",
 		"JWT_SIGNING_SECRET", "JWT_SIGNING_SECRET_FILE", "JWT_EXPIRATION_SECONDS",
+		"ENCRYPTION_KEYRING_FILE",
+		"AUTH_ADMIN_USERS",
+		"METRICS_ENABLED", "METRICS_TOKEN", "METRICS_TOKEN_FILE",
+		"TRACING_OTLP_ENDPOINT", "TRACING_OTLP_HEADERS", "TRACING_OTLP_HEADERS_FILE", "TRACING_SERVICE_NAME", "TRACING_SAMPLE_RATIO",
 		"SESSION_COOKIE_SECRET", "SESSION_COOKIE_NAME", "SESSION_MAX_AGE_SECONDS", "SESSION_COOKIE_HTTP_ONLY", "SESSION_COOKIE_SECURE", "SESSION_COOKIE_SAMESITE",
-		"APP_ENV", "APP_LOG_LEVEL", "APP_LOG_FORMAT", "APP_NAME",
+		"APP_ENV", "APP_LOG_LEVEL", "APP_LOG_FORMAT", "APP_LOG_REDACT_KEYS", "APP_LOG_REDACT_EMAILS", "APP_NAME",
+		"APP_LOG_FILE", "APP_LOG_FILE_FORMAT", "APP_LOG_FILE_LEVEL", "APP_LOG_FILE_MAX_SIZE_MB", "APP_LOG_FILE_ROTATE_INTERVAL", "APP_LOG_FILE_MAX_BACKUPS", "APP_LOG_FILE_MAX_AGE", "APP_LOG_FILE_COMPRESS",
 		"RATE_LIMIT_REQUESTS_PER_WINDOW", "RATE_LIMIT_WINDOW_SECONDS",
 		"CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_METHODS", "CORS_ALLOWED_HEADERS", "CORS_MAX_AGE_SECONDS",
 	}
@@ -244,6 +258,177 @@ func TestValidate(t *testing.T) {
 		}
 	})
 
+	t.Run("rejects non-positive migration lock timeout", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.Database.MigrationLockTimeout = 0
+
+		err := cfg.Validate()
+		if err == nil {
+			t.Error("expected error for zero migration lock timeout, got nil")
+		}
+	})
+
+	t.Run("rejects invalid SQLite synchronous mode", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.SQLite.Synchronous = "sometimes"
+
+		err := cfg.Validate()
+		if err == nil {
+			t.Error("expected error for invalid SQLite synchronous mode, got nil")
+		}
+
+		cfg.SQLite.Synchronous = "full"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected lowercase synchronous mode to be accepted, got %v", err)
+		}
+	})
+
+	t.Run("rejects negative backup settings", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.Backup.Interval = -time.Hour
+
+		err := cfg.Validate()
+		if err == nil {
+			t.Error("expected error for negative backup interval, got nil")
+		}
+
+		cfg.Backup.Interval = time.Hour
+		cfg.Backup.KeepDaily = -1
+		err = cfg.Validate()
+		if err == nil {
+			t.Error("expected error for negative daily retention, got nil")
+		}
+	})
+
+	t.Run("validates replica settings only when enabled", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.Replica.SyncInterval = 0
+
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected disabled replication to be accepted, got %v", err)
+		}
+
+		cfg.Replica.Dir = "./replica"
+		err := cfg.Validate()
+		if err == nil {
+			t.Error("expected error for zero replica sync interval, got nil")
+		}
+	})
+
+	t.Run("rejects invalid outbox settings", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.Outbox.PollInterval = 0
+
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected a disabled relay to be accepted, got %v", err)
+		}
+
+		cfg.Outbox.MaxAttempts = 0
+		err := cfg.Validate()
+		if err == nil {
+			t.Error("expected error for zero outbox max attempts, got nil")
+		}
+	})
+
+	t.Run("validates the database driver", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+
+		cfg.Database.Driver = "mysql"
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for unknown driver, got nil")
+		}
+
+		cfg.Database.Driver = "postgres"
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "POSTGRES_URL") {
+			t.Errorf("expected error for missing POSTGRES_URL, got %v", err)
+		}
+
+		cfg.Postgres.URL = "postgres://localhost/app"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected valid postgres config, got error: %v", err)
+		}
+
+		cfg.Replica.Dir = "./replica"
+		if err := cfg.Validate(); err == nil {
+			t.Error("expected error for WAL replication with postgres, got nil")
+		}
+	})
+
+	t.Run("rejects negative privacy settings", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+		cfg.Privacy.ErasureGracePeriod = -time.Hour
+
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "PRIVACY_ERASURE_GRACE_PERIOD") {
+			t.Errorf("expected grace period error, got %v", err)
+		}
+
+		cfg.Privacy.ErasureGracePeriod = 0
+		cfg.Privacy.ErasureInterval = -time.Hour
+		err = cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "PRIVACY_ERASURE_INTERVAL") {
+			t.Errorf("expected erasure interval error, got %v", err)
+		}
+	})
+
+	t.Run("rejects invalid tracing settings", func(t *testing.T) {
+		cfg := &Config{}
+		loadConfig(cfg)
+		cfg.App.Env = "development"
+		cfg.App.LogLevel = "info"
+		cfg.App.LogFormat = "text"
+
+		for name, tc := range map[string]struct {
+			endpoint, headers string
+			ratio             float64
+			want              string
+		}{
+			"endpoint without scheme": {endpoint: "localhost:4318/v1/traces", ratio: 1, want: "TRACING_OTLP_ENDPOINT"},
+			"header without value":    {headers: "Authorization", ratio: 1, want: "TRACING_OTLP_HEADERS"},
+			"ratio above one":         {ratio: 1.5, want: "TRACING_SAMPLE_RATIO"},
+		} {
+			cfg.Tracing.OTLPEndpoint, cfg.Tracing.OTLPHeaders, cfg.Tracing.SampleRatio = tc.endpoint, tc.headers, tc.ratio
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("%s: expected %s error, got %v", name, tc.want, err)
+			}
+		}
+
+		cfg.Tracing.OTLPEndpoint = "http://localhost:4318/v1/traces"
+		cfg.Tracing.OTLPHeaders = "Authorization=Bearer abc, X-Tenant=acme"
+		cfg.Tracing.SampleRatio = 0.25
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("expected valid tracing settings, got %v", err)
+		}
+	})
+
 	t.Run("rejects zero Rate Limit requests", func(t *testing.T) {
 		cfg := &Config{}
 		cfg.App.Env = "development"
@@ -0,0 +1,482 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+const (
+	// backupFilePrefix and backupTimeFormat name scheduled backups, e.g. backup-20250101T120000Z.db.gz
+	backupFilePrefix = "backup-"
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// BackupOptions controls how a backup is written
+type BackupOptions struct {
+	// Compress gzips the snapshot; the destination should end in .gz
+	Compress bool
+}
+
+// BackupInfo describes a backup file
+type BackupInfo struct {
+	Path       string
+	Size       int64
+	CreatedAt  time.Time
+	Compressed bool
+}
+
+// Backup writes a consistent snapshot of the database to destPath using
+// VACUUM INTO, which reads from a single read transaction and therefore does
+// not block writers. The snapshot is verified with PRAGMA integrity_check
+// before it is moved into place.
+func (d *Database) Backup(ctx context.Context, destPath string, opts BackupOptions) (*BackupInfo, error) {
+	if d.dialect != SQLite {
+		return nil, fmt.Errorf("backup: %w; use pg_dump instead", ErrUnsupported)
+	}
+	if isMemoryDatabase(d.path) {
+		return nil, fmt.Errorf("cannot back up an in-memory database")
+	}
+
+	destDir := filepath.Dir(destPath)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// VACUUM INTO refuses to overwrite files, so snapshot to a fresh temp path
+	snapshot, err := os.CreateTemp(destDir, ".snapshot-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	snapshotPath := snapshot.Name()
+	snapshot.Close()
+	os.Remove(snapshotPath)
+	defer os.Remove(snapshotPath)
+
+	createdAt := time.Now().UTC()
+	if err := d.vacuumInto(ctx, snapshotPath); err != nil {
+		return nil, err
+	}
+
+	if err := checkIntegrity(ctx, snapshotPath); err != nil {
+		return nil, fmt.Errorf("snapshot failed verification: %w", err)
+	}
+
+	// Write to a temporary name and rename so a partial backup is never visible
+	tmpPath := destPath + ".tmp"
+	if opts.Compress {
+		err = gzipFile(snapshotPath, tmpPath)
+	} else {
+		err = os.Rename(snapshotPath, tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to move backup into place: %w", err)
+	}
+
+	stat, err := os.Stat(destPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	return &BackupInfo{
+		Path:       destPath,
+		Size:       stat.Size(),
+		CreatedAt:  createdAt,
+		Compressed: opts.Compress,
+	}, nil
+}
+
+// vacuumInto copies the database into path on a dedicated connection, since
+// the read pool is query-only and the write pool must stay free for writers
+func (d *Database) vacuumInto(ctx context.Context, path string) error {
+	conn, err := sql.Open("sqlite", d.path+"?"+fmt.Sprintf("_pragma=busy_timeout(%d)", d.busyTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to open backup connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// VerifyBackup runs PRAGMA integrity_check against a backup file, which may be gzipped
+func VerifyBackup(ctx context.Context, backupPath string) error {
+	path, cleanup, err := uncompressedBackup(backupPath, filepath.Dir(backupPath))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return checkIntegrity(ctx, path)
+}
+
+// Restore replaces the database at dbPath with the contents of backupPath. The
+// backup is verified first, and the current database is kept next to it with a
+// .pre-restore suffix. The application must not be using dbPath while restoring.
+func Restore(ctx context.Context, backupPath, dbPath string) error {
+	dbDir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// Decompress next to the database so the final rename is atomic
+	path, cleanup, err := uncompressedBackup(backupPath, dbDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if err := checkIntegrity(ctx, path); err != nil {
+		return fmt.Errorf("backup failed verification: %w", err)
+	}
+
+	// Copy rather than rename an uncompressed backup so the backup file survives
+	staged := dbPath + ".restore"
+	if err := copyFile(path, staged); err != nil {
+		return fmt.Errorf("failed to stage restore: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			os.Remove(staged)
+			return fmt.Errorf("failed to keep current database: %w", err)
+		}
+	}
+
+	// Stale WAL and shared-memory files belong to the old database
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+
+	if err := os.Rename(staged, dbPath); err != nil {
+		return fmt.Errorf("failed to move restored database into place: %w", err)
+	}
+	return nil
+}
+
+// readOnlyDSN returns a URI opening path read-only, escaped so that ?, # and
+// % in the path are not taken as URI syntax
+func readOnlyDSN(path string) string {
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return u.String()
+}
+
+// checkIntegrity opens path read-only and runs PRAGMA integrity_check
+func checkIntegrity(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open database file: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite", readOnlyDSN(path))
+	if err != nil {
+		return fmt.Errorf("failed to open database file: %w", err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to read integrity check result: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// uncompressedBackup returns a path to an uncompressed copy of backupPath,
+// decompressing into tmpDir if the backup is gzipped
+func uncompressedBackup(backupPath, tmpDir string) (string, func(), error) {
+	if !strings.HasSuffix(backupPath, ".gz") {
+		return backupPath, func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp(tmpDir, ".restore-*.db")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer tmp.Close()
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	src, err := os.Open(backupPath)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to read compressed backup: %w", err)
+	}
+	defer gz.Close()
+
+	if _, err := io.Copy(tmp, gz); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to decompress backup: %w", err)
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+// gzipFile compresses src into dst
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// copyFile copies src to dst and syncs it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// BackupScheduler takes periodic backups into a directory and prunes old ones
+type BackupScheduler struct {
+	db         *Database
+	dir        string
+	interval   time.Duration
+	compress   bool
+	keepDaily  int
+	keepWeekly int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewBackupScheduler creates a scheduler that backs up db into dir every interval,
+// keeping the newest backup of each of the last keepDaily days and keepWeekly weeks
+func NewBackupScheduler(db *Database, dir string, interval time.Duration, compress bool, keepDaily, keepWeekly int) *BackupScheduler {
+	return &BackupScheduler{
+		db:         db,
+		dir:        dir,
+		interval:   interval,
+		compress:   compress,
+		keepDaily:  keepDaily,
+		keepWeekly: keepWeekly,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start runs the backup loop in the background until Stop is called
+func (s *BackupScheduler) Start(ctx context.Context) {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.RunOnce(ctx); err != nil {
+					logger.FromContext(ctx).Error("Scheduled backup failed",
+						slog.String("dir", s.dir),
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the backup loop and waits for a running backup to finish
+func (s *BackupScheduler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// RunOnce takes a backup now and applies the retention policy
+func (s *BackupScheduler) RunOnce(ctx context.Context) (*BackupInfo, error) {
+	name := BackupFileName(time.Now(), s.compress)
+	info, err := s.db.Backup(ctx, filepath.Join(s.dir, name), BackupOptions{Compress: s.compress})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.FromContext(ctx).Info("Database backup completed",
+		slog.String("path", info.Path),
+		slog.Int64("size_bytes", info.Size),
+	)
+
+	removed, err := PruneBackups(s.dir, s.keepDaily, s.keepWeekly)
+	if err != nil {
+		return info, fmt.Errorf("failed to prune backups: %w", err)
+	}
+	for _, path := range removed {
+		logger.FromContext(ctx).Info("Removed expired backup", slog.String("path", path))
+	}
+
+	return info, nil
+}
+
+// BackupFileName returns the file name used for a backup taken at t
+func BackupFileName(t time.Time, compress bool) string {
+	name := backupFilePrefix + t.UTC().Format(backupTimeFormat) + ".db"
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// ListBackups returns the scheduled backups in dir, newest first
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupFilePrefix) {
+			continue
+		}
+
+		compressed := strings.HasSuffix(name, ".db.gz")
+		stamp := strings.TrimPrefix(name, backupFilePrefix)
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ".db")
+
+		createdAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		var size int64
+		if fi, err := entry.Info(); err == nil {
+			size = fi.Size()
+		}
+
+		backups = append(backups, BackupInfo{
+			Path:       filepath.Join(dir, name),
+			Size:       size,
+			CreatedAt:  createdAt,
+			Compressed: compressed,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// PruneBackups deletes scheduled backups in dir that are not the newest backup
+// of one of the keepDaily most recent days or keepWeekly most recent ISO weeks.
+// The newest backup is always kept. It returns the removed paths.
+func PruneBackups(dir string, keepDaily, keepWeekly int) ([]string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	if len(backups) > 0 {
+		keep[backups[0].Path] = true
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, b := range backups {
+		day := b.CreatedAt.Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[b.Path] = true
+		}
+
+		year, week := b.CreatedAt.ISOWeek()
+		weekKey := fmt.Sprintf("%d-W%02d", year, week)
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep[b.Path] = true
+		}
+	}
+
+	var removed []string
+	for _, b := range backups {
+		if keep[b.Path] {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
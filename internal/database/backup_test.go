@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackup(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		name := "plain"
+		if compress {
+			name = "gzip"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			db, cleanup := setupTestDB(t)
+			defer cleanup()
+			createCounterTable(t, db)
+
+			ctx := context.Background()
+			if _, err := db.Exec(ctx, "UPDATE counters SET value = 7 WHERE id = 1"); err != nil {
+				t.Fatalf("Failed to update counter: %v", err)
+			}
+
+			dest := filepath.Join(t.TempDir(), BackupFileName(time.Now(), compress))
+			info, err := db.Backup(ctx, dest, BackupOptions{Compress: compress})
+			if err != nil {
+				t.Fatalf("Backup failed: %v", err)
+			}
+			if info.Path != dest || info.Size == 0 || info.Compressed != compress {
+				t.Errorf("Unexpected backup info: %+v", info)
+			}
+
+			if err := VerifyBackup(ctx, dest); err != nil {
+				t.Errorf("Expected backup to verify, got %v", err)
+			}
+
+			// Restoring into a fresh path yields the backed up data
+			restored := filepath.Join(t.TempDir(), "restored.db")
+			if err := Restore(ctx, dest, restored); err != nil {
+				t.Fatalf("Restore failed: %v", err)
+			}
+			if value := counterValue(t, openTestDB(t, restored)); value != 7 {
+				t.Errorf("Expected restored value 7, got %d", value)
+			}
+
+			// The backup file itself is left untouched
+			if _, err := os.Stat(dest); err != nil {
+				t.Errorf("Expected backup to survive restore: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyBackupEscapesPath(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	// URI syntax in the directory must not change which file is opened
+	dir := filepath.Join(t.TempDir(), "a?b#c%20d")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	ctx := context.Background()
+	dest := filepath.Join(dir, BackupFileName(time.Now(), false))
+	if _, err := db.Backup(ctx, dest, BackupOptions{}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := VerifyBackup(ctx, dest); err != nil {
+		t.Errorf("Expected backup to verify, got %v", err)
+	}
+
+	// Opening a file cut off at the ? would find an empty, valid database
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(corrupt, []byte("not a database"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := VerifyBackup(ctx, corrupt); err == nil {
+		t.Error("Expected corrupt backup to fail verification")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "a")); !os.IsNotExist(err) {
+		t.Error("Expected no database to be created at the path before the ?")
+	}
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			db.WithTx(ctx, nil, func(tx *Tx) error {
+				_, err := tx.Exec(ctx, "UPDATE counters SET value = value + 1 WHERE id = 1")
+				return err
+			})
+		}
+	}()
+
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		dest := filepath.Join(dir, BackupFileName(time.Now().Add(time.Duration(i)*time.Second), false))
+		if _, err := db.Backup(ctx, dest, BackupOptions{}); err != nil {
+			t.Errorf("Backup during writes failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestVerifyBackupRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.db")
+	if err := os.WriteFile(path, []byte("not a database"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := VerifyBackup(ctx, path); err == nil {
+		t.Error("Expected corrupt backup to fail verification")
+	}
+
+	// A failed restore leaves the current database in place
+	dbFile := filepath.Join(t.TempDir(), "app.db")
+	createCounterTable(t, openTestDB(t, dbFile))
+	if err := Restore(ctx, path, dbFile); err == nil {
+		t.Error("Expected restore of corrupt backup to fail")
+	}
+	if _, err := os.Stat(dbFile + ".pre-restore"); !os.IsNotExist(err) {
+		t.Error("Expected current database not to be moved aside")
+	}
+}
+
+func TestRestoreKeepsPreviousDatabase(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "app.db")
+
+	db := openTestDB(t, dbFile)
+	createCounterTable(t, db)
+	backup := filepath.Join(dir, "snapshot.db.gz")
+	if _, err := db.Backup(ctx, backup, BackupOptions{Compress: true}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = 99 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+	db.Close()
+
+	if err := Restore(ctx, backup, dbFile); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	if value := counterValue(t, openTestDB(t, dbFile)); value != 0 {
+		t.Errorf("Expected restored value 0, got %d", value)
+	}
+	if value := counterValue(t, openTestDB(t, dbFile+".pre-restore")); value != 99 {
+		t.Errorf("Expected previous database to keep value 99, got %d", value)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+
+	// Two backups a day for 30 days, ending on a Sunday
+	end := time.Date(2025, 3, 30, 18, 0, 0, 0, time.UTC)
+	for day := 0; day < 30; day++ {
+		for _, hour := range []int{0, 12} {
+			ts := end.AddDate(0, 0, -day).Add(-time.Duration(hour) * time.Hour)
+			if err := os.WriteFile(filepath.Join(dir, BackupFileName(ts, true)), []byte("x"), 0644); err != nil {
+				t.Fatalf("Failed to write backup: %v", err)
+			}
+		}
+	}
+	// Unrelated files are left alone
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := PruneBackups(dir, 3, 2); err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+
+	// Newest of Mar 30, 29, 28 for the daily slots; the weekly slots are
+	// Mar 30 (already kept) and the newest of the previous week, Mar 23
+	want := []string{
+		BackupFileName(end, true),
+		BackupFileName(end.AddDate(0, 0, -1), true),
+		BackupFileName(end.AddDate(0, 0, -2), true),
+		BackupFileName(end.AddDate(0, 0, -7), true),
+	}
+	if len(backups) != len(want) {
+		t.Fatalf("Expected %d backups, got %d: %+v", len(want), len(backups), backups)
+	}
+	for i, b := range backups {
+		if filepath.Base(b.Path) != want[i] {
+			t.Errorf("Backup %d: expected %s, got %s", i, want[i], filepath.Base(b.Path))
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected unrelated file to be kept: %v", err)
+	}
+}
+
+func TestBackupScheduler(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	dir := t.TempDir()
+	scheduler := NewBackupScheduler(db, dir, 20*time.Millisecond, true, 1, 0)
+	scheduler.Start(context.Background())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backups, _ := ListBackups(dir)
+		if len(backups) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a scheduled backup to be written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	scheduler.Stop()
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	// Only the newest backup of the day is retained
+	if len(backups) != 1 {
+		t.Errorf("Expected 1 retained backup, got %d", len(backups))
+	}
+	if err := VerifyBackup(context.Background(), backups[0].Path); err != nil {
+		t.Errorf("Expected scheduled backup to verify: %v", err)
+	}
+}
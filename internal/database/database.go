@@ -4,23 +4,64 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver
 	"github.com/tediscript/gostarterkit/internal/config"
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
-// Database wraps the sql.DB with additional functionality
+// Database wraps separate connection pools for writes and reads.
+// SQLite allows a single writer at a time, so writes go through a pool with one
+// connection that starts transactions with BEGIN IMMEDIATE, while reads are
+// spread over a read-only pool. PostgreSQL uses one pool for both.
 type Database struct {
-	db   *sql.DB
-	lock sync.RWMutex
+	writer  *sql.DB
+	reader  *sql.DB
+	dialect Dialect
+	lock    sync.RWMutex
+	retry   RetryPolicy
+	// observer times every statement run through the wrappers
+	observer *queryObserver
+	// path and busyTimeout are used to open dedicated connections, e.g. for backups
+	path        string
+	busyTimeout time.Duration
 }
 
-// New creates a new database connection pool
+// New connects to the database selected by DB_DRIVER
 func New(cfg *config.Config) (*Database, error) {
+	if Dialect(cfg.Database.Driver) == Postgres {
+		return newPostgres(cfg)
+	}
+	return newSQLite(cfg)
+}
+
+// newPostgres creates a single connection pool shared by reads and writes
+func newPostgres(cfg *config.Config) (*Database, error) {
+	db, err := sql.Open("pgx", cfg.Postgres.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.Postgres.MaxOpenConnections)
+	db.SetMaxIdleConns(cfg.Postgres.MaxIdleConnections)
+	db.SetConnMaxLifetime(time.Duration(cfg.Postgres.ConnectionMaxLifetimeSeconds) * time.Second)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &Database{writer: db, reader: db, dialect: Postgres, retry: DefaultRetryPolicy, observer: newQueryObserver(cfg.Postgres.SlowQueryThreshold)}, nil
+}
+
+// newSQLite creates the write and read connection pools
+func newSQLite(cfg *config.Config) (*Database, error) {
 	// Ensure database directory exists
 	dbPath := cfg.SQLite.DBFile
 	dbDir := filepath.Dir(dbPath)
@@ -30,90 +71,215 @@ func New(cfg *config.Config) (*Database, error) {
 		}
 	}
 
-	// Open database connection
-	db, err := sql.Open("sqlite", dbPath)
+	// Open the writer first so the database file exists and is in WAL mode
+	// before any reader connects
+	writer, err := sql.Open("sqlite", writerDSN(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(cfg.SQLite.MaxOpenConnections)
-	db.SetMaxIdleConns(cfg.SQLite.MaxIdleConnections)
-	db.SetConnMaxLifetime(time.Duration(cfg.SQLite.ConnectionMaxLifetimeSeconds) * time.Second)
+	// A single writer connection avoids SQLITE_BUSY contention between our own writers
+	writer.SetMaxOpenConns(1)
+	writer.SetMaxIdleConns(1)
+	writer.SetConnMaxLifetime(time.Duration(cfg.SQLite.ConnectionMaxLifetimeSeconds) * time.Second)
 
-	// Verify connection
-	if err := db.Ping(); err != nil {
+	// Verify connection; this also applies the pragmas, including WAL mode
+	if err := writer.Ping(); err != nil {
+		writer.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	// An in-memory database is private to its connection, so it cannot be shared
+	// with a separate read pool
+	if isMemoryDatabase(dbPath) {
+		return &Database{writer: writer, reader: writer, dialect: SQLite, retry: DefaultRetryPolicy, observer: newQueryObserver(cfg.SQLite.SlowQueryThreshold), path: dbPath, busyTimeout: cfg.SQLite.BusyTimeout}, nil
+	}
+
+	reader, err := sql.Open("sqlite", readerDSN(cfg))
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to open read pool: %w", err)
+	}
+
+	// Configure connection pool
+	reader.SetMaxOpenConns(cfg.SQLite.MaxOpenConnections)
+	reader.SetMaxIdleConns(cfg.SQLite.MaxIdleConnections)
+	reader.SetConnMaxLifetime(time.Duration(cfg.SQLite.ConnectionMaxLifetimeSeconds) * time.Second)
+
+	if err := reader.Ping(); err != nil {
+		writer.Close()
+		reader.Close()
+		return nil, fmt.Errorf("failed to ping read pool: %w", err)
+	}
+
+	return &Database{writer: writer, reader: reader, dialect: SQLite, retry: DefaultRetryPolicy, observer: newQueryObserver(cfg.SQLite.SlowQueryThreshold), path: dbPath, busyTimeout: cfg.SQLite.BusyTimeout}, nil
+}
+
+// writerDSN returns the DSN for the write pool
+func writerDSN(cfg *config.Config) string {
+	params := commonPragmas(cfg)
 	// Enable WAL mode for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode=WAL;"); err != nil {
-		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
+	params.Add("_pragma", "journal_mode(WAL)")
+	// Take the write lock when the transaction starts rather than on first write,
+	// so read-then-write transactions cannot fail with SQLITE_BUSY halfway through
+	params.Set("_txlock", "immediate")
+	// With WAL replication the replicator checkpoints once frames are copied
+	if cfg.Replica.Dir != "" {
+		params.Add("_pragma", "wal_autocheckpoint(0)")
 	}
+	return cfg.SQLite.DBFile + "?" + params.Encode()
+}
+
+// readerDSN returns the DSN for the read pool
+func readerDSN(cfg *config.Config) string {
+	params := commonPragmas(cfg)
+	// Reject writes on read connections
+	params.Add("_pragma", "query_only(1)")
+	return cfg.SQLite.DBFile + "?" + params.Encode()
+}
 
+// commonPragmas returns the per-connection pragmas shared by both pools
+func commonPragmas(cfg *config.Config) url.Values {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", cfg.SQLite.BusyTimeout.Milliseconds()))
 	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys=ON;"); err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	params.Add("_pragma", "foreign_keys(1)")
+	if cfg.SQLite.Synchronous != "" {
+		params.Add("_pragma", fmt.Sprintf("synchronous(%s)", strings.ToUpper(cfg.SQLite.Synchronous)))
+	}
+	if cfg.SQLite.CacheSize != 0 {
+		params.Add("_pragma", fmt.Sprintf("cache_size(%d)", cfg.SQLite.CacheSize))
 	}
+	if cfg.SQLite.MmapSize > 0 {
+		params.Add("_pragma", fmt.Sprintf("mmap_size(%d)", cfg.SQLite.MmapSize))
+	}
+	return params
+}
+
+// isMemoryDatabase reports whether path refers to an in-memory database
+func isMemoryDatabase(path string) bool {
+	return path == ":memory:" || strings.HasPrefix(path, "file::memory:") || strings.Contains(path, "mode=memory")
+}
 
-	return &Database{db: db}, nil
+// Dialect returns the SQL dialect of the database
+func (d *Database) Dialect() Dialect {
+	return d.dialect
 }
 
-// DB returns the underlying sql.DB instance
+// DB returns the underlying sql.DB of the write pool
 func (d *Database) DB() *sql.DB {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db
+	return d.writer
 }
 
-// Ping verifies the database connection is alive
+// ReadDB returns the underlying sql.DB of the read pool
+func (d *Database) ReadDB() *sql.DB {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.reader
+}
+
+// Ping verifies both connection pools are alive
 func (d *Database) Ping(ctx context.Context) error {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.PingContext(ctx)
+	if err := d.writer.PingContext(ctx); err != nil {
+		return err
+	}
+	return d.reader.PingContext(ctx)
 }
 
-// Close closes the database connection pool
+// Close closes both connection pools
 func (d *Database) Close() error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	if d.db != nil {
-		return d.db.Close()
+	if d.writer == nil {
+		return nil
 	}
-	return nil
+
+	err := d.writer.Close()
+	if d.reader != d.writer {
+		if readErr := d.reader.Close(); err == nil {
+			err = readErr
+		}
+	}
+	return err
 }
 
-// BeginTx starts a transaction
+// BeginTx starts a transaction on the write pool. Prefer WithTx, which handles
+// commit, rollback and retries on busy errors.
 func (d *Database) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.BeginTx(ctx, opts)
+	return d.writer.BeginTx(ctx, opts)
 }
 
-// Exec executes a query without returning any rows
+// Exec executes a query without returning any rows on the write pool
 func (d *Database) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.ExecContext(ctx, query, args...)
+	span := startQuerySpan(ctx, d.dialect, query)
+	start := time.Now()
+	result, err := d.writer.ExecContext(ctx, d.rebind(query, args), args...)
+	d.observer.observe(ctx, query, args, start, err)
+	endQuerySpan(span, err)
+	return result, err
 }
 
-// Query executes a query that returns rows
+// Query executes a query that returns rows on the read pool. Statements that
+// write, such as INSERT ... RETURNING, must use a transaction instead.
 func (d *Database) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.QueryContext(ctx, query, args...)
+	span := startQuerySpan(ctx, d.dialect, query)
+	start := time.Now()
+	rows, err := d.reader.QueryContext(ctx, d.rebind(query, args), args...)
+	d.observer.observe(ctx, query, args, start, err)
+	endQuerySpan(span, err)
+	return rows, err
 }
 
-// QueryRow executes a query that returns at most one row
+// QueryRow executes a query that returns at most one row on the read pool
 func (d *Database) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.QueryRowContext(ctx, query, args...)
+	span := startQuerySpan(ctx, d.dialect, query)
+	start := time.Now()
+	row := d.reader.QueryRowContext(ctx, d.rebind(query, args), args...)
+	d.observer.observe(ctx, query, args, start, row.Err())
+	endQuerySpan(span, row.Err())
+	return row
 }
 
-// Stats returns connection pool statistics
+// rebind rewrites the placeholders of a query with arguments for the
+// dialect. Statements without arguments, such as migrations, run as written.
+func (d *Database) rebind(query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+	return d.dialect.Rebind(query)
+}
+
+// Stats returns read pool statistics
 func (d *Database) Stats() sql.DBStats {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.db.Stats()
+	return d.reader.Stats()
+}
+
+// WriterStats returns write pool statistics
+func (d *Database) WriterStats() sql.DBStats {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.writer.Stats()
+}
+
+// SplitPools reports whether reads and writes go through separate pools. On
+// Postgres and in-memory SQLite they share one, so Stats and WriterStats
+// describe the same connections.
+func (d *Database) SplitPools() bool {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.reader != d.writer
 }
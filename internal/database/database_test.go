@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/tediscript/gostarterkit/internal/config"
 )
@@ -448,3 +449,117 @@ func TestInvalidSQL(t *testing.T) {
 		t.Error("Expected error for invalid SQL")
 	}
 }
+
+func TestReadWritePools(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{}
+	cfg.SQLite.DBFile = tmpDir + "/pools.db"
+	cfg.SQLite.MaxOpenConnections = 4
+	cfg.SQLite.MaxIdleConnections = 2
+	cfg.SQLite.ConnectionMaxLifetimeSeconds = 300
+	cfg.SQLite.BusyTimeout = 2500 * time.Millisecond
+	cfg.SQLite.Synchronous = "full"
+	cfg.SQLite.CacheSize = -4000
+	cfg.SQLite.MmapSize = 1 << 20
+
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	t.Run("writer pool has a single connection", func(t *testing.T) {
+		if got := db.WriterStats().MaxOpenConnections; got != 1 {
+			t.Errorf("Expected writer MaxOpenConnections 1, got %d", got)
+		}
+		if got := db.Stats().MaxOpenConnections; got != 4 {
+			t.Errorf("Expected reader MaxOpenConnections 4, got %d", got)
+		}
+		if !db.SplitPools() {
+			t.Error("Expected separate read and write pools")
+		}
+	})
+
+	t.Run("pragmas are applied", func(t *testing.T) {
+		pragmas := map[string]int64{
+			"busy_timeout": 2500,
+			"synchronous":  2, // FULL
+			"cache_size":   -4000,
+			"mmap_size":    1 << 20,
+			"foreign_keys": 1,
+		}
+		for name, want := range pragmas {
+			var got int64
+			if err := db.QueryRow(ctx, "PRAGMA "+name).Scan(&got); err != nil {
+				t.Fatalf("Failed to read pragma %s: %v", name, err)
+			}
+			if got != want {
+				t.Errorf("Expected %s = %d, got %d", name, want, got)
+			}
+		}
+
+		var mode string
+		if err := db.DB().QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatalf("Failed to read journal mode: %v", err)
+		}
+		if mode != "wal" {
+			t.Errorf("Expected WAL journal mode, got %s", mode)
+		}
+	})
+
+	t.Run("reads see committed writes", func(t *testing.T) {
+		if _, err := db.Exec(ctx, "CREATE TABLE routed (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		if _, err := db.Exec(ctx, "INSERT INTO routed (name) VALUES (?)", "a"); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+
+		var count int
+		if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM routed").Scan(&count); err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("Expected 1 row, got %d", count)
+		}
+	})
+
+	t.Run("read pool rejects writes", func(t *testing.T) {
+		rows, err := db.Query(ctx, "INSERT INTO routed (name) VALUES ('b') RETURNING id")
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+		}
+		if err == nil {
+			t.Error("Expected write through the read pool to fail")
+		}
+	})
+}
+
+func TestInMemoryDatabase(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SQLite.DBFile = ":memory:"
+	cfg.SQLite.MaxOpenConnections = 4
+
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create in-memory database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, "CREATE TABLE mem (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// Reads must see the same in-memory database as writes
+	var count int
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM mem").Scan(&count); err != nil {
+		t.Fatalf("Expected table to be visible to reads: %v", err)
+	}
+}
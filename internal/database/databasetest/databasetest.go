@@ -0,0 +1,57 @@
+// Package databasetest provides helpers for tests that need a real database.
+// It is kept apart from package database so the testing package is not linked
+// into the application.
+package databasetest
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresURL creates an empty schema in the TEST_POSTGRES_URL database
+// and returns a URL whose connections use it. The schema is dropped when the
+// test ends, and the test is skipped if no URL is set.
+func PostgresURL(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL is not set")
+	}
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open TEST_POSTGRES_URL: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("Failed to drop test schema: %v", err)
+		}
+		admin.Close()
+	})
+
+	// Unknown connection parameters are sent to the server as settings, in
+	// both the URL and the keyword/value form
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("Failed to parse TEST_POSTGRES_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,20 @@
+package databasetest
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPostgresURLSkips(t *testing.T) {
+	if os.Getenv("TEST_POSTGRES_URL") != "" {
+		t.Skip("TEST_POSTGRES_URL is set")
+	}
+	skipped := false
+	t.Run("without a URL", func(t *testing.T) {
+		defer func() { skipped = t.Skipped() }()
+		PostgresURL(t)
+	})
+	if !skipped {
+		t.Error("Expected the test to be skipped")
+	}
+}
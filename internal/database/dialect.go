@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect identifies the SQL flavour of a database. Queries throughout the
+// code base are written with ? placeholders and standard SQL; the Database and
+// Tx wrappers rebind the placeholders for the dialect, and the few statements
+// that differ branch on it.
+type Dialect string
+
+const (
+	// SQLite is the embedded modernc.org/sqlite backend
+	SQLite Dialect = "sqlite"
+	// Postgres is the PostgreSQL backend, using pgx
+	Postgres Dialect = "postgres"
+)
+
+// ErrUnsupported is returned by features that the database's dialect does not
+// support, such as file backups and WAL replication on PostgreSQL
+var ErrUnsupported = errors.New("not supported by this database")
+
+// Rebind rewrites ? placeholders into the dialect's bind syntax ($1, $2, ...
+// for PostgreSQL). Question marks inside string literals, quoted identifiers
+// and comments are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// Copy the quoted text; doubled quotes are escapes and simply
+			// close and reopen the literal
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+1])
+			i += end
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+4])
+			i += end + 3
+		case c == '?':
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// SupportsLastInsertID reports whether sql.Result.LastInsertId works. When it
+// doesn't, generated keys are read back with INSERT ... RETURNING.
+func (d Dialect) SupportsLastInsertID() bool {
+	return d == SQLite
+}
+
+// isBusyError reports whether err means the transaction lost a race with
+// another writer and can be retried: SQLITE_BUSY or SQLITE_LOCKED on SQLite,
+// and serialization failures, deadlocks and lock timeouts on PostgreSQL
+func isBusyError(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// Extended result codes carry the primary code in the low byte
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return true
+		}
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", "40P01", "55P03":
+			return true
+		}
+	}
+	return false
+}
+
+// ResetSequence moves the sequence behind an identity column past the largest
+// key in table, after rows were inserted with explicit keys. SQLite derives
+// the next rowid from the table itself, so it does nothing there. table and
+// column must be trusted identifiers.
+func ResetSequence(ctx context.Context, q Querier, table, column string) error {
+	if q.Dialect() != Postgres {
+		return nil
+	}
+	query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 0) + 1, false) FROM %s", table, column, column, table)
+	if _, err := q.Exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to reset the %s.%s sequence: %w", table, column, err)
+	}
+	return nil
+}
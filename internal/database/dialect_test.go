@@ -0,0 +1,47 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{"sqlite is unchanged", SQLite, "SELECT * FROM users WHERE id = ? AND name = ?", "SELECT * FROM users WHERE id = ? AND name = ?"},
+		{"numbered placeholders", Postgres, "SELECT * FROM users WHERE id = ? AND name = ?", "SELECT * FROM users WHERE id = $1 AND name = $2"},
+		{"string literals", Postgres, "SELECT '?', 'it''s ?' FROM t WHERE a = ?", "SELECT '?', 'it''s ?' FROM t WHERE a = $1"},
+		{"quoted identifiers", Postgres, `SELECT "what?" FROM t WHERE a = ?`, `SELECT "what?" FROM t WHERE a = $1`},
+		{"line comments", Postgres, "SELECT a -- why?\nFROM t WHERE a = ?", "SELECT a -- why?\nFROM t WHERE a = $1"},
+		{"block comments", Postgres, "SELECT /* ? */ a FROM t WHERE a = ?", "SELECT /* ? */ a FROM t WHERE a = $1"},
+		{"unterminated literal", Postgres, "SELECT ? WHERE a = 'oops ?", "SELECT $1 WHERE a = 'oops ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dialect.Rebind(tt.query); got != tt.want {
+				t.Errorf("Rebind(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	for _, code := range []string{"40001", "40P01", "55P03"} {
+		if !isBusyError(fmt.Errorf("commit: %w", &pgconn.PgError{Code: code})) {
+			t.Errorf("Expected %s to be retryable", code)
+		}
+	}
+	if isBusyError(&pgconn.PgError{Code: "23505"}) {
+		t.Error("Expected a unique violation not to be retryable")
+	}
+	if isBusyError(errors.New("busy")) {
+		t.Error("Expected a plain error not to be retryable")
+	}
+}
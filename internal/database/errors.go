@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Sentinel errors returned by repositories. Use errors.Is to test for them and
+// errors.As with *ConflictError or *InvalidError for details.
+var (
+	// ErrNotFound means the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict means the write clashes with existing data, such as a
+	// duplicate unique value or a missing foreign key reference
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid means the data was rejected as invalid, such as a NOT NULL or
+	// CHECK constraint violation
+	ErrInvalid = errors.New("invalid data")
+	// ErrVersionConflict means an update was based on a stale version of the
+	// record because someone else changed it first; it is also an ErrConflict
+	ErrVersionConflict = fmt.Errorf("%w: version mismatch", ErrConflict)
+)
+
+// ConflictError describes a UNIQUE, PRIMARY KEY or FOREIGN KEY constraint violation
+type ConflictError struct {
+	// Constraint is "unique", "primary key" or "foreign key"
+	Constraint string
+	// Table and Columns identify the offending columns; SQLite does not report
+	// them for foreign key violations, PostgreSQL reports the referencing ones
+	Table   string
+	Columns []string
+	Err     error
+}
+
+// Error describes the violated constraint
+func (e *ConflictError) Error() string {
+	if len(e.Columns) == 0 {
+		return fmt.Sprintf("%s: %s constraint violated", ErrConflict, e.Constraint)
+	}
+	return fmt.Sprintf("%s: %s constraint violated on %s", ErrConflict, e.Constraint, qualifiedColumns(e.Table, e.Columns))
+}
+
+// Is makes errors.Is(err, ErrConflict) match
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Unwrap returns the driver error
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidError describes a NOT NULL or CHECK constraint violation
+type InvalidError struct {
+	// Constraint is "not null" or "check"
+	Constraint string
+	// Table and Columns identify the column of a NOT NULL violation
+	Table   string
+	Columns []string
+	// Detail is the failing CHECK expression or constraint name
+	Detail string
+	Err    error
+}
+
+// Error describes the violated constraint
+func (e *InvalidError) Error() string {
+	if len(e.Columns) > 0 {
+		return fmt.Sprintf("%s: %s constraint violated on %s", ErrInvalid, e.Constraint, qualifiedColumns(e.Table, e.Columns))
+	}
+	return fmt.Sprintf("%s: %s constraint violated: %s", ErrInvalid, e.Constraint, e.Detail)
+}
+
+// Is makes errors.Is(err, ErrInvalid) match
+func (e *InvalidError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Unwrap returns the driver error
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// constraintDetailPattern extracts the columns or expression from messages like
+// "constraint failed: UNIQUE constraint failed: users.email (2067)"
+var constraintDetailPattern = regexp.MustCompile(`constraint failed: (.+?)(?: \(\d+\))?$`)
+
+// pgKeyDetailPattern extracts the columns from PostgreSQL details like
+// "Key (email)=(ada@example.com) already exists."
+var pgKeyDetailPattern = regexp.MustCompile(`^Key \((.+?)\)=`)
+
+// MapError translates driver errors into the sentinel errors: sql.ErrNoRows
+// becomes ErrNotFound and constraint violations become *ConflictError or
+// *InvalidError. Other errors are returned unchanged.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return mapPostgresError(pgErr, err)
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	detail := ""
+	if m := constraintDetailPattern.FindStringSubmatch(sqliteErr.Error()); m != nil {
+		detail = m[1]
+		// The message repeats the generic "constraint failed: " prefix
+		if _, rest, ok := strings.Cut(detail, "constraint failed: "); ok {
+			detail = rest
+		}
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		table, columns := parseConstraintColumns(detail)
+		return &ConflictError{Constraint: "unique", Table: table, Columns: columns, Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		table, columns := parseConstraintColumns(detail)
+		return &ConflictError{Constraint: "primary key", Table: table, Columns: columns, Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &ConflictError{Constraint: "foreign key", Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		table, columns := parseConstraintColumns(detail)
+		return &InvalidError{Constraint: "not null", Table: table, Columns: columns, Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		return &InvalidError{Constraint: "check", Detail: detail, Err: err}
+	}
+	return err
+}
+
+// mapPostgresError translates the SQLSTATE codes of integrity constraint
+// violations
+func mapPostgresError(pgErr *pgconn.PgError, err error) error {
+	var columns []string
+	if m := pgKeyDetailPattern.FindStringSubmatch(pgErr.Detail); m != nil {
+		columns = strings.Split(m[1], ", ")
+	}
+
+	switch pgErr.Code {
+	case "23505":
+		constraint := "unique"
+		if strings.HasSuffix(pgErr.ConstraintName, "_pkey") {
+			constraint = "primary key"
+		}
+		return &ConflictError{Constraint: constraint, Table: pgErr.TableName, Columns: columns, Err: err}
+	case "23503":
+		return &ConflictError{Constraint: "foreign key", Table: pgErr.TableName, Columns: columns, Err: err}
+	case "23502":
+		return &InvalidError{Constraint: "not null", Table: pgErr.TableName, Columns: []string{pgErr.ColumnName}, Err: err}
+	case "23514":
+		return &InvalidError{Constraint: "check", Detail: pgErr.ConstraintName, Err: err}
+	}
+	return err
+}
+
+// parseConstraintColumns splits "users.a, users.b" into the table and column names
+func parseConstraintColumns(detail string) (string, []string) {
+	if detail == "" {
+		return "", nil
+	}
+
+	var table string
+	var columns []string
+	for _, part := range strings.Split(detail, ", ") {
+		t, column, ok := strings.Cut(part, ".")
+		if !ok {
+			column = t
+			t = ""
+		}
+		table = t
+		columns = append(columns, column)
+	}
+	return table, columns
+}
+
+// qualifiedColumns formats columns as table.column
+func qualifiedColumns(table string, columns []string) string {
+	if table == "" {
+		return strings.Join(columns, ", ")
+	}
+	qualified := make([]string, len(columns))
+	for i, column := range columns {
+		qualified[i] = table + "." + column
+	}
+	return strings.Join(qualified, ", ")
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestMapError(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	schema := []string{
+		"CREATE TABLE teams (id INTEGER PRIMARY KEY)",
+		`CREATE TABLE members (
+			id INTEGER PRIMARY KEY,
+			email TEXT NOT NULL UNIQUE,
+			first TEXT,
+			last TEXT,
+			age INTEGER CHECK (age >= 0),
+			team_id INTEGER REFERENCES teams(id),
+			UNIQUE (first, last)
+		)`,
+		"INSERT INTO members (id, email, first, last) VALUES (1, 'a@example.com', 'Ada', 'Lovelace')",
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			t.Fatalf("Failed to set up schema: %v", err)
+		}
+	}
+
+	t.Run("no rows is not found", func(t *testing.T) {
+		var id int
+		err := MapError(db.QueryRow(ctx, "SELECT id FROM members WHERE id = 42").Scan(&id))
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows to remain in the chain, got %v", err)
+		}
+	})
+
+	conflicts := []struct {
+		name       string
+		stmt       string
+		constraint string
+		table      string
+		columns    []string
+	}{
+		{"unique column", "INSERT INTO members (email) VALUES ('a@example.com')", "unique", "members", []string{"email"}},
+		{"composite unique", "INSERT INTO members (email, first, last) VALUES ('b@example.com', 'Ada', 'Lovelace')", "unique", "members", []string{"first", "last"}},
+		{"primary key", "INSERT INTO members (id, email) VALUES (1, 'c@example.com')", "primary key", "members", []string{"id"}},
+		{"foreign key", "INSERT INTO members (email, team_id) VALUES ('d@example.com', 7)", "foreign key", "", nil},
+	}
+	for _, tt := range conflicts {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := db.Exec(ctx, tt.stmt)
+			err = MapError(err)
+			if !errors.Is(err, ErrConflict) {
+				t.Fatalf("Expected ErrConflict, got %v", err)
+			}
+
+			var conflict *ConflictError
+			if !errors.As(fmt.Errorf("wrapped: %w", err), &conflict) {
+				t.Fatalf("Expected ConflictError, got %T", err)
+			}
+			if conflict.Constraint != tt.constraint || conflict.Table != tt.table || !reflect.DeepEqual(conflict.Columns, tt.columns) {
+				t.Errorf("Expected %s on %s %v, got %s on %s %v", tt.constraint, tt.table, tt.columns, conflict.Constraint, conflict.Table, conflict.Columns)
+			}
+		})
+	}
+
+	t.Run("not null is invalid", func(t *testing.T) {
+		_, err := db.Exec(ctx, "INSERT INTO members (first) VALUES ('Grace')")
+		var invalid *InvalidError
+		if !errors.As(MapError(err), &invalid) || !errors.Is(MapError(err), ErrInvalid) {
+			t.Fatalf("Expected InvalidError, got %v", err)
+		}
+		if invalid.Constraint != "not null" || !reflect.DeepEqual(invalid.Columns, []string{"email"}) {
+			t.Errorf("Expected not null on email, got %s %v", invalid.Constraint, invalid.Columns)
+		}
+	})
+
+	t.Run("check is invalid", func(t *testing.T) {
+		_, err := db.Exec(ctx, "INSERT INTO members (email, age) VALUES ('e@example.com', -1)")
+		var invalid *InvalidError
+		if !errors.As(MapError(err), &invalid) {
+			t.Fatalf("Expected InvalidError, got %v", err)
+		}
+		if invalid.Constraint != "check" || invalid.Detail != "age >= 0" {
+			t.Errorf("Expected check on age >= 0, got %s %q", invalid.Constraint, invalid.Detail)
+		}
+	})
+
+	t.Run("other errors are unchanged", func(t *testing.T) {
+		_, err := db.Exec(ctx, "SELECT * FROM missing")
+		if mapped := MapError(err); mapped != err {
+			t.Errorf("Expected error to be returned unchanged, got %v", mapped)
+		}
+		if MapError(nil) != nil {
+			t.Error("Expected nil for nil error")
+		}
+	})
+}
+
+func TestMapPostgresError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *pgconn.PgError
+		constraint string
+		columns    []string
+		conflict   bool
+	}{
+		{"unique", &pgconn.PgError{Code: "23505", TableName: "members", ConstraintName: "members_email_key", Detail: "Key (email)=(a@example.com) already exists."}, "unique", []string{"email"}, true},
+		{"composite unique", &pgconn.PgError{Code: "23505", TableName: "members", ConstraintName: "members_first_last_key", Detail: "Key (first, last)=(Ada, Lovelace) already exists."}, "unique", []string{"first", "last"}, true},
+		{"primary key", &pgconn.PgError{Code: "23505", TableName: "members", ConstraintName: "members_pkey", Detail: "Key (id)=(1) already exists."}, "primary key", []string{"id"}, true},
+		{"foreign key", &pgconn.PgError{Code: "23503", TableName: "members", ConstraintName: "members_team_id_fkey", Detail: "Key (team_id)=(7) is not present in table \"teams\"."}, "foreign key", []string{"team_id"}, true},
+		{"not null", &pgconn.PgError{Code: "23502", TableName: "members", ColumnName: "email"}, "not null", []string{"email"}, false},
+		{"check", &pgconn.PgError{Code: "23514", TableName: "members", ConstraintName: "members_age_check"}, "check", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := MapError(fmt.Errorf("exec: %w", tt.err))
+			if tt.conflict {
+				var conflict *ConflictError
+				if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+					t.Fatalf("Expected ConflictError, got %v", err)
+				}
+				if conflict.Constraint != tt.constraint || conflict.Table != "members" || !reflect.DeepEqual(conflict.Columns, tt.columns) {
+					t.Errorf("Expected %s on %v, got %s on %s %v", tt.constraint, tt.columns, conflict.Constraint, conflict.Table, conflict.Columns)
+				}
+				return
+			}
+
+			var invalid *InvalidError
+			if !errors.As(err, &invalid) || !errors.Is(err, ErrInvalid) {
+				t.Fatalf("Expected InvalidError, got %v", err)
+			}
+			if invalid.Constraint != tt.constraint || !reflect.DeepEqual(invalid.Columns, tt.columns) {
+				t.Errorf("Expected %s on %v, got %s %v", tt.constraint, tt.columns, invalid.Constraint, invalid.Columns)
+			}
+		})
+	}
+
+	t.Run("other errors are unchanged", func(t *testing.T) {
+		err := &pgconn.PgError{Code: "42P01", Message: "relation \"missing\" does not exist"}
+		if mapped := MapError(err); mapped != error(err) {
+			t.Errorf("Expected error to be returned unchanged, got %v", mapped)
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+const (
+	// DefaultMigrationLockTimeout is how long Migrate waits for another instance to release the lock
+	DefaultMigrationLockTimeout = 60 * time.Second
+
+	// DefaultMigrationLockLease is how long a lock stays valid without being renewed by its holder
+	DefaultMigrationLockLease = 30 * time.Second
+
+	// migrationLockPollInterval is how often a waiting instance re-checks the lock
+	migrationLockPollInterval = 250 * time.Millisecond
+)
+
+// ErrMigrationLockTimeout is returned when the migration lock could not be acquired in time
+var ErrMigrationLockTimeout = errors.New("timed out waiting for migration lock")
+
+// ErrMigrationLockLost is returned when the lease ran out or was taken over by
+// another instance while migrations were running
+var ErrMigrationLockLost = errors.New("migration lock was lost")
+
+// migrationLockInfo describes the current holder of the migration lock
+type migrationLockInfo struct {
+	Holder     string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+// migrationLock is a lease-based lock stored in the schema_migrations_lock table.
+// The holder renews the lease in the background until the lock is released, so a
+// crashed instance only blocks others until its lease expires. Migrations run
+// through do, which holds the lock row for the length of each migration, so a
+// migration that outlasts the lease cannot be taken over halfway.
+type migrationLock struct {
+	db     *Database
+	holder string
+	lease  time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+
+	// ctx is cancelled with ErrMigrationLockLost when renew finds the lock
+	// lost, so migrations stop instead of racing the new holder
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	// expiresAt is when the lease runs out unless renewed; only renew uses it
+	expiresAt time.Time
+}
+
+// newMigrationLockHolder returns an identifier for this process that is unique across hosts
+func newMigrationLockHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), uuid.New().String()[:8])
+}
+
+// acquireMigrationLock blocks until the migration lock is held by holder or timeout elapses
+func acquireMigrationLock(ctx context.Context, db *Database, holder string, timeout, lease time.Duration) (*migrationLock, error) {
+	log := logger.FromContext(ctx)
+	parent := ctx
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(migrationLockPollInterval)
+	defer ticker.Stop()
+
+	var lastHolder string
+	for {
+		acquired, current, err := tryAcquireMigrationLock(ctx, db, holder, lease)
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+
+		if acquired {
+			log.Info("Acquired migration lock",
+				slog.String("holder", holder),
+				slog.Duration("lease", lease),
+			)
+
+			lockCtx, cancelLock := context.WithCancelCause(parent)
+			lock := &migrationLock{
+				db:        db,
+				holder:    holder,
+				lease:     lease,
+				stop:      make(chan struct{}),
+				done:      make(chan struct{}),
+				ctx:       lockCtx,
+				cancel:    cancelLock,
+				expiresAt: time.Now().Add(lease),
+			}
+			go lock.renew(context.WithoutCancel(ctx))
+			return lock, nil
+		}
+
+		// Only log when the holder changes to avoid flooding the output while waiting
+		if current != nil && current.Holder != lastHolder {
+			log.Info("Waiting for migration lock held by another instance",
+				slog.String("holder", current.Holder),
+				slog.Time("acquired_at", current.AcquiredAt),
+				slog.Time("expires_at", current.ExpiresAt),
+				slog.Duration("timeout", timeout),
+			)
+			lastHolder = current.Holder
+		}
+
+		select {
+		case <-ctx.Done():
+			if current != nil {
+				return nil, fmt.Errorf("%w after %v (held by %s until %s)",
+					ErrMigrationLockTimeout, timeout, current.Holder, current.ExpiresAt.Format(time.RFC3339))
+			}
+			return nil, fmt.Errorf("%w after %v", ErrMigrationLockTimeout, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryAcquireMigrationLock makes a single attempt to take the lock. It returns the
+// current lock holder when the lock is held by someone else.
+func tryAcquireMigrationLock(ctx context.Context, db *Database, holder string, lease time.Duration) (bool, *migrationLockInfo, error) {
+	if _, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			holder TEXT NOT NULL,
+			acquired_at BIGINT NOT NULL,
+			expires_at BIGINT NOT NULL
+		);
+	`); err != nil {
+		if isBusyError(err) {
+			return false, nil, nil
+		}
+		return false, nil, fmt.Errorf("failed to create migration lock table: %w", err)
+	}
+
+	// Check the current holder with a read first; in WAL mode reads never block
+	// the instance that is running migrations
+	current, err := readMigrationLock(ctx, db)
+	if err != nil {
+		if isBusyError(err) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+
+	now := time.Now()
+	if current != nil && current.Holder != holder && now.Before(current.ExpiresAt) {
+		return false, current, nil
+	}
+
+	if current != nil && current.Holder != holder {
+		logger.FromContext(ctx).Warn("Migration lock lease expired, taking over",
+			slog.String("previous_holder", current.Holder),
+			slog.Time("expired_at", current.ExpiresAt),
+			slog.String("holder", holder),
+		)
+	}
+
+	// Take the lock if it is free, expired or already ours. The conditional upsert
+	// makes the check-and-set atomic when several instances race for it.
+	result, err := db.Exec(ctx, `
+		INSERT INTO schema_migrations_lock (id, holder, acquired_at, expires_at)
+		VALUES (1, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = excluded.acquired_at,
+			expires_at = excluded.expires_at
+		WHERE schema_migrations_lock.expires_at < ? OR schema_migrations_lock.holder = excluded.holder
+	`, holder, now.UnixNano(), now.Add(lease).UnixNano(), now.UnixNano())
+	if err != nil {
+		if isBusyError(err) {
+			return false, current, nil
+		}
+		return false, nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 1 {
+		return true, nil, nil
+	}
+
+	// Someone else won the race; report them as the holder
+	current, err = readMigrationLock(ctx, db)
+	if err != nil && !isBusyError(err) {
+		return false, nil, err
+	}
+	return false, current, nil
+}
+
+// readMigrationLock returns the current lock row, or nil if nobody holds the lock
+func readMigrationLock(ctx context.Context, db *Database) (*migrationLockInfo, error) {
+	var holder string
+	var acquiredAt, expiresAt int64
+	err := db.QueryRow(ctx,
+		"SELECT holder, acquired_at, expires_at FROM schema_migrations_lock WHERE id = 1",
+	).Scan(&holder, &acquiredAt, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read migration lock: %w", err)
+	}
+
+	return &migrationLockInfo{
+		Holder:     holder,
+		AcquiredAt: time.Unix(0, acquiredAt),
+		ExpiresAt:  time.Unix(0, expiresAt),
+	}, nil
+}
+
+// do runs fn in a transaction that first confirms the lock is still held and
+// extends its lease. The update keeps the lock row locked on Postgres, and the
+// write lock held on SQLite, until the transaction ends, so no other instance
+// can take the lock over while fn runs, however long it takes. The lease is
+// extended again before committing, so it is valid when fn's changes land.
+// fn gets a context that is cancelled if the lock is lost, and is not run at
+// all once it has been lost.
+func (l *migrationLock) do(fn func(ctx context.Context, tx *Tx) error) error {
+	if err := l.lost(); err != nil {
+		return err
+	}
+	err := l.db.WithTx(l.ctx, nil, func(tx *Tx) error {
+		if err := l.extend(tx); err != nil {
+			return err
+		}
+		if err := fn(l.ctx, tx); err != nil {
+			return err
+		}
+		return l.extend(tx)
+	})
+	if err != nil {
+		if lost := l.lost(); lost != nil {
+			return lost
+		}
+		return err
+	}
+	return nil
+}
+
+// extend confirms in tx that the lock is still held and extends its lease,
+// failing with ErrMigrationLockLost if another instance has taken it
+func (l *migrationLock) extend(tx *Tx) error {
+	result, err := tx.Exec(l.ctx,
+		"UPDATE schema_migrations_lock SET expires_at = ? WHERE id = 1 AND holder = ?",
+		time.Now().Add(l.lease).UnixNano(), l.holder,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to confirm migration lock: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		lost := fmt.Errorf("%w to another instance", ErrMigrationLockLost)
+		l.cancel(lost)
+		return lost
+	}
+	return nil
+}
+
+// lost returns ErrMigrationLockLost once the lock has been lost
+func (l *migrationLock) lost() error {
+	if cause := context.Cause(l.ctx); errors.Is(cause, ErrMigrationLockLost) {
+		return cause
+	}
+	return nil
+}
+
+// renew extends the lease periodically until the lock is released. While a
+// migration runs, the update waits for it to commit, which is safe since do
+// keeps the lock from being taken over meanwhile.
+func (l *migrationLock) renew(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		expiresAt := time.Now().Add(l.lease)
+		result, err := l.db.Exec(ctx,
+			"UPDATE schema_migrations_lock SET expires_at = ? WHERE id = 1 AND holder = ?",
+			expiresAt.UnixNano(), l.holder,
+		)
+
+		if err != nil {
+			// Once the lease has run out another instance may take over
+			if time.Now().After(l.expiresAt) {
+				logger.FromContext(ctx).Error("Migration lock lease expired before it could be renewed",
+					slog.String("holder", l.holder),
+					slog.String("error", err.Error()),
+				)
+				l.cancel(fmt.Errorf("%w: lease expired at %s", ErrMigrationLockLost, l.expiresAt.Format(time.RFC3339)))
+				return
+			}
+			// A busy database is retried on the next tick
+			if !isBusyError(err) {
+				logger.FromContext(ctx).Warn("Failed to renew migration lock",
+					slog.String("holder", l.holder),
+					slog.String("error", err.Error()),
+				)
+			}
+			continue
+		}
+
+		if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+			logger.FromContext(ctx).Error("Migration lock was lost to another instance",
+				slog.String("holder", l.holder),
+			)
+			l.cancel(fmt.Errorf("%w to another instance", ErrMigrationLockLost))
+			return
+		}
+		l.expiresAt = expiresAt
+	}
+}
+
+// release stops lease renewal and deletes the lock row if it is still ours
+func (l *migrationLock) release(ctx context.Context) error {
+	close(l.stop)
+	<-l.done
+	l.cancel(nil)
+
+	if _, err := l.db.Exec(ctx,
+		"DELETE FROM schema_migrations_lock WHERE id = 1 AND holder = ?", l.holder,
+	); err != nil {
+		return fmt.Errorf("failed to release migration lock: %w", err)
+	}
+
+	logger.FromContext(ctx).Info("Released migration lock",
+		slog.String("holder", l.holder),
+	)
+	return nil
+}
@@ -0,0 +1,372 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NumberingStyle controls how CreateMigration numbers new migration files
+type NumberingStyle string
+
+const (
+	// SequentialNumbering numbers migrations 000001, 000002, ...
+	SequentialNumbering NumberingStyle = "sequential"
+	// TimestampNumbering numbers migrations by UTC creation time (YYYYMMDDHHMMSS)
+	TimestampNumbering NumberingStyle = "timestamp"
+
+	// timestampVersionFormat is the layout used for timestamp-numbered migrations
+	timestampVersionFormat = "20060102150405"
+
+	// minTimestampVersion is the smallest version treated as a timestamp (14 digits)
+	minTimestampVersion = 10000000000000
+)
+
+// migrationFileRegex matches version_name.up.sql and version_name.down.sql
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_([A-Za-z0-9][A-Za-z0-9_]*)\.(up|down)\.sql$`)
+
+// migrationNameRegex matches characters that are not allowed in migration names
+var migrationNameRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// MigrationIssue describes a problem with the files in the migrations directory
+type MigrationIssue struct {
+	File    string
+	Version int
+	Problem string
+}
+
+// String returns a human readable description of the issue
+func (i MigrationIssue) String() string {
+	if i.File != "" {
+		return fmt.Sprintf("%s: %s", i.File, i.Problem)
+	}
+	return fmt.Sprintf("version %d: %s", i.Version, i.Problem)
+}
+
+// MigrationValidationError is returned when the migrations directory is inconsistent
+type MigrationValidationError []MigrationIssue
+
+// Error implements the error interface
+func (e MigrationValidationError) Error() string {
+	if len(e) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("invalid migrations: ")
+	for i, issue := range e {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(issue.String())
+	}
+	return sb.String()
+}
+
+// migrationDialects are the dialects that can have their own subdirectory of
+// the migrations directory
+var migrationDialects = []Dialect{SQLite, Postgres}
+
+// DialectMigrationsDir returns the directory holding the migrations for
+// dialect: the subdirectory of migrationsDir named after it if there is one,
+// otherwise migrationsDir itself
+func DialectMigrationsDir(migrationsDir string, dialect Dialect) string {
+	dir := filepath.Join(migrationsDir, string(dialect))
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return dir
+	}
+	return migrationsDir
+}
+
+// migrationDirs returns the per-dialect subdirectories of migrationsDir, or
+// migrationsDir itself if it has none
+func migrationDirs(migrationsDir string) []string {
+	var dirs []string
+	for _, dialect := range migrationDialects {
+		if dir := DialectMigrationsDir(migrationsDir, dialect); dir != migrationsDir {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return []string{migrationsDir}
+	}
+	return dirs
+}
+
+// readMigrationDirs reads every directory returned by migrationDirs. With
+// several dialects, file issues are prefixed with the dialect's directory and
+// each dialect must have the same versions under the same names, so a schema
+// change is never made for one database only.
+func readMigrationDirs(migrationsDir string) ([]string, [][]Migration, MigrationValidationError, error) {
+	dirs := migrationDirs(migrationsDir)
+	all := make([][]Migration, len(dirs))
+	var issues MigrationValidationError
+	for i, dir := range dirs {
+		migrations, dirIssues, err := readMigrationFiles(dir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		all[i] = migrations
+		for _, issue := range dirIssues {
+			if len(dirs) > 1 && issue.File != "" {
+				issue.File = filepath.Join(filepath.Base(dir), issue.File)
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	if len(dirs) > 1 {
+		names := make([]map[int]string, len(dirs))
+		for i, migrations := range all {
+			names[i] = make(map[int]string, len(migrations))
+			for _, m := range migrations {
+				names[i][m.Version] = m.Name
+			}
+		}
+		for i, migrations := range all {
+			for _, m := range migrations {
+				for j := range dirs {
+					name, ok := names[j][m.Version]
+					switch {
+					case !ok:
+						issues = append(issues, MigrationIssue{
+							Version: m.Version,
+							Problem: fmt.Sprintf("%s is missing from %s", m.Name, filepath.Base(dirs[j])),
+						})
+					case j > i && name != m.Name:
+						issues = append(issues, MigrationIssue{
+							Version: m.Version,
+							Problem: fmt.Sprintf("named %s in %s but %s in %s", m.Name, filepath.Base(dirs[i]), name, filepath.Base(dirs[j])),
+						})
+					}
+				}
+			}
+		}
+	}
+	return dirs, all, issues, nil
+}
+
+// ValidateMigrations checks the migrations directory for malformed file names,
+// orphaned up/down files, duplicate versions and gaps in sequential numbering.
+// If it has per-dialect subdirectories, each is checked and they must define
+// the same migrations.
+func ValidateMigrations(migrationsDir string) error {
+	_, _, issues, err := readMigrationDirs(migrationsDir)
+	if err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+	return nil
+}
+
+// CreateMigration writes an empty up/down migration pair for name to
+// migrationsDir, or to each of its per-dialect subdirectories, and returns the
+// paths of the created files
+func CreateMigration(migrationsDir, name string, style NumberingStyle) ([]string, error) {
+	slug := strings.Trim(migrationNameRegex.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return nil, fmt.Errorf("migration name %q must contain letters or digits", name)
+	}
+
+	if err := os.MkdirAll(migrationsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create migrations directory: %w", err)
+	}
+
+	// Refuse to add to a directory that is already inconsistent
+	dirs, all, issues, err := readMigrationDirs(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(issues) > 0 {
+		return nil, issues
+	}
+
+	latest := 0
+	for _, migrations := range all {
+		if len(migrations) > 0 && migrations[len(migrations)-1].Version > latest {
+			latest = migrations[len(migrations)-1].Version
+		}
+	}
+
+	var version string
+	switch style {
+	case SequentialNumbering, "":
+		version = fmt.Sprintf("%06d", latest+1)
+	case TimestampNumbering:
+		stamp, _ := strconv.Atoi(time.Now().UTC().Format(timestampVersionFormat))
+		// Two migrations created within the same second still get distinct versions
+		if stamp <= latest {
+			stamp = latest + 1
+		}
+		version = strconv.Itoa(stamp)
+	default:
+		return nil, fmt.Errorf("unknown numbering style %q", style)
+	}
+
+	var created []string
+	for _, dir := range dirs {
+		upPath := filepath.Join(dir, fmt.Sprintf("%s_%s.up.sql", version, slug))
+		downPath := filepath.Join(dir, fmt.Sprintf("%s_%s.down.sql", version, slug))
+
+		if err := writeNewFile(upPath, fmt.Sprintf("-- %s (up)\n", slug)); err != nil {
+			removeFiles(created)
+			return nil, err
+		}
+		created = append(created, upPath)
+		if err := writeNewFile(downPath, fmt.Sprintf("-- %s (down)\n", slug)); err != nil {
+			removeFiles(created)
+			return nil, err
+		}
+		created = append(created, downPath)
+	}
+
+	return created, nil
+}
+
+// removeFiles deletes the files created before a failure
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// writeNewFile creates path with content, failing if the file already exists
+func writeNewFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write migration file: %w", err)
+	}
+	return nil
+}
+
+// readMigrationFiles parses the migrations directory and returns the migrations
+// sorted by version along with any problems found
+func readMigrationFiles(migrationsDir string) ([]Migration, MigrationValidationError, error) {
+	// Check if migrations directory exists
+	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
+		return []Migration{}, nil, nil
+	}
+
+	// Read migration files
+	files, err := os.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var issues MigrationValidationError
+	migrationsMap := make(map[int]*Migration)
+	upFiles := make(map[int]string)
+	downFiles := make(map[int]string)
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		filename := file.Name()
+		if !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+
+		// Parse filename: version_name.up.sql or version_name.down.sql
+		matches := migrationFileRegex.FindStringSubmatch(filename)
+		if matches == nil {
+			issues = append(issues, MigrationIssue{
+				File:    filename,
+				Problem: "file name must match NNN_name.up.sql or NNN_name.down.sql",
+			})
+			continue
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version <= 0 {
+			issues = append(issues, MigrationIssue{File: filename, Problem: "version must be a positive number"})
+			continue
+		}
+		name, direction := matches[2], matches[3]
+
+		seen := upFiles
+		if direction == "down" {
+			seen = downFiles
+		}
+		if existing, ok := seen[version]; ok {
+			issues = append(issues, MigrationIssue{
+				File:    filename,
+				Version: version,
+				Problem: fmt.Sprintf("duplicate version %d (also used by %s)", version, existing),
+			})
+			continue
+		}
+		seen[version] = filename
+
+		// Read file content
+		content, err := os.ReadFile(filepath.Join(migrationsDir, filename))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read migration file %s: %w", filename, err)
+		}
+
+		migration, ok := migrationsMap[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			migrationsMap[version] = migration
+		} else if migration.Name != name {
+			issues = append(issues, MigrationIssue{
+				File:    filename,
+				Version: version,
+				Problem: fmt.Sprintf("duplicate version %d with different names (%s and %s)", version, migration.Name, name),
+			})
+			continue
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	// Convert map to slice and sort by version
+	migrations := make([]Migration, 0, len(migrationsMap))
+	for _, m := range migrationsMap {
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	previous := 0
+	for _, m := range migrations {
+		if _, ok := upFiles[m.Version]; !ok {
+			issues = append(issues, MigrationIssue{File: downFiles[m.Version], Version: m.Version, Problem: "missing matching .up.sql file"})
+		}
+		if _, ok := downFiles[m.Version]; !ok {
+			issues = append(issues, MigrationIssue{File: upFiles[m.Version], Version: m.Version, Problem: "missing matching .down.sql file"})
+		}
+
+		// Timestamp-numbered migrations are expected to have gaps
+		if m.Version < minTimestampVersion {
+			if m.Version != previous+1 {
+				issues = append(issues, MigrationIssue{
+					Version: m.Version,
+					Problem: fmt.Sprintf("gap in sequential numbering (previous version is %d)", previous),
+				})
+			}
+			previous = m.Version
+		}
+	}
+
+	return migrations, issues, nil
+}
@@ -2,16 +2,19 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sort"
-	"strings"
+	"log/slog"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
 )
 
 // Migration represents a database migration
 type Migration struct {
 	Version int
+	Name    string
 	Up      string
 	Down    string
 }
@@ -20,11 +23,34 @@ type Migration struct {
 type MigrationRunner struct {
 	db            *Database
 	migrationsDir string
+	lockTimeout   time.Duration
+	lockLease     time.Duration
+	lockHolder    string
 }
 
 // NewMigrationRunner creates a new migration runner
 func NewMigrationRunner(db *Database, migrationsDir string) *MigrationRunner {
-	return &MigrationRunner{db: db, migrationsDir: migrationsDir}
+	return &MigrationRunner{
+		db:            db,
+		migrationsDir: migrationsDir,
+		lockTimeout:   DefaultMigrationLockTimeout,
+		lockLease:     DefaultMigrationLockLease,
+		lockHolder:    newMigrationLockHolder(),
+	}
+}
+
+// SetLockTimeout sets how long Migrate and Rollback wait for the migration lock
+func (m *MigrationRunner) SetLockTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		m.lockTimeout = timeout
+	}
+}
+
+// SetLockLease sets how long the migration lock stays valid without renewal
+func (m *MigrationRunner) SetLockLease(lease time.Duration) {
+	if lease > 0 {
+		m.lockLease = lease
+	}
 }
 
 // RunMigrations is a convenience function to run all pending migrations
@@ -33,8 +59,17 @@ func RunMigrations(db *Database, migrationsDir string) error {
 	return runner.Migrate(context.Background())
 }
 
-// Migrate runs all pending migrations
+// Migrate runs all pending migrations while holding the migration lock. If
+// the lock is lost, it stops before the next migration and returns
+// ErrMigrationLockLost.
 func (m *MigrationRunner) Migrate(ctx context.Context) error {
+	// Serialize migration runs across instances sharing the database file
+	lock, err := acquireMigrationLock(ctx, m.db, m.lockHolder, m.lockTimeout, m.lockLease)
+	if err != nil {
+		return err
+	}
+	defer m.releaseLock(ctx, lock)
+
 	// Create migrations table if it doesn't exist
 	if err := m.createMigrationsTable(ctx); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
@@ -58,7 +93,7 @@ func (m *MigrationRunner) Migrate(ctx context.Context) error {
 			continue
 		}
 
-		if err := m.runMigration(ctx, migration); err != nil {
+		if err := lock.do(func(ctx context.Context, tx *Tx) error { return m.runMigration(ctx, tx, migration) }); err != nil {
 			return fmt.Errorf("failed to run migration %d: %w", migration.Version, err)
 		}
 	}
@@ -66,8 +101,14 @@ func (m *MigrationRunner) Migrate(ctx context.Context) error {
 	return nil
 }
 
-// Rollback rolls back the last migration
+// Rollback rolls back the last migration while holding the migration lock
 func (m *MigrationRunner) Rollback(ctx context.Context) error {
+	lock, err := acquireMigrationLock(ctx, m.db, m.lockHolder, m.lockTimeout, m.lockLease)
+	if err != nil {
+		return err
+	}
+	defer m.releaseLock(ctx, lock)
+
 	// Create migrations table if it doesn't exist
 	if err := m.createMigrationsTable(ctx); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
@@ -102,25 +143,27 @@ func (m *MigrationRunner) Rollback(ctx context.Context) error {
 		return fmt.Errorf("migration version %d not found", currentVersion)
 	}
 
-	// Run down migration
-	if _, err := m.db.Exec(ctx, migration.Down); err != nil {
-		return fmt.Errorf("failed to rollback migration %d: %w", migration.Version, err)
-	}
+	return lock.do(func(ctx context.Context, tx *Tx) error {
+		// Run down migration
+		if _, err := tx.Exec(ctx, migration.Down); err != nil {
+			return fmt.Errorf("failed to rollback migration %d: %w", migration.Version, err)
+		}
 
-	// Delete migration record
-	if _, err := m.db.Exec(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version); err != nil {
-		return fmt.Errorf("failed to delete migration record: %w", err)
-	}
+		// Delete migration record
+		if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version); err != nil {
+			return fmt.Errorf("failed to delete migration record: %w", err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
 // createMigrationsTable creates the schema_migrations table if it doesn't exist
 func (m *MigrationRunner) createMigrationsTable(ctx context.Context) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version INTEGER PRIMARY KEY,
-			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			version BIGINT PRIMARY KEY,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
 	`
 	if _, err := m.db.Exec(ctx, query); err != nil {
@@ -131,114 +174,56 @@ func (m *MigrationRunner) createMigrationsTable(ctx context.Context) error {
 
 // getCurrentVersion returns the current migration version
 func (m *MigrationRunner) getCurrentVersion(ctx context.Context) (int, error) {
-	var version int
+	// MAX returns NULL on an empty table
+	var version sql.NullInt64
 	err := m.db.QueryRow(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
 	if err != nil {
 		// No migrations yet
-		if strings.Contains(err.Error(), "no rows in result set") {
+		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
 		}
 		return 0, err
 	}
-	return version, nil
+	return int(version.Int64), nil
 }
 
-// loadMigrations loads migration files from the migrations directory
+// loadMigrations loads migration files from the migrations directory, or from
+// its subdirectory for the database's dialect if it has one.
+// Malformed file names, orphaned up/down files, duplicate versions and gaps in
+// sequential numbering are reported as a MigrationValidationError.
 func (m *MigrationRunner) loadMigrations() ([]Migration, error) {
-	migrationsDir := m.migrationsDir
-
-	// Check if migrations directory exists
-	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
-		return []Migration{}, nil
-	}
-
-	// Read migration files
-	files, err := os.ReadDir(migrationsDir)
+	migrations, issues, err := readMigrationFiles(DialectMigrationsDir(m.migrationsDir, m.db.Dialect()))
 	if err != nil {
 		return nil, err
 	}
-
-	// Group migrations by version
-	migrationsMap := make(map[int]Migration)
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		filename := file.Name()
-		if !strings.HasSuffix(filename, ".sql") {
-			continue
-		}
-
-		// Parse filename: version_name.up.sql or version_name.down.sql
-		parts := strings.Split(filename, "_")
-		if len(parts) < 3 {
-			continue
-		}
-
-		var version int
-		if _, err := fmt.Sscanf(parts[0], "%d", &version); err != nil {
-			continue
-		}
-
-		// Read file content
-		content, err := os.ReadFile(filepath.Join(migrationsDir, filename))
-		if err != nil {
-			return nil, fmt.Errorf("failed to read migration file %s: %w", filename, err)
-		}
-
-		// Store up or down migration
-		migration := migrationsMap[version]
-		migration.Version = version
-
-		if strings.Contains(filename, ".up.sql") {
-			migration.Up = string(content)
-		} else if strings.Contains(filename, ".down.sql") {
-			migration.Down = string(content)
-		}
-
-		migrationsMap[version] = migration
-	}
-
-	// Convert map to slice and sort by version
-	migrations := make([]Migration, 0, len(migrationsMap))
-	for _, m := range migrationsMap {
-		migrations = append(migrations, m)
+	if len(issues) > 0 {
+		return nil, issues
 	}
-
-	sort.Slice(migrations, func(i, j int) bool {
-		return migrations[i].Version < migrations[j].Version
-	})
-
 	return migrations, nil
 }
 
-// runMigration runs a single migration
-func (m *MigrationRunner) runMigration(ctx context.Context, migration Migration) error {
-	// Begin transaction
-	tx, err := m.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-
-	// Ensure transaction is rolled back if error occurs
-	defer tx.Rollback()
-
+// runMigration runs a single migration and records it in tx
+func (m *MigrationRunner) runMigration(ctx context.Context, tx *Tx, migration Migration) error {
 	// Execute up migration
-	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+	if _, err := tx.Exec(ctx, migration.Up); err != nil {
 		return err
 	}
 
 	// Record migration
-	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", migration.Version); err != nil {
-		return err
-	}
-
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
+	if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", migration.Version); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// releaseLock releases the migration lock. A failure is only logged: the
+// lock is left to expire with its lease.
+func (m *MigrationRunner) releaseLock(ctx context.Context, lock *migrationLock) {
+	if err := lock.release(context.WithoutCancel(ctx)); err != nil {
+		logger.FromContext(ctx).Error("Failed to release migration lock, it expires with its lease",
+			slog.String("holder", m.lockHolder),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,560 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/config"
+)
+
+// writeTestMigrations writes a small set of migrations to a temporary directory
+func writeTestMigrations(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"000001_create_items.up.sql":     "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);",
+		"000001_create_items.down.sql":   "DROP TABLE items;",
+		"000002_create_widgets.up.sql":   "CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);",
+		"000002_create_widgets.down.sql": "DROP TABLE widgets;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// openTestDB opens another connection pool on an existing database file
+func openTestDB(t *testing.T, dbFile string) *Database {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.SQLite.DBFile = dbFile
+	cfg.SQLite.MaxOpenConnections = 5
+	cfg.SQLite.MaxIdleConnections = 2
+	cfg.SQLite.ConnectionMaxLifetimeSeconds = 300
+
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func countAppliedMigrations(t *testing.T, db *Database) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(context.Background(), "SELECT COUNT(*) FROM schema_migrations").Scan(&count); err != nil {
+		t.Fatalf("Failed to count migrations: %v", err)
+	}
+	return count
+}
+
+func TestMigrate(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	runner := NewMigrationRunner(db, writeTestMigrations(t))
+
+	if err := runner.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	if count := countAppliedMigrations(t, db); count != 2 {
+		t.Errorf("Expected 2 applied migrations, got %d", count)
+	}
+
+	// Running again is a no-op
+	if err := runner.Migrate(ctx); err != nil {
+		t.Fatalf("Second Migrate failed: %v", err)
+	}
+
+	// The lock is released after the run
+	info, err := readMigrationLock(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to read migration lock: %v", err)
+	}
+	if info != nil {
+		t.Errorf("Expected lock to be released, still held by %s", info.Holder)
+	}
+}
+
+func TestMigrateConcurrentInstances(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "shared.db")
+	migrationsDir := writeTestMigrations(t)
+
+	// Each instance gets its own connection pool, like separate processes
+	const instances = 4
+	dbs := make([]*Database, instances)
+	for i := range dbs {
+		dbs[i] = openTestDB(t, dbFile)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, instances)
+	for i, db := range dbs {
+		wg.Add(1)
+		go func(i int, db *Database) {
+			defer wg.Done()
+			runner := NewMigrationRunner(db, migrationsDir)
+			runner.SetLockTimeout(10 * time.Second)
+			errs[i] = runner.Migrate(context.Background())
+		}(i, db)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Instance %d failed to migrate: %v", i, err)
+		}
+	}
+
+	if count := countAppliedMigrations(t, dbs[0]); count != 2 {
+		t.Errorf("Expected each migration to be applied once (2 rows), got %d", count)
+	}
+}
+
+func TestMigrationLockTimeout(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Another instance holds a valid lease
+	other, err := acquireMigrationLock(ctx, db, "other-instance", time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock for other instance: %v", err)
+	}
+	defer other.release(ctx)
+
+	runner := NewMigrationRunner(db, writeTestMigrations(t))
+	runner.SetLockTimeout(300 * time.Millisecond)
+
+	err = runner.Migrate(ctx)
+	if !errors.Is(err, ErrMigrationLockTimeout) {
+		t.Fatalf("Expected ErrMigrationLockTimeout, got %v", err)
+	}
+
+	var exists int
+	db.QueryRow(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'items'").Scan(&exists)
+	if exists != 0 {
+		t.Error("Expected no migrations to run without the lock")
+	}
+}
+
+func TestMigrationLockExpiredLease(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Simulate an instance that crashed while holding the lock
+	if _, _, err := tryAcquireMigrationLock(ctx, db, "crashed-instance", time.Minute); err != nil {
+		t.Fatalf("Failed to create lock: %v", err)
+	}
+	past := time.Now().Add(-time.Second).UnixNano()
+	if _, err := db.Exec(ctx, "UPDATE schema_migrations_lock SET expires_at = ?", past); err != nil {
+		t.Fatalf("Failed to expire lock: %v", err)
+	}
+
+	runner := NewMigrationRunner(db, writeTestMigrations(t))
+	runner.SetLockTimeout(time.Second)
+
+	if err := runner.Migrate(ctx); err != nil {
+		t.Fatalf("Expected expired lock to be taken over, got %v", err)
+	}
+
+	if count := countAppliedMigrations(t, db); count != 2 {
+		t.Errorf("Expected 2 applied migrations, got %d", count)
+	}
+}
+
+func TestMigrationLockRenewal(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	lease := 150 * time.Millisecond
+
+	lock, err := acquireMigrationLock(ctx, db, "renewing-instance", time.Second, lease)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	// Outlive the original lease; renewal must keep the lock valid
+	time.Sleep(3 * lease)
+
+	info, err := readMigrationLock(ctx, db)
+	if err != nil || info == nil {
+		t.Fatalf("Expected lock to be held, got %v (err %v)", info, err)
+	}
+	if !info.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected lease to be renewed, expired at %v", info.ExpiresAt)
+	}
+
+	if err := lock.release(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+}
+
+func TestMigrationLockLost(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	lease := 150 * time.Millisecond
+
+	lock, err := acquireMigrationLock(ctx, db, "losing-instance", time.Second, lease)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.release(ctx)
+
+	// Another instance takes over, e.g. after this one stalled past its lease
+	if _, err := db.Exec(ctx, "UPDATE schema_migrations_lock SET holder = 'other-instance'"); err != nil {
+		t.Fatalf("Failed to take over lock: %v", err)
+	}
+
+	select {
+	case <-lock.ctx.Done():
+	case <-time.After(3 * lease):
+		t.Fatal("Expected the lock to be reported lost")
+	}
+
+	ran := false
+	err = lock.do(func(ctx context.Context, tx *Tx) error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, ErrMigrationLockLost) {
+		t.Errorf("Expected ErrMigrationLockLost, got %v", err)
+	}
+	if ran {
+		t.Error("Expected no statements to run after the lock was lost")
+	}
+}
+
+func TestMigrationOutlastingLease(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	other := openTestDB(t, db.path)
+
+	ctx := context.Background()
+	lease := 150 * time.Millisecond
+
+	lock, err := acquireMigrationLock(ctx, db, "slow-instance", time.Second, lease)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.release(ctx)
+
+	err = lock.do(func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.Exec(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY)"); err != nil {
+			return err
+		}
+		// Another instance tries to take over once the lease has run out
+		time.Sleep(3 * lease)
+		acquired, _, err := tryAcquireMigrationLock(ctx, other, "other-instance", lease)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			t.Error("Expected the lock not to be taken over while a migration runs")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the slow migration to succeed, got %v", err)
+	}
+
+	// The lease was extended, so the lock is still held afterwards
+	if acquired, _, _ := tryAcquireMigrationLock(ctx, other, "other-instance", lease); acquired {
+		t.Error("Expected the lock to still be held after the migration")
+	}
+	if err := lock.lost(); err != nil {
+		t.Errorf("Expected the lock not to be lost, got %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	runner := NewMigrationRunner(db, writeTestMigrations(t))
+
+	if err := runner.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if err := runner.Rollback(ctx); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	if count := countAppliedMigrations(t, db); count != 1 {
+		t.Errorf("Expected 1 applied migration after rollback, got %d", count)
+	}
+}
+
+func TestLoadMigrationsFromRepository(t *testing.T) {
+	for _, dialect := range []Dialect{SQLite, Postgres} {
+		t.Run(string(dialect), func(t *testing.T) {
+			runner := NewMigrationRunner(&Database{dialect: dialect}, "../../migrations")
+
+			migrations, err := runner.loadMigrations()
+			if err != nil {
+				t.Fatalf("Failed to load repository migrations: %v", err)
+			}
+			if len(migrations) == 0 || migrations[0].Version != 1 {
+				t.Fatalf("Expected the initial migration to be loaded, got %+v", migrations)
+			}
+			if migrations[0].Up == "" || migrations[0].Down == "" {
+				t.Error("Expected initial migration to have up and down SQL")
+			}
+		})
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       []string
+		wantProblem string
+	}{
+		{
+			name:  "valid sequential migrations",
+			files: []string{"000001_a.up.sql", "000001_a.down.sql", "000002_b.up.sql", "000002_b.down.sql"},
+		},
+		{
+			name:  "timestamp migrations may have gaps",
+			files: []string{"20250101000000_a.up.sql", "20250101000000_a.down.sql", "20250301000000_b.up.sql", "20250301000000_b.down.sql"},
+		},
+		{
+			name:        "orphaned up file",
+			files:       []string{"000001_a.up.sql"},
+			wantProblem: "missing matching .down.sql file",
+		},
+		{
+			name:        "orphaned down file",
+			files:       []string{"000001_a.down.sql"},
+			wantProblem: "missing matching .up.sql file",
+		},
+		{
+			name:        "duplicate version",
+			files:       []string{"000001_a.up.sql", "000001_a.down.sql", "000001_b.up.sql", "000001_b.down.sql"},
+			wantProblem: "duplicate version 1",
+		},
+		{
+			name:        "gap in sequential numbering",
+			files:       []string{"000001_a.up.sql", "000001_a.down.sql", "000003_c.up.sql", "000003_c.down.sql"},
+			wantProblem: "gap in sequential numbering",
+		},
+		{
+			name:        "malformed file name",
+			files:       []string{"000001_a.up.sql", "000001_a.down.sql", "create_users.sql"},
+			wantProblem: "file name must match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", name, err)
+				}
+			}
+
+			err := ValidateMigrations(dir)
+			if tt.wantProblem == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr MigrationValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected MigrationValidationError, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantProblem) {
+				t.Errorf("Expected error to mention %q, got %v", tt.wantProblem, err)
+			}
+		})
+	}
+}
+
+func TestMigrateRejectsInvalidMigrations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	dir := writeTestMigrations(t)
+	if err := os.Remove(filepath.Join(dir, "000002_create_widgets.down.sql")); err != nil {
+		t.Fatalf("Failed to remove migration: %v", err)
+	}
+
+	err := NewMigrationRunner(db, dir).Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing matching .down.sql file") {
+		t.Fatalf("Expected orphaned migration to be reported, got %v", err)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	t.Run("numbers sequentially", func(t *testing.T) {
+		dir := t.TempDir()
+
+		paths, err := CreateMigration(dir, "Create Users", SequentialNumbering)
+		if err != nil {
+			t.Fatalf("CreateMigration failed: %v", err)
+		}
+		if len(paths) != 2 || filepath.Base(paths[0]) != "000001_create_users.up.sql" || filepath.Base(paths[1]) != "000001_create_users.down.sql" {
+			t.Errorf("Unexpected file names: %v", paths)
+		}
+
+		paths, err = CreateMigration(dir, "add-email-index", SequentialNumbering)
+		if err != nil {
+			t.Fatalf("CreateMigration failed: %v", err)
+		}
+		if filepath.Base(paths[0]) != "000002_add_email_index.up.sql" {
+			t.Errorf("Expected second migration to be 000002, got %s", paths[0])
+		}
+
+		if err := ValidateMigrations(dir); err != nil {
+			t.Errorf("Expected created migrations to be valid, got %v", err)
+		}
+	})
+
+	t.Run("numbers by timestamp", func(t *testing.T) {
+		dir := t.TempDir()
+
+		first, err := CreateMigration(dir, "first", TimestampNumbering)
+		if err != nil {
+			t.Fatalf("CreateMigration failed: %v", err)
+		}
+		second, err := CreateMigration(dir, "second", TimestampNumbering)
+		if err != nil {
+			t.Fatalf("CreateMigration failed: %v", err)
+		}
+
+		if !migrationFileRegex.MatchString(filepath.Base(first[0])) || len(strings.SplitN(filepath.Base(first[0]), "_", 2)[0]) != 14 {
+			t.Errorf("Expected 14 digit timestamp version, got %s", first[0])
+		}
+		if filepath.Base(first[0]) >= filepath.Base(second[0]) {
+			t.Errorf("Expected increasing versions, got %s then %s", first[0], second[0])
+		}
+	})
+
+	t.Run("creates one pair per dialect", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, dialect := range []string{"sqlite", "postgres"} {
+			os.Mkdir(filepath.Join(dir, dialect), 0755)
+		}
+
+		paths, err := CreateMigration(dir, "create users", SequentialNumbering)
+		if err != nil {
+			t.Fatalf("CreateMigration failed: %v", err)
+		}
+		want := []string{
+			filepath.Join(dir, "sqlite", "000001_create_users.up.sql"),
+			filepath.Join(dir, "sqlite", "000001_create_users.down.sql"),
+			filepath.Join(dir, "postgres", "000001_create_users.up.sql"),
+			filepath.Join(dir, "postgres", "000001_create_users.down.sql"),
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("Expected %v, got %v", want, paths)
+		}
+		if err := ValidateMigrations(dir); err != nil {
+			t.Errorf("Expected created migrations to be valid, got %v", err)
+		}
+	})
+
+	t.Run("rejects empty names", func(t *testing.T) {
+		if _, err := CreateMigration(t.TempDir(), "  --  ", SequentialNumbering); err == nil {
+			t.Error("Expected error for name without letters or digits")
+		}
+	})
+
+	t.Run("refuses inconsistent directory", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "000001_a.up.sql"), []byte(""), 0644)
+
+		if _, err := CreateMigration(dir, "next", SequentialNumbering); err == nil {
+			t.Error("Expected error when directory has orphaned migrations")
+		}
+	})
+}
+
+func TestDialectMigrations(t *testing.T) {
+	writeFiles := func(t *testing.T, dir string, files ...string) {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		for _, name := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	t.Run("resolves the dialect subdirectory", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, filepath.Join(dir, "sqlite"))
+
+		if got := DialectMigrationsDir(dir, SQLite); got != filepath.Join(dir, "sqlite") {
+			t.Errorf("Expected the sqlite subdirectory, got %s", got)
+		}
+		if got := DialectMigrationsDir(dir, Postgres); got != dir {
+			t.Errorf("Expected a fallback to the directory itself, got %s", got)
+		}
+	})
+
+	t.Run("runs the migrations of its dialect", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		dir := t.TempDir()
+		sqliteDir := filepath.Join(dir, "sqlite")
+		writeFiles(t, sqliteDir)
+		os.WriteFile(filepath.Join(sqliteDir, "000001_widgets.up.sql"), []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);"), 0644)
+		os.WriteFile(filepath.Join(sqliteDir, "000001_widgets.down.sql"), []byte("DROP TABLE widgets;"), 0644)
+		writeFiles(t, filepath.Join(dir, "postgres"), "000001_widgets.up.sql", "000001_widgets.down.sql")
+
+		if err := NewMigrationRunner(db, dir).Migrate(context.Background()); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if _, err := db.Exec(context.Background(), "INSERT INTO widgets (id) VALUES (1)"); err != nil {
+			t.Errorf("Expected the sqlite migration to have run: %v", err)
+		}
+	})
+
+	t.Run("dialects must define the same migrations", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, filepath.Join(dir, "sqlite"), "000001_a.up.sql", "000001_a.down.sql", "000002_b.up.sql", "000002_b.down.sql")
+		writeFiles(t, filepath.Join(dir, "postgres"), "000001_c.up.sql", "000001_c.down.sql")
+
+		err := ValidateMigrations(dir)
+		for _, want := range []string{"b is missing from postgres", "named a in sqlite but c in postgres"} {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %q, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("the shipped migrations are consistent", func(t *testing.T) {
+		if err := ValidateMigrations("../../migrations"); err != nil {
+			t.Errorf("Expected valid migrations, got %v", err)
+		}
+	})
+}
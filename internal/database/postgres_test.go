@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/database/databasetest"
+)
+
+// setupPostgresDB connects to a fresh schema in the TEST_POSTGRES_URL
+// database, skipping the test if no URL is set
+func setupPostgresDB(t *testing.T) *Database {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Database.Driver = "postgres"
+	cfg.Postgres.URL = databasetest.PostgresURL(t)
+	cfg.Postgres.MaxOpenConnections = 5
+	cfg.Postgres.MaxIdleConnections = 2
+	cfg.Postgres.ConnectionMaxLifetimeSeconds = 300
+
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgres(t *testing.T) {
+	db := setupPostgresDB(t)
+	ctx := context.Background()
+
+	if db.Dialect() != Postgres {
+		t.Fatalf("Expected the postgres dialect, got %s", db.Dialect())
+	}
+
+	t.Run("runs the shipped migrations", func(t *testing.T) {
+		runner := NewMigrationRunner(db, "../../migrations")
+		if err := runner.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		if err := runner.Rollback(ctx); err != nil {
+			t.Fatalf("Rollback failed: %v", err)
+		}
+		if err := runner.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate after rollback failed: %v", err)
+		}
+	})
+
+	t.Run("rebinds placeholders and maps errors", func(t *testing.T) {
+		var id int64
+		err := db.WithTx(ctx, nil, func(tx *Tx) error {
+			query, args := Insert("users").Set("username", "ada").Set("email", "ada@example.com").Returning("id").Build()
+			return tx.QueryRow(ctx, query, args...).Scan(&id)
+		})
+		if err != nil || id == 0 {
+			t.Fatalf("Insert failed: %v (id %d)", err, id)
+		}
+
+		query, args := Insert("users").Set("username", "other").Set("email", "ada@example.com").Build()
+		_, err = db.Exec(ctx, query, args...)
+		var conflict *ConflictError
+		if !errors.As(MapError(err), &conflict) || conflict.Constraint != "unique" || conflict.Columns[0] != "email" {
+			t.Errorf("Expected a unique conflict on email, got %v", MapError(err))
+		}
+
+		query, args = Select("username").From("users").WhereExpr(And(Eq("id", id), Like("email", "%@example.com"))).Offset(0).Build()
+		var username string
+		if err := db.QueryRow(ctx, query, args...).Scan(&username); err != nil || username != "ada" {
+			t.Errorf("Select failed: %v (%q)", err, username)
+		}
+	})
+
+	t.Run("rolls back transactions", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		err := db.WithTx(ctx, nil, func(tx *Tx) error {
+			if _, err := tx.Exec(ctx, "INSERT INTO users (username, email) VALUES (?, ?)", "alan", "alan@example.com"); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Expected the transaction error, got %v", err)
+		}
+		var n int
+		if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE username = ?", "alan").Scan(&n); err != nil || n != 0 {
+			t.Errorf("Expected the insert to be rolled back, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("file backups are unsupported", func(t *testing.T) {
+		_, err := db.Backup(ctx, t.TempDir()+"/backup.db", BackupOptions{})
+		if !errors.Is(err, ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,403 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Expr is a SQL condition with its ? placeholder arguments. Identifiers are
+// written into the SQL as given, so they must never come from user input;
+// values always travel as arguments.
+type Expr struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Raw creates a condition from SQL and its arguments
+func Raw(sql string, args ...interface{}) Expr {
+	return Expr{SQL: sql, Args: args}
+}
+
+// Eq creates a "column = ?" condition
+func Eq(column string, value interface{}) Expr {
+	return Raw(column+" = ?", value)
+}
+
+// Ne creates a "column != ?" condition
+func Ne(column string, value interface{}) Expr {
+	return Raw(column+" != ?", value)
+}
+
+// Gt creates a "column > ?" condition
+func Gt(column string, value interface{}) Expr {
+	return Raw(column+" > ?", value)
+}
+
+// Gte creates a "column >= ?" condition
+func Gte(column string, value interface{}) Expr {
+	return Raw(column+" >= ?", value)
+}
+
+// Lt creates a "column < ?" condition
+func Lt(column string, value interface{}) Expr {
+	return Raw(column+" < ?", value)
+}
+
+// Lte creates a "column <= ?" condition
+func Lte(column string, value interface{}) Expr {
+	return Raw(column+" <= ?", value)
+}
+
+// Like creates a "column LIKE ?" condition
+func Like(column, pattern string) Expr {
+	return Raw(column+" LIKE ?", pattern)
+}
+
+// In creates a "column IN (?, ...)" condition; an empty list matches nothing
+func In(column string, values ...interface{}) Expr {
+	if len(values) == 0 {
+		return Raw("1 = 0")
+	}
+	return Raw(column+" IN ("+placeholders(len(values))+")", values...)
+}
+
+// And joins conditions with AND
+func And(exprs ...Expr) Expr {
+	return join(" AND ", exprs)
+}
+
+// Or joins conditions with OR
+func Or(exprs ...Expr) Expr {
+	return join(" OR ", exprs)
+}
+
+// join combines non-empty conditions, parenthesizing each one
+func join(sep string, exprs []Expr) Expr {
+	var nonEmpty []Expr
+	for _, e := range exprs {
+		if e.SQL != "" {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+
+	parts := make([]string, len(nonEmpty))
+	var args []interface{}
+	for i, e := range nonEmpty {
+		parts[i] = "(" + e.SQL + ")"
+		args = append(args, e.Args...)
+	}
+	return Expr{SQL: strings.Join(parts, sep), Args: args}
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// SelectBuilder builds a parameterized SELECT statement
+type SelectBuilder struct {
+	columns []string
+	from    string
+	joins   []Expr
+	where   []Expr
+	groupBy []string
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+// Select starts a SELECT of the given columns; no columns selects *
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1, offset: -1}
+}
+
+// From sets the table to select from
+func (b *SelectBuilder) From(table string) *SelectBuilder {
+	b.from = table
+	return b
+}
+
+// Join adds an inner join, e.g. Join("teams t", "t.id = users.team_id")
+func (b *SelectBuilder) Join(table, on string, args ...interface{}) *SelectBuilder {
+	b.joins = append(b.joins, Raw("JOIN "+table+" ON "+on, args...))
+	return b
+}
+
+// LeftJoin adds a left outer join
+func (b *SelectBuilder) LeftJoin(table, on string, args ...interface{}) *SelectBuilder {
+	b.joins = append(b.joins, Raw("LEFT JOIN "+table+" ON "+on, args...))
+	return b
+}
+
+// Where adds a condition; multiple conditions are combined with AND
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	return b.WhereExpr(Raw(cond, args...))
+}
+
+// WhereExpr adds a condition built from Expr helpers
+func (b *SelectBuilder) WhereExpr(e Expr) *SelectBuilder {
+	if e.SQL != "" {
+		b.where = append(b.where, e)
+	}
+	return b
+}
+
+// GroupBy adds GROUP BY expressions
+func (b *SelectBuilder) GroupBy(exprs ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, exprs...)
+	return b
+}
+
+// OrderBy adds ORDER BY expressions, e.g. OrderBy("created_at DESC", "id DESC")
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, exprs...)
+	return b
+}
+
+// Limit sets the maximum number of rows; a negative limit removes it
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset sets the number of rows to skip; a negative offset removes it
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Clone returns an independent copy of the builder
+func (b *SelectBuilder) Clone() *SelectBuilder {
+	c := *b
+	c.columns = append([]string(nil), b.columns...)
+	c.joins = append([]Expr(nil), b.joins...)
+	c.where = append([]Expr(nil), b.where...)
+	c.groupBy = append([]string(nil), b.groupBy...)
+	c.orderBy = append([]string(nil), b.orderBy...)
+	return &c
+}
+
+// Count returns a copy of the builder that counts the matching rows, without
+// ordering, limit or offset
+func (b *SelectBuilder) Count() *SelectBuilder {
+	c := b.Clone()
+	c.columns = []string{"COUNT(*)"}
+	c.orderBy = nil
+	c.limit, c.offset = -1, -1
+	return c
+}
+
+// Build returns the SQL statement and its arguments
+func (b *SelectBuilder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	var args []interface{}
+
+	columns := "*"
+	if len(b.columns) > 0 {
+		columns = strings.Join(b.columns, ", ")
+	}
+	sb.WriteString("SELECT " + columns + " FROM " + b.from)
+
+	for _, j := range b.joins {
+		sb.WriteString(" " + j.SQL)
+		args = append(args, j.Args...)
+	}
+
+	if len(b.where) > 0 {
+		where := And(b.where...)
+		sb.WriteString(" WHERE " + where.SQL)
+		args = append(args, where.Args...)
+	}
+	if len(b.groupBy) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(b.groupBy, ", "))
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+
+	// SQLite requires a LIMIT before OFFSET; PostgreSQL rejects a negative
+	// one, so an offset without a limit gets the largest possible limit
+	if b.limit >= 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, b.limit)
+	} else if b.offset >= 0 {
+		sb.WriteString(" LIMIT ?")
+		args = append(args, int64(math.MaxInt64))
+	}
+	if b.offset >= 0 {
+		sb.WriteString(" OFFSET ?")
+		args = append(args, b.offset)
+	}
+
+	return sb.String(), args
+}
+
+// InsertBuilder builds a parameterized INSERT statement. Upserts and
+// RETURNING use the syntax shared by SQLite and PostgreSQL.
+type InsertBuilder struct {
+	table     string
+	columns   []string
+	values    []interface{}
+	conflict  []string
+	update    []string
+	nothing   bool
+	returning []string
+}
+
+// Insert starts an INSERT into table
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+// Set adds a column and its value
+func (b *InsertBuilder) Set(column string, value interface{}) *InsertBuilder {
+	b.columns = append(b.columns, column)
+	b.values = append(b.values, value)
+	return b
+}
+
+// OnConflictUpdate turns the insert into an upsert: when a row with the same
+// values in the conflict columns exists, its given columns are overwritten
+// with the inserted values. The conflict columns must be covered by a primary
+// key or unique index.
+func (b *InsertBuilder) OnConflictUpdate(conflict []string, columns ...string) *InsertBuilder {
+	b.conflict, b.update, b.nothing = conflict, columns, false
+	return b
+}
+
+// OnConflictDoNothing skips the insert when a row with the same values in the
+// conflict columns exists
+func (b *InsertBuilder) OnConflictDoNothing(conflict ...string) *InsertBuilder {
+	b.conflict, b.update, b.nothing = conflict, nil, true
+	return b
+}
+
+// Returning makes the statement return the given columns of the inserted
+// row; run it with QueryRow inside a transaction
+func (b *InsertBuilder) Returning(columns ...string) *InsertBuilder {
+	b.returning = columns
+	return b
+}
+
+// Build returns the SQL statement and its arguments
+func (b *InsertBuilder) Build() (string, []interface{}) {
+	var sb strings.Builder
+	if len(b.columns) == 0 {
+		sb.WriteString("INSERT INTO " + b.table + " DEFAULT VALUES")
+	} else {
+		fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES (%s)", b.table, strings.Join(b.columns, ", "), placeholders(len(b.columns)))
+	}
+
+	if len(b.conflict) > 0 && (b.nothing || len(b.update) > 0) {
+		sb.WriteString(" ON CONFLICT (" + strings.Join(b.conflict, ", ") + ")")
+		if b.nothing {
+			sb.WriteString(" DO NOTHING")
+		} else {
+			sets := make([]string, len(b.update))
+			for i, column := range b.update {
+				sets[i] = column + " = excluded." + column
+			}
+			sb.WriteString(" DO UPDATE SET " + strings.Join(sets, ", "))
+		}
+	}
+
+	if len(b.returning) > 0 {
+		sb.WriteString(" RETURNING " + strings.Join(b.returning, ", "))
+	}
+	return sb.String(), b.values
+}
+
+// UpdateBuilder builds a parameterized UPDATE statement
+type UpdateBuilder struct {
+	table string
+	sets  []Expr
+	where []Expr
+}
+
+// Update starts an UPDATE of table
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+// Set adds a column assignment
+func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	return b.SetExpr(column, Raw("?", value))
+}
+
+// SetExpr assigns a SQL expression to a column, e.g.
+// SetExpr("version", Raw("version + 1"))
+func (b *UpdateBuilder) SetExpr(column string, e Expr) *UpdateBuilder {
+	b.sets = append(b.sets, Raw(column+" = "+e.SQL, e.Args...))
+	return b
+}
+
+// Where adds a condition; multiple conditions are combined with AND
+func (b *UpdateBuilder) Where(cond string, args ...interface{}) *UpdateBuilder {
+	return b.WhereExpr(Raw(cond, args...))
+}
+
+// WhereExpr adds a condition built from Expr helpers
+func (b *UpdateBuilder) WhereExpr(e Expr) *UpdateBuilder {
+	if e.SQL != "" {
+		b.where = append(b.where, e)
+	}
+	return b
+}
+
+// Build returns the SQL statement and its arguments
+func (b *UpdateBuilder) Build() (string, []interface{}) {
+	sets := make([]string, len(b.sets))
+	var args []interface{}
+	for i, set := range b.sets {
+		sets[i] = set.SQL
+		args = append(args, set.Args...)
+	}
+
+	query := "UPDATE " + b.table + " SET " + strings.Join(sets, ", ")
+	if len(b.where) > 0 {
+		where := And(b.where...)
+		query += " WHERE " + where.SQL
+		args = append(args, where.Args...)
+	}
+	return query, args
+}
+
+// DeleteBuilder builds a parameterized DELETE statement
+type DeleteBuilder struct {
+	table string
+	where []Expr
+}
+
+// Delete starts a DELETE from table
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+// Where adds a condition; multiple conditions are combined with AND
+func (b *DeleteBuilder) Where(cond string, args ...interface{}) *DeleteBuilder {
+	return b.WhereExpr(Raw(cond, args...))
+}
+
+// WhereExpr adds a condition built from Expr helpers
+func (b *DeleteBuilder) WhereExpr(e Expr) *DeleteBuilder {
+	if e.SQL != "" {
+		b.where = append(b.where, e)
+	}
+	return b
+}
+
+// Build returns the SQL statement and its arguments
+func (b *DeleteBuilder) Build() (string, []interface{}) {
+	query := "DELETE FROM " + b.table
+	var args []interface{}
+	if len(b.where) > 0 {
+		where := And(b.where...)
+		query += " WHERE " + where.SQL
+		args = where.Args
+	}
+	return query, args
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *SelectBuilder
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:    "all columns",
+			builder: Select().From("users"),
+			wantSQL: "SELECT * FROM users",
+		},
+		{
+			name: "where, order, limit and offset",
+			builder: Select("id", "email").From("users").
+				Where("email LIKE ?", "%@example.com").
+				WhereExpr(Gte("id", 10)).
+				OrderBy("created_at DESC", "id DESC").
+				Limit(20).Offset(40),
+			wantSQL:  "SELECT id, email FROM users WHERE (email LIKE ?) AND (id >= ?) ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?",
+			wantArgs: []interface{}{"%@example.com", 10, 20, 40},
+		},
+		{
+			name: "joins and grouping",
+			builder: Select("t.name", "COUNT(u.id)").From("teams t").
+				LeftJoin("users u", "u.team_id = t.id AND u.active = ?", true).
+				GroupBy("t.name"),
+			wantSQL:  "SELECT t.name, COUNT(u.id) FROM teams t LEFT JOIN users u ON u.team_id = t.id AND u.active = ? GROUP BY t.name",
+			wantArgs: []interface{}{true},
+		},
+		{
+			name:     "composed conditions",
+			builder:  Select("id").From("users").WhereExpr(Or(Eq("username", "ada"), And(Like("email", "ada%"), In("id", 1, 2, 3)))),
+			wantSQL:  "SELECT id FROM users WHERE (username = ?) OR ((email LIKE ?) AND (id IN (?, ?, ?)))",
+			wantArgs: []interface{}{"ada", "ada%", 1, 2, 3},
+		},
+		{
+			name:    "empty IN matches nothing",
+			builder: Select("id").From("users").WhereExpr(In("id")),
+			wantSQL: "SELECT id FROM users WHERE 1 = 0",
+		},
+		{
+			name:     "offset without limit",
+			builder:  Select("id").From("users").Offset(5),
+			wantSQL:  "SELECT id FROM users LIMIT ? OFFSET ?",
+			wantArgs: []interface{}{int64(math.MaxInt64), 5},
+		},
+		{
+			name:     "count drops ordering and paging",
+			builder:  Select("id").From("users").Where("id > ?", 1).OrderBy("id").Limit(10).Count(),
+			wantSQL:  "SELECT COUNT(*) FROM users WHERE id > ?",
+			wantArgs: []interface{}{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := tt.builder.Build()
+			if sql != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+
+	t.Run("clone is independent", func(t *testing.T) {
+		base := Select("id").From("users")
+		clone := base.Clone().Where("id = ?", 1)
+		if sql, _ := base.Build(); sql != "SELECT id FROM users" {
+			t.Errorf("Expected base to be unchanged, got %q", sql)
+		}
+		if sql, _ := clone.Build(); sql != "SELECT id FROM users WHERE id = ?" {
+			t.Errorf("Unexpected clone SQL %q", sql)
+		}
+	})
+}
+
+func TestWriteBuilders(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func() (string, []interface{})
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "insert",
+			build:    Insert("users").Set("username", "ada").Set("email", "ada@example.com").Build,
+			wantSQL:  "INSERT INTO users (username, email) VALUES (?, ?)",
+			wantArgs: []interface{}{"ada", "ada@example.com"},
+		},
+		{
+			name:     "upsert",
+			build:    Insert("users").Set("username", "ada").Set("email", "ada@example.com").OnConflictUpdate([]string{"username"}, "email").Build,
+			wantSQL:  "INSERT INTO users (username, email) VALUES (?, ?) ON CONFLICT (username) DO UPDATE SET email = excluded.email",
+			wantArgs: []interface{}{"ada", "ada@example.com"},
+		},
+		{
+			name:     "insert or ignore returning",
+			build:    Insert("tags").Set("name", "go").OnConflictDoNothing("name").Returning("id").Build,
+			wantSQL:  "INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING RETURNING id",
+			wantArgs: []interface{}{"go"},
+		},
+		{
+			name:    "insert defaults",
+			build:   Insert("events").Build,
+			wantSQL: "INSERT INTO events DEFAULT VALUES",
+		},
+		{
+			name:     "update",
+			build:    Update("users").Set("email", "new@example.com").Where("id = ?", 7).Build,
+			wantSQL:  "UPDATE users SET email = ? WHERE id = ?",
+			wantArgs: []interface{}{"new@example.com", 7},
+		},
+		{
+			name:     "update with expression",
+			build:    Update("users").Set("email", "new@example.com").SetExpr("version", Raw("version + ?", 1)).Where("id = ?", 7).Build,
+			wantSQL:  "UPDATE users SET email = ?, version = version + ? WHERE id = ?",
+			wantArgs: []interface{}{"new@example.com", 1, 7},
+		},
+		{
+			name:     "delete",
+			build:    Delete("users").WhereExpr(And(Eq("id", 7), Ne("username", "admin"))).Build,
+			wantSQL:  "DELETE FROM users WHERE (id = ?) AND (username != ?)",
+			wantArgs: []interface{}{7, "admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := tt.build()
+			if sql != tt.wantSQL {
+				t.Errorf("SQL = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBuilderAgainstSQLite(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	query, args := Insert("counters").Set("id", 2).Set("value", 5).Build()
+	if _, err := db.Exec(ctx, query, args...); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	query, args = Select("SUM(value)").From("counters").WhereExpr(In("id", 1, 2)).Build()
+	var sum int
+	if err := db.QueryRow(ctx, query, args...).Scan(&sum); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if sum != 5 {
+		t.Errorf("Expected sum 5, got %d", sum)
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/tracing"
+)
+
+const (
+	// maxQueryFingerprints bounds the metrics kept; further fingerprints are
+	// counted under otherFingerprint
+	maxQueryFingerprints = 1000
+	otherFingerprint     = "other"
+)
+
+// QueryDurationBuckets are the upper bounds of the query duration histogram
+var QueryDurationBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// QueryStats holds the metrics recorded for one query fingerprint
+type QueryStats struct {
+	Fingerprint   string
+	Count         uint64
+	Errors        uint64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+	// Buckets counts queries per histogram bucket: Buckets[i] counts durations
+	// up to QueryDurationBuckets[i], and the last entry counts slower queries
+	Buckets []uint64
+}
+
+// queryObserver times statements, logs slow ones and aggregates metrics. It is
+// shared by a Database and the transactions started from it.
+type queryObserver struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	stats     map[string]*QueryStats
+}
+
+// newQueryObserver creates an observer that logs statements slower than threshold
+func newQueryObserver(threshold time.Duration) *queryObserver {
+	return &queryObserver{
+		threshold: threshold,
+		stats:     make(map[string]*QueryStats),
+	}
+}
+
+// observe records a statement that started at start and finished with err
+func (o *queryObserver) observe(ctx context.Context, query string, args []interface{}, start time.Time, err error) {
+	duration := time.Since(start)
+	fingerprint := Fingerprint(query)
+
+	o.mu.Lock()
+	stats, ok := o.stats[fingerprint]
+	if !ok {
+		if len(o.stats) >= maxQueryFingerprints {
+			fingerprint = otherFingerprint
+			stats = o.stats[fingerprint]
+		}
+		if stats == nil {
+			stats = &QueryStats{Fingerprint: fingerprint, Buckets: make([]uint64, len(QueryDurationBuckets)+1)}
+			o.stats[fingerprint] = stats
+		}
+	}
+	stats.Count++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalDuration += duration
+	if duration > stats.MaxDuration {
+		stats.MaxDuration = duration
+	}
+	stats.Buckets[sort.Search(len(QueryDurationBuckets), func(i int) bool { return duration <= QueryDurationBuckets[i] })]++
+	threshold := o.threshold
+	o.mu.Unlock()
+
+	if threshold > 0 && duration >= threshold {
+		attrs := []any{
+			slog.String("fingerprint", fingerprint),
+			slog.Float64("duration_ms", float64(duration.Microseconds())/1000),
+			// Argument values may hold personal data or secrets, so only their types are logged
+			slog.Any("args", redactArgs(args)),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.FromContext(ctx).Named("database").Warn("Slow query", attrs...)
+	}
+}
+
+// snapshot returns a copy of the metrics, slowest fingerprints by total time first
+func (o *queryObserver) snapshot() []QueryStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	result := make([]QueryStats, 0, len(o.stats))
+	for _, stats := range o.stats {
+		copied := *stats
+		copied.Buckets = append([]uint64(nil), stats.Buckets...)
+		result = append(result, copied)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalDuration != result[j].TotalDuration {
+			return result[i].TotalDuration > result[j].TotalDuration
+		}
+		return result[i].Fingerprint < result[j].Fingerprint
+	})
+	return result
+}
+
+// redactArgs replaces argument values with their types
+func redactArgs(args []interface{}) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			redacted[i] = "null"
+			continue
+		}
+		redacted[i] = fmt.Sprintf("%T", arg)
+	}
+	return redacted
+}
+
+var (
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numberLiteralPattern = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	placeholderPattern   = regexp.MustCompile(`\$\d+|@\w+`)
+	valueListPattern     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	whitespacePattern    = regexp.MustCompile(`\s+`)
+
+	// namedPattern matches :name placeholders but not Postgres casts such as
+	// id::int, keeping the character before the colon in group 1
+	namedPattern = regexp.MustCompile(`(^|[^:]):\w+`)
+)
+
+// Fingerprint normalizes a query so that statements differing only in literal
+// values, placeholder style or whitespace share metrics: literals and
+// placeholders become ?, lists of them collapse to (?), and whitespace is
+// collapsed
+func Fingerprint(query string) string {
+	fp := stringLiteralPattern.ReplaceAllString(query, "?")
+	fp = placeholderPattern.ReplaceAllString(fp, "?")
+	fp = namedPattern.ReplaceAllString(fp, "${1}?")
+	fp = numberLiteralPattern.ReplaceAllString(fp, "?")
+	fp = valueListPattern.ReplaceAllString(fp, "(?)")
+	fp = whitespacePattern.ReplaceAllString(fp, " ")
+	return strings.TrimSpace(fp)
+}
+
+// SetSlowQueryThreshold sets the duration above which statements are logged;
+// zero disables slow query logging
+func (d *Database) SetSlowQueryThreshold(threshold time.Duration) {
+	d.observer.mu.Lock()
+	defer d.observer.mu.Unlock()
+	d.observer.threshold = threshold
+}
+
+// QueryMetrics returns per-fingerprint statement metrics, slowest by total time first
+func (d *Database) QueryMetrics() []QueryStats {
+	return d.observer.snapshot()
+}
+
+// startQuerySpan starts a client span for a statement, named after its first
+// keyword. Only the fingerprint of the query is recorded, never argument
+// values.
+func startQuerySpan(ctx context.Context, dialect Dialect, query string) *tracing.Span {
+	system := string(dialect)
+	if dialect == Postgres {
+		system = "postgresql"
+	}
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+	if operation == "" {
+		operation = "query"
+	}
+	_, span := tracing.Start(ctx, operation, tracing.WithKind(tracing.KindClient))
+	if span.IsRecording() {
+		span.SetAttributes(
+			tracing.String("db.system", system),
+			tracing.String("db.operation.name", operation),
+			tracing.String("db.query.text", Fingerprint(query)),
+		)
+	}
+	return span
+}
+
+// endQuerySpan ends a statement span, failed if err is set
+func endQuerySpan(span *tracing.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/tracing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"placeholders unchanged", "SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = ?"},
+		{"string literals", "SELECT * FROM users WHERE email = 'a@b.c' AND name = 'O''Brien'", "SELECT * FROM users WHERE email = ? AND name = ?"},
+		{"number literals", "SELECT * FROM users LIMIT 10 OFFSET 2.5", "SELECT * FROM users LIMIT ? OFFSET ?"},
+		{"identifiers with digits", "SELECT * FROM table2 WHERE col_1 = 3", "SELECT * FROM table2 WHERE col_1 = ?"},
+		{"numbered placeholders", "SELECT * FROM users WHERE id = $1", "SELECT * FROM users WHERE id = ?"},
+		{"named placeholders", "SELECT * FROM users WHERE id = :id AND name = @name", "SELECT * FROM users WHERE id = ? AND name = ?"},
+		{"postgres casts", "SELECT id::int, created_at::date FROM users WHERE id = $1::bigint", "SELECT id::int, created_at::date FROM users WHERE id = ?::bigint"},
+		{"value lists", "SELECT * FROM users WHERE id IN (?, ?,?)", "SELECT * FROM users WHERE id IN (?)"},
+		{"whitespace", "SELECT *\n\tFROM   users ", "SELECT * FROM users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Fingerprint(tt.query); got != tt.want {
+				t.Errorf("Fingerprint(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		db.QueryRow(ctx, "SELECT value FROM counters WHERE id = ?", i)
+	}
+	db.Exec(ctx, "INSERT INTO missing_table VALUES (1)")
+	db.WithTx(ctx, nil, func(tx *Tx) error {
+		_, err := tx.Exec(ctx, "UPDATE counters SET value = 5 WHERE id = 1")
+		return err
+	})
+
+	byFingerprint := make(map[string]QueryStats)
+	for _, stats := range db.QueryMetrics() {
+		byFingerprint[stats.Fingerprint] = stats
+	}
+
+	t.Run("groups by fingerprint", func(t *testing.T) {
+		stats := byFingerprint["SELECT value FROM counters WHERE id = ?"]
+		if stats.Count != 3 {
+			t.Errorf("Expected 3 executions, got %d", stats.Count)
+		}
+		var bucketed uint64
+		for _, n := range stats.Buckets {
+			bucketed += n
+		}
+		if bucketed != stats.Count {
+			t.Errorf("Expected histogram to hold %d observations, got %d", stats.Count, bucketed)
+		}
+		if stats.MaxDuration <= 0 || stats.TotalDuration < stats.MaxDuration {
+			t.Errorf("Unexpected durations: total %v, max %v", stats.TotalDuration, stats.MaxDuration)
+		}
+	})
+
+	t.Run("counts errors", func(t *testing.T) {
+		if stats := byFingerprint["INSERT INTO missing_table VALUES (?)"]; stats.Errors != 1 {
+			t.Errorf("Expected 1 error, got %d", stats.Errors)
+		}
+	})
+
+	t.Run("includes transaction statements", func(t *testing.T) {
+		if stats := byFingerprint["UPDATE counters SET value = ? WHERE id = ?"]; stats.Count != 1 {
+			t.Errorf("Expected 1 execution, got %d", stats.Count)
+		}
+	})
+}
+
+// spanRecorder is a tracing processor that keeps the spans it is given
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *spanRecorder) OnEnd(span tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func TestQuerySpans(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	rec := &spanRecorder{}
+	previous := tracing.CurrentTracer()
+	tracing.SetTracer(tracing.NewTracer(1, rec))
+	defer tracing.SetTracer(previous)
+
+	ctx, parent := tracing.Start(context.Background(), "request")
+	var value int
+	db.QueryRow(ctx, "SELECT value FROM counters WHERE id = ?", 99).Scan(&value)
+	db.Exec(ctx, "INSERT INTO missing_table VALUES ('secret')")
+	db.WithTx(ctx, nil, func(tx *Tx) error {
+		_, err := tx.Exec(ctx, "UPDATE counters SET value = 5 WHERE id = 1")
+		return err
+	})
+	parent.End()
+
+	if len(rec.spans) != 4 {
+		t.Fatalf("Expected 3 statement spans and the parent, got %d", len(rec.spans))
+	}
+	for i, want := range []struct {
+		name, text string
+		status     tracing.StatusCode
+	}{
+		{"SELECT", "SELECT value FROM counters WHERE id = ?", tracing.StatusUnset},
+		{"INSERT", "INSERT INTO missing_table VALUES (?)", tracing.StatusError},
+		{"UPDATE", "UPDATE counters SET value = ? WHERE id = ?", tracing.StatusUnset},
+	} {
+		span := rec.spans[i]
+		if span.Name != want.name || span.Kind != tracing.KindClient || span.Parent != parent.SpanContext().SpanID || span.Status != want.status {
+			t.Errorf("Unexpected span %d: %+v", i, span)
+		}
+		attrs := make(map[string]interface{})
+		for _, a := range span.Attributes {
+			attrs[a.Key] = a.Value
+		}
+		if attrs["db.system"] != "sqlite" || attrs["db.query.text"] != want.text {
+			t.Errorf("Expected the fingerprint without values on span %d, got %v", i, attrs)
+		}
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(original)
+
+	ctx := logger.NewContextWithRequestID(context.Background(), "req-123")
+
+	t.Run("logs statements above the threshold", func(t *testing.T) {
+		buf.Reset()
+		db.SetSlowQueryThreshold(time.Nanosecond)
+		db.QueryRow(ctx, "SELECT value FROM counters WHERE id = ? AND ? != ''", 1, "secret-value")
+
+		out := buf.String()
+		if !strings.Contains(out, `"msg":"Slow query"`) {
+			t.Fatalf("Expected slow query log, got %q", out)
+		}
+		if !strings.Contains(out, `"request_id":"req-123"`) {
+			t.Errorf("Expected request ID from context, got %q", out)
+		}
+		if !strings.Contains(out, `"fingerprint":"SELECT value FROM counters WHERE id = ? AND ? != ?"`) {
+			t.Errorf("Expected fingerprint, got %q", out)
+		}
+		if strings.Contains(out, "secret-value") {
+			t.Errorf("Expected arguments to be redacted, got %q", out)
+		}
+		if !strings.Contains(out, `"args":["int","string"]`) {
+			t.Errorf("Expected argument types, got %q", out)
+		}
+	})
+
+	t.Run("does not log when disabled", func(t *testing.T) {
+		buf.Reset()
+		db.SetSlowQueryThreshold(0)
+		db.QueryRow(ctx, "SELECT value FROM counters WHERE id = ?", 1)
+
+		if buf.Len() != 0 {
+			t.Errorf("Expected no log output, got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,740 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// Continuous WAL archiving
+//
+// The replicator ships the database to a ReplicaSink as a series of generations.
+// A generation starts with a snapshot of the database, i.e. the database file
+// with the committed WAL frames applied, followed by numbered segments holding
+// the WAL frames of every transaction committed since. Automatic checkpoints
+// are disabled on the write pool so frames cannot be checkpointed away before
+// they are copied; the replicator checkpoints itself once it holds a copy.
+//
+// The replicator holds the single writer connection, blocking writes, only
+// while it copies the database file or WAL frames to a local file or memory
+// and checkpoints. Uploads to the sink happen after it is released, so a slow
+// sink never holds up the application.
+//
+// Sink layout:
+//
+//	generations/<generation>/snapshot.db.gz
+//	generations/<generation>/wal/<index>-<time>.wal
+//
+// Each segment starts with the 32-byte WAL header followed by whole frames up
+// to a commit frame, so it can be replayed on its own.
+
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+
+	walMagicLittleEndian = 0x377f0682
+	walMagicBigEndian    = 0x377f0683
+
+	// replicaTimeFormat names generations and segments; it sorts lexically
+	replicaTimeFormat = "20060102T150405.000000Z"
+
+	replicaGenerationsPrefix = "generations/"
+
+	// DefaultReplicaCheckpointSize is the WAL size after which the replicator
+	// checkpoints once the frames are copied
+	DefaultReplicaCheckpointSize = 4 << 20
+)
+
+// ErrNoReplica is returned when a sink has no generation to restore from
+var ErrNoReplica = errors.New("no replica generation available")
+
+// ReplicaGeneration describes a snapshot and the WAL segments that follow it
+type ReplicaGeneration struct {
+	ID       string
+	Start    time.Time
+	End      time.Time
+	Segments int
+}
+
+// walPosition tracks how far the WAL has been copied
+type walPosition struct {
+	salt1, salt2 uint32
+	offset       int64
+	// s0 and s1 are the cumulative checksum of the last copied frame
+	s0, s1 uint32
+	// checkpointed means every frame was copied and checkpointed, so the WAL
+	// may legitimately restart with new salts
+	checkpointed bool
+}
+
+// Replicator continuously copies committed WAL frames of a database to a sink
+type Replicator struct {
+	db               *Database
+	sink             ReplicaSink
+	syncInterval     time.Duration
+	snapshotInterval time.Duration
+	retention        time.Duration
+	checkpointSize   int64
+
+	mu         sync.Mutex
+	guard      *sql.DB
+	generation string
+	genStart   time.Time
+	index      int
+	pos        walPosition
+	// pending is a segment copied out of the WAL that is not in the sink yet
+	pending *pendingSegment
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// pendingSegment is a WAL segment waiting to be uploaded
+type pendingSegment struct {
+	name string
+	data []byte
+}
+
+// NewReplicator creates a replicator that syncs db to sink every syncInterval,
+// starts a new generation every snapshotInterval (0 disables) and deletes
+// generations no longer needed to restore to any point within retention
+// (0 keeps everything)
+func NewReplicator(db *Database, sink ReplicaSink, syncInterval, snapshotInterval, retention time.Duration) *Replicator {
+	return &Replicator{
+		db:               db,
+		sink:             sink,
+		syncInterval:     syncInterval,
+		snapshotInterval: snapshotInterval,
+		retention:        retention,
+		checkpointSize:   DefaultReplicaCheckpointSize,
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start takes the initial snapshot and runs the sync loop in the background
+// until Stop is called
+func (r *Replicator) Start(ctx context.Context) error {
+	if r.db.dialect != SQLite {
+		return fmt.Errorf("replication: %w", ErrUnsupported)
+	}
+	if isMemoryDatabase(r.db.path) {
+		return fmt.Errorf("cannot replicate an in-memory database")
+	}
+
+	// An idle connection keeps SQLite from checkpointing and deleting the WAL
+	// when the pools close their last connection
+	guard, err := sql.Open("sqlite", r.db.path+"?"+fmt.Sprintf("_pragma=busy_timeout(%d)", r.db.busyTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("failed to open replica guard connection: %w", err)
+	}
+	guard.SetMaxOpenConns(1)
+	guard.SetConnMaxLifetime(0)
+	if err := guard.PingContext(ctx); err != nil {
+		guard.Close()
+		return fmt.Errorf("failed to open replica guard connection: %w", err)
+	}
+	r.guard = guard
+
+	if err := r.Sync(ctx); err != nil {
+		guard.Close()
+		return err
+	}
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.syncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Sync(ctx); err != nil {
+					logger.FromContext(ctx).Error("WAL replication failed",
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the sync loop and copies any remaining frames
+func (r *Replicator) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+
+	ctx := context.Background()
+	if err := r.Sync(ctx); err != nil {
+		logger.FromContext(ctx).Error("Final WAL replication failed",
+			slog.String("error", err.Error()),
+		)
+	}
+	r.guard.Close()
+}
+
+// Sync copies newly committed WAL frames to the sink, starting a new generation
+// when none exists, the snapshot interval has passed or the WAL position was lost
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A segment whose upload failed goes first, so segments reach the sink in
+	// order; until it does, new frames stay in the WAL
+	if err := r.uploadPending(ctx); err != nil {
+		return err
+	}
+
+	if r.generation == "" || (r.snapshotInterval > 0 && time.Since(r.genStart) >= r.snapshotInterval) {
+		return r.startGeneration(ctx)
+	}
+
+	lost, err := r.copyFrames(ctx)
+	if err != nil {
+		return err
+	}
+	if lost {
+		logger.FromContext(ctx).Warn("WAL position lost, starting a new replica generation",
+			slog.String("generation", r.generation),
+		)
+		return r.startGeneration(ctx)
+	}
+	return r.uploadPending(ctx)
+}
+
+// uploadPending uploads the pending segment, keeping it for the next sync if
+// the upload fails
+func (r *Replicator) uploadPending(ctx context.Context) error {
+	if r.pending == nil {
+		return nil
+	}
+	if err := r.sink.WriteFile(ctx, r.pending.name, bytes.NewReader(r.pending.data)); err != nil {
+		return fmt.Errorf("failed to upload WAL segment: %w", err)
+	}
+	r.pending = nil
+	return nil
+}
+
+// startGeneration uploads a snapshot of the database as a new generation
+func (r *Replicator) startGeneration(ctx context.Context) error {
+	snapshot, start, pos, err := r.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(snapshot)
+
+	generation := start.Format(replicaTimeFormat)
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, f)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	if err := r.sink.WriteFile(ctx, snapshotName(generation), pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+
+	r.generation = generation
+	r.genStart = start
+	r.index = 0
+	r.pos = pos
+
+	logger.FromContext(ctx).Info("Started replica generation",
+		slog.String("generation", generation),
+	)
+
+	if err := r.prune(ctx); err != nil {
+		logger.FromContext(ctx).Error("Failed to prune replica generations",
+			slog.String("error", err.Error()),
+		)
+	}
+	return nil
+}
+
+// snapshot copies the database file to a temporary file next to it and
+// applies the committed WAL frames, returning its path, when it was taken and
+// the WAL position it covers. Holding the writer connection keeps the WAL and
+// the database file from changing meanwhile. Unlike a checkpoint, the copy
+// cannot be blocked by readers.
+func (r *Replicator) snapshot(ctx context.Context) (path string, taken time.Time, pos walPosition, err error) {
+	conn, err := r.db.DB().Conn(ctx)
+	if err != nil {
+		return "", time.Time{}, pos, fmt.Errorf("failed to acquire writer connection: %w", err)
+	}
+	defer conn.Close()
+	taken = time.Now().UTC()
+
+	wal, err := os.ReadFile(r.db.path + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return "", taken, pos, fmt.Errorf("failed to read WAL: %w", err)
+	}
+
+	src, err := os.Open(r.db.path)
+	if err != nil {
+		return "", taken, pos, fmt.Errorf("failed to open database file: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.db.path), ".replica-snapshot-*.db")
+	if err != nil {
+		return "", taken, pos, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	defer tmp.Close()
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		return "", taken, pos, fmt.Errorf("failed to copy database file: %w", err)
+	}
+
+	pos = walPosition{checkpointed: true}
+	if len(wal) >= walHeaderSize {
+		var header walHeader
+		if header, err = parseWALHeader(wal[:walHeaderSize]); err != nil {
+			return "", taken, pos, err
+		}
+		end, s0, s1 := scanFrames(wal, header, walHeaderSize, header.s0, header.s1)
+		if err = applyFrames(tmp, header.pageSize, wal[walHeaderSize:end]); err != nil {
+			return "", taken, pos, err
+		}
+		pos = walPosition{salt1: header.salt1, salt2: header.salt2, offset: end, s0: s0, s1: s1}
+	}
+
+	if err = tmp.Close(); err != nil {
+		return "", taken, pos, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return tmp.Name(), taken, pos, nil
+}
+
+// copyFrames copies the transactions committed since the last sync out of the
+// WAL as the pending segment, then checkpoints once the WAL has grown past
+// checkpointSize. Holding the writer connection blocks writes, so the WAL
+// cannot change between the copy and the checkpoint. It reports lost if frames
+// were removed from the WAL before they could be copied.
+func (r *Replicator) copyFrames(ctx context.Context) (lost bool, err error) {
+	conn, err := r.db.DB().Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire writer connection: %w", err)
+	}
+	defer conn.Close()
+
+	wal, err := os.ReadFile(r.db.path + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read WAL: %w", err)
+	}
+
+	if len(wal) < walHeaderSize {
+		// An empty WAL is expected only right after our own checkpoint
+		return r.pos.offset > 0 && !r.pos.checkpointed, nil
+	}
+
+	header, err := parseWALHeader(wal[:walHeaderSize])
+	if err != nil {
+		return false, err
+	}
+
+	if header.salt1 != r.pos.salt1 || header.salt2 != r.pos.salt2 || r.pos.offset == 0 {
+		if r.pos.offset > 0 && !r.pos.checkpointed {
+			return true, nil
+		}
+		r.pos = walPosition{
+			salt1:  header.salt1,
+			salt2:  header.salt2,
+			offset: walHeaderSize,
+			s0:     header.s0,
+			s1:     header.s1,
+		}
+	}
+	if int64(len(wal)) < r.pos.offset {
+		return true, nil
+	}
+
+	commitEnd, commitS0, commitS1 := scanFrames(wal, header, r.pos.offset, r.pos.s0, r.pos.s1)
+	if commitEnd > r.pos.offset {
+		segment := make([]byte, 0, walHeaderSize+commitEnd-r.pos.offset)
+		segment = append(segment, wal[:walHeaderSize]...)
+		segment = append(segment, wal[r.pos.offset:commitEnd]...)
+
+		r.index++
+		r.pending = &pendingSegment{name: segmentName(r.generation, r.index, time.Now()), data: segment}
+		r.pos.offset, r.pos.s0, r.pos.s1 = commitEnd, commitS0, commitS1
+		r.pos.checkpointed = false
+	}
+
+	if r.pos.offset >= r.checkpointSize && !r.pos.checkpointed {
+		if _, err := checkpointTruncate(ctx, conn); err != nil {
+			return false, err
+		}
+		// Every frame is copied, so whether or not the checkpoint completed
+		// the WAL may now restart
+		r.pos.checkpointed = true
+	}
+	return false, nil
+}
+
+// scanFrames walks the WAL frames from offset, whose preceding frames have the
+// cumulative checksum s0, s1, and stops at the first frame that is partial or
+// fails validation. It returns the end of the last whole transaction and the
+// checksum up to it.
+func scanFrames(wal []byte, header walHeader, offset int64, s0, s1 uint32) (int64, uint32, uint32) {
+	frameSize := int64(walFrameHeaderSize + header.pageSize)
+	commitEnd, commitS0, commitS1 := offset, s0, s1
+	for offset+frameSize <= int64(len(wal)) {
+		frame := wal[offset : offset+frameSize]
+		if binary.BigEndian.Uint32(frame[8:]) != header.salt1 || binary.BigEndian.Uint32(frame[12:]) != header.salt2 {
+			break
+		}
+		s0, s1 = walChecksum(header.bigEndian, s0, s1, frame[:8])
+		s0, s1 = walChecksum(header.bigEndian, s0, s1, frame[walFrameHeaderSize:])
+		if s0 != binary.BigEndian.Uint32(frame[16:]) || s1 != binary.BigEndian.Uint32(frame[20:]) {
+			break
+		}
+
+		offset += frameSize
+		// Only whole transactions are shipped
+		if binary.BigEndian.Uint32(frame[4:]) != 0 {
+			commitEnd, commitS0, commitS1 = offset, s0, s1
+		}
+	}
+	return commitEnd, commitS0, commitS1
+}
+
+// prune deletes generations that are superseded by a generation older than the retention period
+func (r *Replicator) prune(ctx context.Context) error {
+	if r.retention <= 0 {
+		return nil
+	}
+
+	generations, err := ListReplicaGenerations(ctx, r.sink)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-r.retention)
+	for i := 0; i < len(generations)-1; i++ {
+		// A generation is still needed while the next one started within retention
+		if generations[i+1].Start.After(cutoff) {
+			break
+		}
+
+		names, err := r.sink.List(ctx, replicaGenerationsPrefix+generations[i].ID+"/")
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := r.sink.Delete(ctx, name); err != nil {
+				return err
+			}
+		}
+		logger.FromContext(ctx).Info("Removed expired replica generation",
+			slog.String("generation", generations[i].ID),
+		)
+	}
+	return nil
+}
+
+// checkpointTruncate copies all WAL frames into the database file and truncates
+// the WAL. It reports busy if readers prevented the checkpoint from completing.
+func checkpointTruncate(ctx context.Context, conn *sql.Conn) (bool, error) {
+	var busy, logFrames, checkpointed int
+	if err := conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return false, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return busy != 0, nil
+}
+
+// walHeader holds the fields of a WAL file header used for replication
+type walHeader struct {
+	bigEndian    bool
+	pageSize     int
+	salt1, salt2 uint32
+	s0, s1       uint32
+}
+
+// parseWALHeader validates and decodes a 32-byte WAL header
+func parseWALHeader(b []byte) (walHeader, error) {
+	var h walHeader
+	switch binary.BigEndian.Uint32(b) {
+	case walMagicLittleEndian:
+	case walMagicBigEndian:
+		h.bigEndian = true
+	default:
+		return h, fmt.Errorf("invalid WAL header magic %#x", binary.BigEndian.Uint32(b))
+	}
+
+	h.pageSize = int(binary.BigEndian.Uint32(b[8:]))
+	if h.pageSize == 1 {
+		h.pageSize = 65536
+	}
+	h.salt1 = binary.BigEndian.Uint32(b[16:])
+	h.salt2 = binary.BigEndian.Uint32(b[20:])
+	h.s0 = binary.BigEndian.Uint32(b[24:])
+	h.s1 = binary.BigEndian.Uint32(b[28:])
+
+	if s0, s1 := walChecksum(h.bigEndian, 0, 0, b[:24]); s0 != h.s0 || s1 != h.s1 {
+		return h, fmt.Errorf("invalid WAL header checksum")
+	}
+	return h, nil
+}
+
+// walChecksum extends the cumulative WAL checksum s0, s1 over b
+func walChecksum(bigEndian bool, s0, s1 uint32, b []byte) (uint32, uint32) {
+	order := binary.ByteOrder(binary.LittleEndian)
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+// snapshotName returns the sink name of a generation's snapshot
+func snapshotName(generation string) string {
+	return replicaGenerationsPrefix + generation + "/snapshot.db.gz"
+}
+
+// segmentName returns the sink name of a WAL segment
+func segmentName(generation string, index int, t time.Time) string {
+	return fmt.Sprintf("%s%s/wal/%08d-%s.wal", replicaGenerationsPrefix, generation, index, t.UTC().Format(replicaTimeFormat))
+}
+
+// replicaSegment is a WAL segment listed in a sink
+type replicaSegment struct {
+	name  string
+	index int
+	time  time.Time
+}
+
+// listSegments returns the segments of a generation in replay order
+func listSegments(ctx context.Context, sink ReplicaSink, generation string) ([]replicaSegment, error) {
+	names, err := sink.List(ctx, replicaGenerationsPrefix+generation+"/wal/")
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []replicaSegment
+	for _, name := range names {
+		base := strings.TrimSuffix(filepath.Base(name), ".wal")
+		indexPart, timePart, ok := strings.Cut(base, "-")
+		if !ok {
+			continue
+		}
+		index, err := strconv.Atoi(indexPart)
+		if err != nil {
+			continue
+		}
+		t, err := time.Parse(replicaTimeFormat, timePart)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, replicaSegment{name: name, index: index, time: t})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].index < segments[j].index })
+	return segments, nil
+}
+
+// ListReplicaGenerations returns the generations stored in sink, oldest first
+func ListReplicaGenerations(ctx context.Context, sink ReplicaSink) ([]ReplicaGeneration, error) {
+	names, err := sink.List(ctx, replicaGenerationsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var generations []ReplicaGeneration
+	for _, name := range names {
+		id, rest, ok := strings.Cut(strings.TrimPrefix(name, replicaGenerationsPrefix), "/")
+		if !ok || rest != "snapshot.db.gz" {
+			continue
+		}
+		start, err := time.Parse(replicaTimeFormat, id)
+		if err != nil {
+			continue
+		}
+
+		segments, err := listSegments(ctx, sink, id)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if len(segments) > 0 {
+			end = segments[len(segments)-1].time
+		}
+
+		generations = append(generations, ReplicaGeneration{ID: id, Start: start, End: end, Segments: len(segments)})
+	}
+
+	sort.Slice(generations, func(i, j int) bool { return generations[i].Start.Before(generations[j].Start) })
+	return generations, nil
+}
+
+// RestoreReplica rebuilds the database at dbPath from the replica in sink as it
+// was at target, or as of the last synced transaction if target is zero.
+// Transactions are restored with the granularity of the sync interval. Like
+// Restore, the current database is kept with a .pre-restore suffix and the
+// application must not be using dbPath.
+func RestoreReplica(ctx context.Context, sink ReplicaSink, dbPath string, target time.Time) error {
+	generations, err := ListReplicaGenerations(ctx, sink)
+	if err != nil {
+		return err
+	}
+
+	// Use the newest generation whose snapshot predates the target
+	var generation *ReplicaGeneration
+	for i := range generations {
+		if target.IsZero() || !generations[i].Start.After(target) {
+			generation = &generations[i]
+		}
+	}
+	if generation == nil {
+		return ErrNoReplica
+	}
+
+	dbDir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dbDir, ".replica-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := downloadSnapshot(ctx, sink, generation.ID, tmp); err != nil {
+		return err
+	}
+
+	segments, err := listSegments(ctx, sink, generation.ID)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		if !target.IsZero() && segment.time.After(target) {
+			break
+		}
+		if err := applySegment(ctx, sink, segment.name, tmp); err != nil {
+			return err
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync restored database: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close restored database: %w", err)
+	}
+
+	return Restore(ctx, tmp.Name(), dbPath)
+}
+
+// downloadSnapshot decompresses a generation's snapshot into f
+func downloadSnapshot(ctx context.Context, sink ReplicaSink, generation string, f *os.File) error {
+	rc, err := sink.OpenFile(ctx, snapshotName(generation))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer rc.Close()
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	if _, err := io.Copy(f, gz); err != nil {
+		return fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	return nil
+}
+
+// applySegment writes the pages of every committed transaction in a segment
+// into the database file f
+func applySegment(ctx context.Context, sink ReplicaSink, name string, f *os.File) error {
+	rc, err := sink.OpenFile(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL segment: %w", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL segment %s: %w", name, err)
+	}
+	if len(data) < walHeaderSize {
+		return fmt.Errorf("WAL segment %s is truncated", name)
+	}
+
+	header, err := parseWALHeader(data[:walHeaderSize])
+	if err != nil {
+		return fmt.Errorf("WAL segment %s: %w", name, err)
+	}
+
+	frames := data[walHeaderSize:]
+	if len(frames)%(walFrameHeaderSize+header.pageSize) != 0 {
+		return fmt.Errorf("WAL segment %s is truncated", name)
+	}
+	return applyFrames(f, header.pageSize, frames)
+}
+
+// applyFrames writes the pages of whole WAL frames into the database file f
+func applyFrames(f *os.File, pageSize int, frames []byte) error {
+	frameSize := walFrameHeaderSize + pageSize
+	for len(frames) > 0 {
+		frame := frames[:frameSize]
+		frames = frames[frameSize:]
+
+		pgno := int64(binary.BigEndian.Uint32(frame))
+		if _, err := f.WriteAt(frame[walFrameHeaderSize:], (pgno-1)*int64(pageSize)); err != nil {
+			return fmt.Errorf("failed to apply WAL frame: %w", err)
+		}
+
+		// A commit frame records the database size, which shrinks after deletes
+		if dbSize := int64(binary.BigEndian.Uint32(frame[4:])); dbSize != 0 {
+			if err := f.Truncate(dbSize * int64(pageSize)); err != nil {
+				return fmt.Errorf("failed to apply WAL commit: %w", err)
+			}
+		}
+	}
+	return nil
+}
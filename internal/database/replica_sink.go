@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReplicaSink stores WAL replica files. Names are slash-separated paths relative
+// to the root of the sink, so implementations backed by object storage can use
+// them as keys directly.
+type ReplicaSink interface {
+	// WriteFile stores the contents of r under name, replacing any existing file.
+	// A partially written file must never be visible under name.
+	WriteFile(ctx context.Context, name string, r io.Reader) error
+	// OpenFile opens the named file for reading
+	OpenFile(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of all files starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the named file; deleting a missing file is not an error
+	Delete(ctx context.Context, name string) error
+}
+
+// FileSink is a ReplicaSink that stores files in a local directory
+type FileSink struct {
+	dir string
+}
+
+// NewFileSink creates a sink that stores replica files under dir
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{dir: dir}
+}
+
+// path converts a sink name into a path under the sink directory
+func (s *FileSink) path(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid replica file name %q", name)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// WriteFile writes to a temporary file and renames it into place
+func (s *FileSink) WriteFile(ctx context.Context, name string, r io.Reader) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create replica directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create replica file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return fmt.Errorf("failed to write replica file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync replica file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close replica file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move replica file into place: %w", err)
+	}
+	return nil
+}
+
+// OpenFile opens the named file
+func (s *FileSink) OpenFile(ctx context.Context, name string) (io.ReadCloser, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// List walks the sink directory for files starting with prefix
+func (s *FileSink) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replica files: %w", err)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete removes the named file
+func (s *FileSink) Delete(ctx context.Context, name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete replica file: %w", err)
+	}
+	// Remove directories left empty, ignoring failures for non-empty ones
+	for dir := filepath.Dir(path); dir != s.dir && strings.HasPrefix(dir, s.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/config"
+)
+
+// openReplicatedDB opens a database configured for WAL replication into a FileSink
+func openReplicatedDB(t *testing.T) (*Database, *FileSink) {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.SQLite.DBFile = filepath.Join(dir, "app.db")
+	cfg.SQLite.MaxOpenConnections = 5
+	cfg.SQLite.MaxIdleConnections = 2
+	cfg.SQLite.ConnectionMaxLifetimeSeconds = 300
+	cfg.Replica.Dir = filepath.Join(dir, "replica")
+
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, NewFileSink(cfg.Replica.Dir)
+}
+
+// setCounter updates the counter row and syncs the replica
+func setCounter(t *testing.T, db *Database, r *Replicator, value int) time.Time {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = ? WHERE id = 1", value); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+	if err := r.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	synced := time.Now()
+	// Keep segment timestamps of successive updates apart
+	time.Sleep(2 * time.Millisecond)
+	return synced
+}
+
+func restoredCounter(t *testing.T, sink ReplicaSink, target time.Time) int {
+	t.Helper()
+
+	dbFile := filepath.Join(t.TempDir(), "restored.db")
+	if err := RestoreReplica(context.Background(), sink, dbFile, target); err != nil {
+		t.Fatalf("RestoreReplica failed: %v", err)
+	}
+	return counterValue(t, openTestDB(t, dbFile))
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	sink := NewFileSink(t.TempDir())
+
+	for _, name := range []string{"a/1", "a/2", "b/1"} {
+		if err := sink.WriteFile(ctx, name, strings.NewReader("data "+name)); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+
+	names, err := sink.List(ctx, "a/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if strings.Join(names, ",") != "a/1,a/2" {
+		t.Errorf("Expected a/1,a/2, got %v", names)
+	}
+
+	rc, err := sink.OpenFile(ctx, "b/1")
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "data b/1" {
+		t.Errorf("Expected file contents, got %q", data)
+	}
+
+	if err := sink.Delete(ctx, "b/1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := sink.Delete(ctx, "b/1"); err != nil {
+		t.Errorf("Expected deleting a missing file to succeed, got %v", err)
+	}
+	if names, _ := sink.List(ctx, ""); len(names) != 2 {
+		t.Errorf("Expected 2 files after delete, got %v", names)
+	}
+
+	if err := sink.WriteFile(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("Expected names outside the sink to be rejected")
+	}
+}
+
+func TestReplicaPointInTimeRestore(t *testing.T) {
+	db, sink := openReplicatedDB(t)
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	r := NewReplicator(db, sink, time.Hour, 0, 0)
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	t1 := setCounter(t, db, r, 1)
+	t2 := setCounter(t, db, r, 2)
+	// Checkpoint after every sync from here on, so the WAL restarts between segments
+	r.checkpointSize = 1
+	t3 := setCounter(t, db, r, 3)
+	setCounter(t, db, r, 4)
+
+	// Writes after the last sync are shipped when the replicator stops
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = 5 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+	r.Stop()
+
+	tests := []struct {
+		name   string
+		target time.Time
+		want   int
+	}{
+		{"first sync", t1, 1},
+		{"second sync", t2, 2},
+		{"after checkpoint", t3, 3},
+		{"latest", time.Time{}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restoredCounter(t, sink, tt.target); got != tt.want {
+				t.Errorf("Expected counter %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("before first snapshot", func(t *testing.T) {
+		err := RestoreReplica(ctx, sink, filepath.Join(t.TempDir(), "restored.db"), t1.Add(-time.Hour))
+		if !errors.Is(err, ErrNoReplica) {
+			t.Errorf("Expected ErrNoReplica, got %v", err)
+		}
+	})
+}
+
+func TestReplicaGenerations(t *testing.T) {
+	db, sink := openReplicatedDB(t)
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	// A tiny snapshot interval starts a new generation on every sync
+	r := NewReplicator(db, sink, time.Hour, time.Nanosecond, 0)
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	t1 := setCounter(t, db, r, 1)
+	setCounter(t, db, r, 2)
+	r.Stop()
+
+	generations, err := ListReplicaGenerations(ctx, sink)
+	if err != nil {
+		t.Fatalf("ListReplicaGenerations failed: %v", err)
+	}
+	if len(generations) < 3 {
+		t.Fatalf("Expected at least 3 generations, got %d", len(generations))
+	}
+
+	// Restores pick the generation whose snapshot covers the target
+	if got := restoredCounter(t, sink, t1); got != 1 {
+		t.Errorf("Expected counter 1, got %d", got)
+	}
+	if got := restoredCounter(t, sink, time.Time{}); got != 2 {
+		t.Errorf("Expected counter 2, got %d", got)
+	}
+
+	// Old generations are pruned once newer ones cover the retention period
+	r.retention = time.Nanosecond
+	if err := r.prune(ctx); err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	generations, _ = ListReplicaGenerations(ctx, sink)
+	if len(generations) != 1 {
+		t.Errorf("Expected only the current generation to remain, got %d", len(generations))
+	}
+}
+
+func TestReplicaLostPosition(t *testing.T) {
+	db, sink := openReplicatedDB(t)
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	r := NewReplicator(db, sink, time.Hour, 0, 0)
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer r.Stop()
+
+	setCounter(t, db, r, 1)
+
+	// Frames checkpointed away by someone else cannot be shipped
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = 2 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+	if _, err := db.Exec(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		t.Fatalf("Failed to checkpoint: %v", err)
+	}
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = 3 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+	if err := r.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	generations, _ := ListReplicaGenerations(ctx, sink)
+	if len(generations) != 2 {
+		t.Fatalf("Expected a new generation after losing the WAL position, got %d", len(generations))
+	}
+	if got := restoredCounter(t, sink, time.Time{}); got != 3 {
+		t.Errorf("Expected counter 3, got %d", got)
+	}
+}
+
+// blockingSink is a FileSink whose writes wait for release once armed
+type blockingSink struct {
+	*FileSink
+	armed   atomic.Bool
+	entered chan string
+	release chan struct{}
+}
+
+func (s *blockingSink) WriteFile(ctx context.Context, name string, r io.Reader) error {
+	if s.armed.Load() {
+		s.entered <- name
+		<-s.release
+	}
+	return s.FileSink.WriteFile(ctx, name, r)
+}
+
+func TestReplicaUploadDoesNotBlockWrites(t *testing.T) {
+	db, fileSink := openReplicatedDB(t)
+	createCounterTable(t, db)
+	sink := &blockingSink{FileSink: fileSink, entered: make(chan string), release: make(chan struct{})}
+
+	ctx := context.Background()
+	r := NewReplicator(db, sink, time.Hour, 0, 0)
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer r.Stop()
+
+	for i, kind := range []string{"/wal/", "/snapshot.db.gz"} {
+		t.Run(strings.Trim(kind, "/"), func(t *testing.T) {
+			if kind == "/snapshot.db.gz" {
+				r.snapshotInterval = time.Nanosecond
+				defer func() { r.snapshotInterval = 0 }()
+			}
+			if _, err := db.Exec(ctx, "UPDATE counters SET value = ? WHERE id = 1", i*10+1); err != nil {
+				t.Fatalf("Failed to update counter: %v", err)
+			}
+
+			sink.armed.Store(true)
+			synced := make(chan error, 1)
+			go func() { synced <- r.Sync(ctx) }()
+			if name := <-sink.entered; !strings.Contains(name, kind) {
+				t.Fatalf("Expected an upload of %s, got %s", kind, name)
+			}
+			sink.armed.Store(false)
+
+			// The application keeps writing while the upload is in flight
+			writeCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			if _, err := db.Exec(writeCtx, "UPDATE counters SET value = ? WHERE id = 1", i*10+2); err != nil {
+				t.Errorf("Expected writes to proceed during the upload, got %v", err)
+			}
+
+			sink.release <- struct{}{}
+			if err := <-synced; err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if err := r.Sync(ctx); err != nil {
+				t.Fatalf("Sync failed: %v", err)
+			}
+			if got := restoredCounter(t, fileSink, time.Time{}); got != i*10+2 {
+				t.Errorf("Expected counter %d, got %d", i*10+2, got)
+			}
+		})
+	}
+}
+
+func TestReplicaStartsWithActiveReaders(t *testing.T) {
+	db, sink := openReplicatedDB(t)
+	createCounterTable(t, db)
+
+	// A read transaction holds a snapshot, so a TRUNCATE checkpoint cannot complete
+	ctx := context.Background()
+	tx, err := db.reader.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin read transaction: %v", err)
+	}
+	defer tx.Rollback()
+	var value int
+	if err := tx.QueryRowContext(ctx, "SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	if _, err := db.Exec(ctx, "UPDATE counters SET value = 7 WHERE id = 1"); err != nil {
+		t.Fatalf("Failed to update counter: %v", err)
+	}
+
+	r := NewReplicator(db, sink, time.Hour, 0, 0)
+	if err := r.Start(ctx); err != nil {
+		t.Fatalf("Expected the first generation to start despite readers, got %v", err)
+	}
+	r.Stop()
+
+	if got := restoredCounter(t, sink, time.Time{}); got != 7 {
+		t.Errorf("Expected counter 7, got %d", got)
+	}
+}
+
+func TestReplicaRequiresFileDatabase(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.SQLite.DBFile = ":memory:"
+	db, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	r := NewReplicator(db, NewFileSink(t.TempDir()), time.Second, 0, 0)
+	if err := r.Start(context.Background()); err == nil {
+		t.Error("Expected replicating an in-memory database to fail")
+	}
+}
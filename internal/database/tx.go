@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Querier is implemented by both Database and Tx, so repositories can run
+// either directly against the pool or inside a caller's transaction
+type Querier interface {
+	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row
+	// Dialect returns the SQL dialect queries are written for
+	Dialect() Dialect
+}
+
+// RetryPolicy controls how WithTx retries transactions that fail because the
+// database is busy or locked
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used by databases created with New
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     500 * time.Millisecond,
+}
+
+// backoff returns the jittered delay before the given retry attempt (1-based)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff << (attempt - 1)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	// Full jitter spreads out writers that collided at the same moment
+	return time.Duration(rand.Int64N(int64(delay) + 1))
+}
+
+// Tx wraps sql.Tx and supports nested transactions through savepoints
+type Tx struct {
+	tx    *sql.Tx
+	depth int
+	// savepoints is shared by all nesting levels to keep savepoint names unique
+	savepoints *int
+	observer   *queryObserver
+	dialect    Dialect
+}
+
+// Tx returns the underlying sql.Tx instance
+func (t *Tx) Tx() *sql.Tx {
+	return t.tx
+}
+
+// Dialect returns the SQL dialect of the transaction's database
+func (t *Tx) Dialect() Dialect {
+	return t.dialect
+}
+
+// rebind rewrites the placeholders of a query with arguments for the dialect
+func (t *Tx) rebind(query string, args []interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+	return t.dialect.Rebind(query)
+}
+
+// Exec executes a query without returning any rows
+func (t *Tx) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	span := startQuerySpan(ctx, t.dialect, query)
+	start := time.Now()
+	result, err := t.tx.ExecContext(ctx, t.rebind(query, args), args...)
+	t.observer.observe(ctx, query, args, start, err)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+// Query executes a query that returns rows
+func (t *Tx) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	span := startQuerySpan(ctx, t.dialect, query)
+	start := time.Now()
+	rows, err := t.tx.QueryContext(ctx, t.rebind(query, args), args...)
+	t.observer.observe(ctx, query, args, start, err)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+// QueryRow executes a query that returns at most one row
+func (t *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	span := startQuerySpan(ctx, t.dialect, query)
+	start := time.Now()
+	row := t.tx.QueryRowContext(ctx, t.rebind(query, args), args...)
+	t.observer.observe(ctx, query, args, start, row.Err())
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+// WithTx runs fn in a savepoint nested in this transaction. If fn returns an
+// error or panics, only the work done inside the savepoint is rolled back.
+func (t *Tx) WithTx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	*t.savepoints++
+	name := fmt.Sprintf("sp_%d", *t.savepoints)
+
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	nested := &Tx{tx: t.tx, depth: t.depth + 1, savepoints: t.savepoints, observer: t.observer, dialect: t.dialect}
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.rollbackTo(ctx, name)
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		t.rollbackTo(ctx, name)
+		return err
+	}
+
+	if _, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// rollbackTo undoes everything since the named savepoint and removes it
+func (t *Tx) rollbackTo(ctx context.Context, name string) {
+	// Errors are ignored: the outer transaction is rolled back anyway if the
+	// connection is in a bad state
+	t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+	t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+}
+
+// SetRetryPolicy sets how WithTx retries busy or locked transactions
+func (d *Database) SetRetryPolicy(policy RetryPolicy) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	d.retry = policy
+}
+
+// WithTx runs fn in a transaction that is committed if fn returns nil and rolled
+// back otherwise. When the database reports SQLITE_BUSY or SQLITE_LOCKED, or a
+// PostgreSQL serialization failure or deadlock, the whole transaction,
+// including fn, is retried with backoff, so fn must not have side effects
+// outside the transaction.
+func (d *Database) WithTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	d.lock.RLock()
+	policy := d.retry
+	d.lock.RUnlock()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = d.runTx(ctx, opts, fn)
+		if err == nil || !isBusyError(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(policy.backoff(attempt)):
+		}
+	}
+}
+
+// runTx makes a single attempt at running fn in a transaction
+func (d *Database) runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := d.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&Tx{tx: sqlTx, savepoints: new(int), observer: d.observer, dialect: d.dialect}); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createCounterTable(t *testing.T, db *Database) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := db.Exec(ctx, "CREATE TABLE counters (id INTEGER PRIMARY KEY, value INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := db.Exec(ctx, "INSERT INTO counters (id, value) VALUES (1, 0)"); err != nil {
+		t.Fatalf("Failed to insert counter: %v", err)
+	}
+}
+
+func counterValue(t *testing.T, db *Database) int {
+	t.Helper()
+
+	var value int
+	if err := db.QueryRow(context.Background(), "SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	return value
+}
+
+func TestWithTx(t *testing.T) {
+	t.Run("commits on success", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+		createCounterTable(t, db)
+
+		ctx := context.Background()
+		err := db.WithTx(ctx, nil, func(tx *Tx) error {
+			_, err := tx.Exec(ctx, "UPDATE counters SET value = value + 1 WHERE id = 1")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("WithTx failed: %v", err)
+		}
+
+		if value := counterValue(t, db); value != 1 {
+			t.Errorf("Expected value 1 after commit, got %d", value)
+		}
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+		createCounterTable(t, db)
+
+		ctx := context.Background()
+		errBoom := errors.New("boom")
+		err := db.WithTx(ctx, nil, func(tx *Tx) error {
+			if _, err := tx.Exec(ctx, "UPDATE counters SET value = 42 WHERE id = 1"); err != nil {
+				return err
+			}
+			return errBoom
+		})
+		if !errors.Is(err, errBoom) {
+			t.Fatalf("Expected errBoom, got %v", err)
+		}
+
+		if value := counterValue(t, db); value != 0 {
+			t.Errorf("Expected value 0 after rollback, got %d", value)
+		}
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+		createCounterTable(t, db)
+
+		ctx := context.Background()
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic to propagate")
+				}
+			}()
+			db.WithTx(ctx, nil, func(tx *Tx) error {
+				tx.Exec(ctx, "UPDATE counters SET value = 42 WHERE id = 1")
+				panic("boom")
+			})
+		}()
+
+		if value := counterValue(t, db); value != 0 {
+			t.Errorf("Expected value 0 after panic, got %d", value)
+		}
+	})
+
+	t.Run("does not retry non-busy errors", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		calls := 0
+		db.WithTx(context.Background(), nil, func(tx *Tx) error {
+			calls++
+			return errors.New("permanent")
+		})
+		if calls != 1 {
+			t.Errorf("Expected 1 call, got %d", calls)
+		}
+	})
+}
+
+func TestWithTxSavepoints(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	createCounterTable(t, db)
+
+	ctx := context.Background()
+	err := db.WithTx(ctx, nil, func(tx *Tx) error {
+		if _, err := tx.Exec(ctx, "UPDATE counters SET value = value + 1 WHERE id = 1"); err != nil {
+			return err
+		}
+
+		// A failed nested transaction only undoes its own work
+		nestedErr := tx.WithTx(ctx, func(nested *Tx) error {
+			if _, err := nested.Exec(ctx, "UPDATE counters SET value = value + 100 WHERE id = 1"); err != nil {
+				return err
+			}
+			return errors.New("nested failure")
+		})
+		if nestedErr == nil {
+			t.Error("Expected nested error to be returned")
+		}
+
+		// A successful nested transaction is kept, even several levels deep
+		return tx.WithTx(ctx, func(nested *Tx) error {
+			return nested.WithTx(ctx, func(deeper *Tx) error {
+				_, err := deeper.Exec(ctx, "UPDATE counters SET value = value + 10 WHERE id = 1")
+				return err
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	if value := counterValue(t, db); value != 11 {
+		t.Errorf("Expected value 11, got %d", value)
+	}
+}
+
+func TestWithTxRetriesBusy(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "busy.db")
+	db := openTestDB(t, dbFile)
+	createCounterTable(t, db)
+
+	// A second pool on the same file behaves like another process
+	other := openTestDB(t, dbFile)
+
+	ctx := context.Background()
+	db.SetRetryPolicy(RetryPolicy{MaxAttempts: 100, InitialBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+	other.SetRetryPolicy(RetryPolicy{MaxAttempts: 100, InitialBackoff: time.Millisecond, MaxBackoff: 20 * time.Millisecond})
+
+	// Read-then-write transactions collide and fail with SQLITE_BUSY
+	increment := func(d *Database) error {
+		return d.WithTx(ctx, nil, func(tx *Tx) error {
+			var value int
+			if err := tx.QueryRow(ctx, "SELECT value FROM counters WHERE id = 1").Scan(&value); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "UPDATE counters SET value = ? WHERE id = 1", value+1)
+			return err
+		})
+	}
+
+	const workers, increments = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*increments)
+	for i := 0; i < workers; i++ {
+		d := db
+		if i%2 == 1 {
+			d = other
+		}
+		wg.Add(1)
+		go func(d *Database) {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				if err := increment(d); err != nil {
+					errs <- err
+				}
+			}
+		}(d)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Increment failed: %v", err)
+	}
+
+	if value := counterValue(t, db); value != workers*increments {
+		t.Errorf("Expected no lost updates (%d), got %d", workers*increments, value)
+	}
+}
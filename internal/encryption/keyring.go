@@ -0,0 +1,291 @@
+// Package encryption encrypts sensitive values at rest. Values are sealed with
+// AES-256-GCM under a key from a Keyring and stored as self-describing
+// envelopes that name the key, so keys can be rotated without a flag day: new
+// writes use the primary key while older keys keep decrypting existing data
+// until it is re-encrypted.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeySize is the size of an AES-256 key in bytes
+const KeySize = 32
+
+// envelopePrefix starts every encrypted value; the key ID and payload follow
+const envelopePrefix = "enc:v1:"
+
+var (
+	// ErrNoKeyring is returned when a value must be encrypted or decrypted
+	// but no keyring has been configured
+	ErrNoKeyring = errors.New("no encryption keyring configured")
+	// ErrUnknownKey is returned for envelopes sealed with a key that is not
+	// in the keyring
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrMalformed is returned for values that are not valid envelopes
+	ErrMalformed = errors.New("malformed encrypted value")
+	// ErrDecrypt is returned when an envelope fails authentication, i.e. it
+	// was tampered with or sealed with a different key of the same ID
+	ErrDecrypt = errors.New("failed to decrypt value")
+)
+
+// keyIDPattern restricts key IDs to characters that cannot clash with the
+// envelope's separators
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// keyringFile is the JSON layout of a keyring file
+type keyringFile struct {
+	Primary string       `json:"primary"`
+	Keys    []keyringKey `json:"keys"`
+}
+
+// keyringKey is one key of a keyring file, base64 encoded
+type keyringKey struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Keyring holds the keys values are encrypted with. The primary key seals new
+// values; every key can open values sealed with it.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring creates a keyring from raw 32-byte keys indexed by ID
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+
+	k := &Keyring{primary: primary, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, KeySize, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher for key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// LoadKeyring reads a keyring file
+func LoadKeyring(path string) (*Keyring, error) {
+	file, err := readKeyringFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("keyring %s has no keys", path)
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for _, entry := range file.Keys {
+		if _, ok := keys[entry.ID]; ok {
+			return nil, fmt.Errorf("keyring %s has duplicate key %q", path, entry.ID)
+		}
+		key, err := base64.StdEncoding.DecodeString(entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", entry.ID, err)
+		}
+		keys[entry.ID] = key
+	}
+
+	k, err := NewKeyring(file.Primary, keys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyring %s: %w", path, err)
+	}
+	return k, nil
+}
+
+// AddKey generates a new random key, adds it to the keyring file and makes it
+// the primary key. The file is created if it doesn't exist. An empty id
+// defaults to the current UTC time, e.g. 20240131T120000Z.
+func AddKey(path, id string) (string, error) {
+	if id == "" {
+		id = time.Now().UTC().Format("20060102T150405Z")
+	}
+	if !keyIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid key ID %q: use letters, digits, '_', '.' and '-'", id)
+	}
+
+	file, err := readKeyringFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = &keyringFile{}, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range file.Keys {
+		if entry.ID == id {
+			return "", fmt.Errorf("key %q already exists", id)
+		}
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	file.Keys = append(file.Keys, keyringKey{ID: id, Key: base64.StdEncoding.EncodeToString(key), CreatedAt: time.Now().UTC()})
+	file.Primary = id
+
+	if err := writeKeyringFile(path, file); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// readKeyringFile parses a keyring file
+func readKeyringFile(path string) (*keyringFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// writeKeyringFile replaces the keyring file atomically, readable by the
+// owner only
+func writeKeyringFile(path string, file *keyringFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keyring: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create keyring: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set keyring permissions: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync keyring: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace keyring: %w", err)
+	}
+	return nil
+}
+
+// PrimaryKeyID returns the ID of the key new values are sealed with
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// KeyIDs returns the IDs of all keys, sorted
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.aeads))
+	for id := range k.aeads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Encrypt seals plaintext with the primary key and returns the envelope
+// enc:v1:<key ID>:<base64 nonce and ciphertext>. The envelope header is
+// authenticated, so a value cannot be relabelled with another key ID.
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	aead := k.aeads[k.primary]
+	header := envelopePrefix + k.primary
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(header))
+	return header + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens an envelope produced by Encrypt with any key in the keyring
+func (k *Keyring) Decrypt(envelope string) ([]byte, error) {
+	id, payload, ok := parseEnvelope(envelope)
+	if !ok {
+		return nil, ErrMalformed
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(envelopePrefix+id))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// NeedsRotation reports whether a stored value should be re-encrypted: it is
+// plaintext or sealed with a key other than the primary key
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, ok := KeyID(value)
+	return !ok || id != k.primary
+}
+
+// IsEncrypted reports whether value looks like an envelope
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID returns the ID of the key an envelope was sealed with
+func KeyID(envelope string) (string, bool) {
+	id, _, ok := parseEnvelope(envelope)
+	return id, ok
+}
+
+// parseEnvelope splits an envelope into its key ID and payload
+func parseEnvelope(envelope string) (id, payload string, ok bool) {
+	rest, found := strings.CutPrefix(envelope, envelopePrefix)
+	if !found {
+		return "", "", false
+	}
+	id, payload, found = strings.Cut(rest, ":")
+	if !found || id == "" || payload == "" {
+		return "", "", false
+	}
+	return id, payload, true
+}
@@ -0,0 +1,160 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestKeyring returns a keyring with the given key IDs, the last one
+// primary
+func newTestKeyring(t *testing.T, ids ...string) *Keyring {
+	t.Helper()
+	keys := map[string][]byte{}
+	for i, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, KeySize)
+	}
+	k, err := NewKeyring(ids[len(ids)-1], keys)
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	k := newTestKeyring(t, "old", "new")
+
+	t.Run("round trips with the primary key", func(t *testing.T) {
+		envelope, err := k.Encrypt([]byte("s3cret"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if !strings.HasPrefix(envelope, "enc:v1:new:") || strings.Contains(envelope, "s3cret") {
+			t.Errorf("Unexpected envelope %q", envelope)
+		}
+		if id, ok := KeyID(envelope); !ok || id != "new" {
+			t.Errorf("Expected key ID new, got %q", id)
+		}
+
+		plaintext, err := k.Decrypt(envelope)
+		if err != nil || string(plaintext) != "s3cret" {
+			t.Errorf("Decrypt returned %q, %v", plaintext, err)
+		}
+	})
+
+	t.Run("uses a fresh nonce per value", func(t *testing.T) {
+		a, _ := k.Encrypt([]byte("same"))
+		b, _ := k.Encrypt([]byte("same"))
+		if a == b {
+			t.Error("Expected different envelopes for the same plaintext")
+		}
+	})
+
+	t.Run("decrypts values sealed with older keys", func(t *testing.T) {
+		old := newTestKeyring(t, "old")
+		envelope, err := old.Encrypt([]byte("legacy"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		plaintext, err := k.Decrypt(envelope)
+		if err != nil || string(plaintext) != "legacy" {
+			t.Errorf("Decrypt returned %q, %v", plaintext, err)
+		}
+		if !k.NeedsRotation(envelope) {
+			t.Error("Expected a value sealed with an old key to need rotation")
+		}
+	})
+
+	t.Run("rejects bad envelopes", func(t *testing.T) {
+		envelope, _ := k.Encrypt([]byte("s3cret"))
+		relabelled := strings.Replace(envelope, ":new:", ":old:", 1)
+		payload := envelope[len("enc:v1:new:"):]
+		sealed, _ := base64.RawURLEncoding.DecodeString(payload)
+		sealed[len(sealed)-1] ^= 1
+		tampered := "enc:v1:new:" + base64.RawURLEncoding.EncodeToString(sealed)
+
+		tests := []struct {
+			name     string
+			envelope string
+			want     error
+		}{
+			{"plaintext", "s3cret", ErrMalformed},
+			{"missing payload", "enc:v1:new:", ErrMalformed},
+			{"bad base64", "enc:v1:new:!!!", ErrMalformed},
+			{"unknown key", "enc:v1:gone:" + payload, ErrUnknownKey},
+			{"relabelled key", relabelled, ErrDecrypt},
+			{"tampered payload", tampered, ErrDecrypt},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := k.Decrypt(tt.envelope); !errors.Is(err, tt.want) {
+					t.Errorf("Expected %v, got %v", tt.want, err)
+				}
+			})
+		}
+	})
+
+	t.Run("validates keys", func(t *testing.T) {
+		if _, err := NewKeyring("a", map[string][]byte{"a": make([]byte, 16)}); err == nil {
+			t.Error("Expected a short key to be rejected")
+		}
+		if _, err := NewKeyring("a:b", map[string][]byte{"a:b": make([]byte, KeySize)}); err == nil {
+			t.Error("Expected a key ID with a separator to be rejected")
+		}
+		if _, err := NewKeyring("missing", map[string][]byte{"a": make([]byte, KeySize)}); err == nil {
+			t.Error("Expected a missing primary key to be rejected")
+		}
+	})
+}
+
+func TestKeyringFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	if _, err := LoadKeyring(path); err == nil {
+		t.Fatal("Expected loading a missing keyring to fail")
+	}
+
+	first, err := AddKey(path, "")
+	if err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	if _, err := AddKey(path, "second"); err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	if _, err := AddKey(path, "second"); err == nil {
+		t.Error("Expected a duplicate key ID to be rejected")
+	}
+	if _, err := AddKey(path, "bad:id"); err == nil {
+		t.Error("Expected an invalid key ID to be rejected")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat keyring: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected keyring permissions 0600, got %o", perm)
+	}
+
+	k, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatalf("LoadKeyring failed: %v", err)
+	}
+	if k.PrimaryKeyID() != "second" {
+		t.Errorf("Expected the newest key to be primary, got %q", k.PrimaryKeyID())
+	}
+	if ids := k.KeyIDs(); len(ids) != 2 || ids[0] != first {
+		t.Errorf("Unexpected key IDs %v", ids)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"primary":"a","keys":[{"id":"a","key":"c2hvcnQ="}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyring(path); err == nil {
+		t.Error("Expected a keyring with a short key to be rejected")
+	}
+}
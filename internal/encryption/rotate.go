@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// Column identifies an encrypted column and the unique key its rows are
+// updated by
+type Column struct {
+	Table  string
+	Key    string
+	Column string
+}
+
+// String returns the column as table.column
+func (c Column) String() string {
+	return c.Table + "." + c.Column
+}
+
+// identifierPattern matches the table and column names Rotate builds SQL from
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Column{}
+)
+
+// RegisterColumn records an EncryptedString column so it is re-encrypted by
+// the rotate command. key must be a unique, sortable column such as the
+// primary key. It is meant to be called from init functions and panics on
+// invalid names.
+func RegisterColumn(table, key, column string) {
+	for _, name := range []string{table, key, column} {
+		if !identifierPattern.MatchString(name) {
+			panic(fmt.Sprintf("encryption: invalid identifier %q", name))
+		}
+	}
+	c := Column{Table: table, Key: key, Column: column}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[c.String()] = c
+}
+
+// Columns returns the registered columns sorted by name
+func Columns() []Column {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	columns := make([]Column, 0, len(registry))
+	for _, c := range registry {
+		columns = append(columns, c)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].String() < columns[j].String() })
+	return columns
+}
+
+// LookupColumn returns the registered column named table.column
+func LookupColumn(name string) (Column, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+// RotateResult counts what Rotate did with a column's values
+type RotateResult struct {
+	// Scanned is the number of non-empty values read
+	Scanned int
+	// Rotated is the number of values re-encrypted under the primary key
+	Rotated int
+}
+
+// Rotate re-encrypts every value of c that is not sealed with the primary key
+// of k, including plaintext values written before the column was encrypted.
+// Rows are processed in batches ordered by the key column, each batch in its
+// own transaction, so a large table never holds the write lock for long and
+// an interrupted rotation can simply be run again. Values changed
+// concurrently are left alone, since they were just written with the primary
+// key. progress, if not nil, is called after each batch.
+func Rotate(ctx context.Context, db *database.Database, k *Keyring, c Column, batchSize int, progress func(RotateResult)) (RotateResult, error) {
+	var result RotateResult
+	if batchSize <= 0 {
+		return result, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	type row struct {
+		key   interface{}
+		value string
+	}
+
+	var after interface{}
+	for {
+		query := database.Select(c.Key, c.Column).From(c.Table).Where(c.Column + " <> ''").OrderBy(c.Key).Limit(batchSize)
+		if after != nil {
+			query.WhereExpr(database.Gt(c.Key, after))
+		}
+		sqlStr, args := query.Build()
+
+		rows, err := db.Query(ctx, sqlStr, args...)
+		if err != nil {
+			return result, fmt.Errorf("failed to read %s: %w", c, err)
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.key, &r.value); err != nil {
+				rows.Close()
+				return result, fmt.Errorf("failed to scan %s: %w", c, err)
+			}
+			batch = append(batch, r)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("failed to read %s: %w", c, err)
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		// Seal the values before opening the transaction to keep it short
+		type update struct {
+			key       interface{}
+			old, next string
+		}
+		var updates []update
+		for _, r := range batch {
+			if !k.NeedsRotation(r.value) {
+				continue
+			}
+			plaintext := []byte(r.value)
+			if IsEncrypted(r.value) {
+				if plaintext, err = k.Decrypt(r.value); err != nil {
+					return result, fmt.Errorf("failed to decrypt %s where %s = %v: %w", c, c.Key, r.key, err)
+				}
+			}
+			next, err := k.Encrypt(plaintext)
+			if err != nil {
+				return result, err
+			}
+			updates = append(updates, update{key: r.key, old: r.value, next: next})
+		}
+
+		rotated := 0
+		if len(updates) > 0 {
+			err = db.WithTx(ctx, nil, func(tx *database.Tx) error {
+				rotated = 0
+				for _, u := range updates {
+					sqlStr, args := database.Update(c.Table).Set(c.Column, u.next).
+						WhereExpr(database.And(database.Eq(c.Key, u.key), database.Eq(c.Column, u.old))).Build()
+					res, err := tx.Exec(ctx, sqlStr, args...)
+					if err != nil {
+						return fmt.Errorf("failed to update %s: %w", c, err)
+					}
+					if n, err := res.RowsAffected(); err == nil {
+						rotated += int(n)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return result, err
+			}
+		}
+
+		result.Scanned += len(batch)
+		result.Rotated += rotated
+		if progress != nil {
+			progress(result)
+		}
+		after = batch[len(batch)-1].key
+		if len(batch) < batchSize {
+			return result, nil
+		}
+	}
+}
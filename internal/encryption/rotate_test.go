@@ -0,0 +1,110 @@
+package encryption
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// setupSecretsTable returns a database with a secrets table holding an
+// encrypted column
+func setupSecretsTable(t *testing.T) *database.Database {
+	t.Helper()
+	db := seedtest.NewDatabase(t, "../../migrations", "")
+	_, err := db.Exec(context.Background(), `CREATE TABLE secrets (
+		id INTEGER PRIMARY KEY,
+		value TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return db
+}
+
+func TestRegisterColumn(t *testing.T) {
+	RegisterColumn("secrets", "id", "value")
+	c, ok := LookupColumn("secrets.value")
+	if !ok || c.Key != "id" {
+		t.Errorf("Expected the column to be registered, got %+v", c)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected an invalid identifier to panic")
+		}
+	}()
+	RegisterColumn("secrets; DROP TABLE users", "id", "value")
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	db := setupSecretsTable(t)
+	column := Column{Table: "secrets", Key: "id", Column: "value"}
+
+	// Write values with the old key, plus a plaintext and an empty value
+	old := newTestKeyring(t, "old")
+	useKeyring(t, old)
+	for i, v := range []EncryptedString{"one", "two", "three", "four", "five"} {
+		if _, err := db.Exec(ctx, "INSERT INTO secrets (id, value) VALUES (?, ?)", i+1, v); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if _, err := db.Exec(ctx, "INSERT INTO secrets (id, value) VALUES (?, ?), (?, ?)", 6, "six", 7, ""); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	k := newTestKeyring(t, "old", "new")
+	useKeyring(t, k)
+
+	var batches int
+	result, err := Rotate(ctx, db, k, column, 2, func(RotateResult) { batches++ })
+	if err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if result.Scanned != 6 || result.Rotated != 6 || batches != 3 {
+		t.Errorf("Unexpected result %+v after %d batches", result, batches)
+	}
+
+	rows, err := db.Query(ctx, "SELECT value FROM secrets WHERE value <> '' ORDER BY id")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var raw string
+		var s EncryptedString
+		if err := rows.Scan(&raw); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if id, _ := KeyID(raw); id != "new" {
+			t.Errorf("Expected the value to be sealed with the new key, got %q", raw)
+		}
+		if err := s.Scan(raw); err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		got = append(got, string(s))
+	}
+	if want := "one two three four five six"; strings.Join(got, " ") != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	t.Run("is idempotent", func(t *testing.T) {
+		result, err := Rotate(ctx, db, k, column, 2, nil)
+		if err != nil {
+			t.Fatalf("Rotate failed: %v", err)
+		}
+		if result.Scanned != 6 || result.Rotated != 0 {
+			t.Errorf("Expected nothing to rotate, got %+v", result)
+		}
+	})
+
+	t.Run("fails on values it cannot decrypt", func(t *testing.T) {
+		if _, err := Rotate(ctx, db, newTestKeyring(t, "other"), column, 10, nil); err == nil {
+			t.Error("Expected values sealed with an unknown key to fail")
+		}
+	})
+}
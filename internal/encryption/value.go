@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+)
+
+// defaultKeyring is the keyring EncryptedString values use
+var defaultKeyring atomic.Pointer[Keyring]
+
+// SetKeyring sets the keyring EncryptedString values are encrypted and
+// decrypted with. Pass nil to disable encryption.
+func SetKeyring(k *Keyring) {
+	defaultKeyring.Store(k)
+}
+
+// CurrentKeyring returns the keyring set by SetKeyring, or nil
+func CurrentKeyring() *Keyring {
+	return defaultKeyring.Load()
+}
+
+// EncryptedString is a string that is stored encrypted. Use it for model
+// fields holding secrets or personal data: the value is sealed when it is
+// written to the database and opened again when it is scanned, so the rest of
+// the code works with plaintext. Empty strings are stored as is.
+//
+// Encryption uses the keyring set by SetKeyring; writing or reading a
+// non-empty value without one fails. Register the column with RegisterColumn
+// so the rotate command re-encrypts it after a key rotation.
+//
+// Values that are not envelopes scan as they are, so an existing plaintext
+// column can be switched to EncryptedString: new writes are sealed, and the
+// rotate command seals the values written before.
+type EncryptedString string
+
+// Value implements driver.Valuer by encrypting the string
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	k := CurrentKeyring()
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	return k.Encrypt([]byte(s))
+}
+
+// Scan implements sql.Scanner by decrypting the stored envelope. NULL scans
+// as the empty string and plaintext values as themselves.
+func (s *EncryptedString) Scan(src interface{}) error {
+	var stored string
+	switch v := src.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EncryptedString", src)
+	}
+	if !IsEncrypted(stored) {
+		// Written before the column was encrypted
+		*s = EncryptedString(stored)
+		return nil
+	}
+
+	k := CurrentKeyring()
+	if k == nil {
+		return ErrNoKeyring
+	}
+	plaintext, err := k.Decrypt(stored)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// String returns the plaintext
+func (s EncryptedString) String() string {
+	return string(s)
+}
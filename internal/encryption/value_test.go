@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"errors"
+	"testing"
+)
+
+// useKeyring sets the default keyring for the duration of the test
+func useKeyring(t *testing.T, k *Keyring) {
+	t.Helper()
+	previous := CurrentKeyring()
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(previous) })
+}
+
+func TestEncryptedString(t *testing.T) {
+	k := newTestKeyring(t, "primary")
+
+	t.Run("round trips through Value and Scan", func(t *testing.T) {
+		useKeyring(t, k)
+		v, err := EncryptedString("JBSWY3DPEHPK3PXP").Value()
+		if err != nil {
+			t.Fatalf("Value failed: %v", err)
+		}
+		stored, ok := v.(string)
+		if !ok || !IsEncrypted(stored) {
+			t.Fatalf("Expected an envelope, got %#v", v)
+		}
+
+		for _, src := range []interface{}{stored, []byte(stored)} {
+			var s EncryptedString
+			if err := s.Scan(src); err != nil || s != "JBSWY3DPEHPK3PXP" {
+				t.Errorf("Scan(%T) returned %q, %v", src, s, err)
+			}
+		}
+	})
+
+	t.Run("stores empty values as is", func(t *testing.T) {
+		useKeyring(t, nil)
+		if v, err := EncryptedString("").Value(); err != nil || v != "" {
+			t.Errorf("Expected an empty value, got %#v, %v", v, err)
+		}
+		s := EncryptedString("stale")
+		if err := s.Scan(nil); err != nil || s != "" {
+			t.Errorf("Expected NULL to scan as empty, got %q, %v", s, err)
+		}
+	})
+
+	t.Run("requires a keyring", func(t *testing.T) {
+		useKeyring(t, k)
+		stored, _ := EncryptedString("secret").Value()
+
+		SetKeyring(nil)
+		if _, err := EncryptedString("secret").Value(); !errors.Is(err, ErrNoKeyring) {
+			t.Errorf("Expected ErrNoKeyring from Value, got %v", err)
+		}
+		var s EncryptedString
+		if err := s.Scan(stored); !errors.Is(err, ErrNoKeyring) {
+			t.Errorf("Expected ErrNoKeyring from Scan, got %v", err)
+		}
+	})
+
+	t.Run("reads plaintext written before encryption", func(t *testing.T) {
+		useKeyring(t, nil)
+		var s EncryptedString
+		if err := s.Scan("not encrypted"); err != nil || s != "not encrypted" {
+			t.Errorf("Expected plaintext to scan as is, got %q, %v", s, err)
+		}
+	})
+
+	t.Run("rejects malformed envelopes", func(t *testing.T) {
+		useKeyring(t, k)
+		var s EncryptedString
+		if err := s.Scan("enc:v1:primary:"); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Expected ErrMalformed, got %v", err)
+		}
+		if err := s.Scan(42); err == nil {
+			t.Error("Expected scanning an integer to fail")
+		}
+	})
+}
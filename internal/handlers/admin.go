@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+)
+
+const (
+	// defaultOutboxListLimit is the number of events listed by default
+	defaultOutboxListLimit = 50
+	// maxOutboxListLimit caps the number of events listed
+	maxOutboxListLimit = 500
+)
+
+// OutboxStatusResponse is the data of GET /api/admin/outbox
+type OutboxStatusResponse struct {
+	Stats  *outbox.Stats  `json:"stats"`
+	Events []outbox.Event `json:"events"`
+}
+
+// requireAdmin reports whether the request's principal is an admin,
+// responding with 403 if not
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if auth.IsAdmin(principal) {
+		return true
+	}
+	ErrorResponseFunc(w, http.StatusForbidden, "Forbidden")
+	return false
+}
+
+// OutboxStatus handles GET /api/admin/outbox - returns outbox event counts and
+// the latest events with the status given by the status parameter (default
+// dead, i.e. the dead letters); admins only
+func (h *Handlers) OutboxStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	status := outbox.StatusDead
+	if v := query.Get("status"); v != "" {
+		status = outbox.Status(v)
+		if status != outbox.StatusPending && status != outbox.StatusDelivered && status != outbox.StatusDead {
+			ValidationError(w, "status", "must be pending, delivered or dead")
+			return
+		}
+	}
+	limit := defaultOutboxListLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			ValidationError(w, "limit", "must be a positive integer")
+			return
+		}
+		limit = min(n, maxOutboxListLimit)
+	}
+
+	stats, err := h.Outbox.Stats(r.Context())
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to read outbox stats: %w", err))
+		return
+	}
+	events, err := h.Outbox.List(r.Context(), status, limit)
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to list outbox events: %w", err))
+		return
+	}
+	JSONResponse(w, http.StatusOK, OutboxStatusResponse{Stats: stats, Events: events})
+}
+
+// RetryOutboxEvent handles POST /api/admin/outbox/{id}/retry - requeues a
+// dead-lettered event for delivery; admins only
+func (h *Handlers) RetryOutboxEvent(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		ValidationError(w, "id", "must be a positive integer")
+		return
+	}
+	if err := h.Outbox.Retry(r.Context(), id); err != nil {
+		RepositoryErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
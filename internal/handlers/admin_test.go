@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/models"
+)
+
+// adminAPI serves the admin routes as principal without JWT middleware
+func adminAPI(h *Handlers, principal, method, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/outbox", h.OutboxStatus)
+	mux.HandleFunc("POST /api/admin/outbox/{id}/retry", h.RetryOutboxEvent)
+
+	req := httptest.NewRequest(method, target, nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestOutboxAdminAPI(t *testing.T) {
+	h := setupUserHandlers(t)
+
+	cfg := &config.Config{}
+	cfg.Auth.AdminUsers = "root"
+	auth.SetConfigForTesting(cfg)
+	defer auth.ResetConfigForTesting()
+
+	if err := h.Users.Create(context.Background(), &models.User{Username: "ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("admins only", func(t *testing.T) {
+		if rr := adminAPI(h, "ada", http.MethodGet, "/api/admin/outbox"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+		if rr := adminAPI(h, "ada", http.MethodPost, "/api/admin/outbox/1/retry"); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+	})
+
+	t.Run("stats and events", func(t *testing.T) {
+		rr := adminAPI(h, "root", http.MethodGet, "/api/admin/outbox?status=pending")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Data OutboxStatusResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		if response.Data.Stats.Pending != 1 || len(response.Data.Events) != 1 || response.Data.Events[0].Topic != models.UserCreatedEvent {
+			t.Errorf("Unexpected response %+v", response.Data)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, target := range []string{"/api/admin/outbox?status=lost", "/api/admin/outbox?limit=0"} {
+			if rr := adminAPI(h, "root", http.MethodGet, target); rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", target, rr.Code)
+			}
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		if rr := adminAPI(h, "root", http.MethodPost, "/api/admin/outbox/1/retry"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for an event that is not dead, got %d", rr.Code)
+		}
+		if rr := adminAPI(h, "root", http.MethodPost, "/api/admin/outbox/abc/retry"); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", rr.Code)
+		}
+	})
+}
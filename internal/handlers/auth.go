@@ -28,7 +28,10 @@ func LoginPage(tpl *template.Template) http.HandlerFunc {
 		}
 
 		// Execute template
-		if err := tpl.ExecuteTemplate(w, "login.html", data); err != nil {
+		err := renderTraced(r.Context(), "login.html", func() error {
+			return tpl.ExecuteTemplate(w, "login.html", data)
+		})
+		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -107,7 +110,10 @@ func ProtectedPage(tpl *template.Template) http.HandlerFunc {
 		}
 
 		// Execute template
-		if err := tpl.ExecuteTemplate(w, "protected.html", data); err != nil {
+		err := renderTraced(r.Context(), "protected.html", func() error {
+			return tpl.ExecuteTemplate(w, "protected.html", data)
+		})
+		if err != nil {
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrPreconditionFailed means an If-Match header can never match the resource
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// VersionETag returns the strong ETag for a record version, e.g. "3"
+func VersionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// SetVersionETag sets the ETag header for a record version; call it before
+// writing the response
+func SetVersionETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", VersionETag(version))
+}
+
+// IfMatchVersion returns the record version required by the request's
+// If-Match header, so it can be checked atomically by the repository's
+// versioned Update. ok is false when there is no header or it is "*". The
+// header must carry a single ETag from VersionETag; weak or foreign ETags can
+// never match and return ErrPreconditionFailed.
+func IfMatchVersion(r *http.Request) (version int, ok bool, err error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+
+	tag := header
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return 0, false, ErrPreconditionFailed
+	}
+	version, err = strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, false, ErrPreconditionFailed
+	}
+	return version, true, nil
+}
+
+// PreconditionFailedResponse sends a 412 response for a failed If-Match check
+func PreconditionFailedResponse(w http.ResponseWriter) {
+	ErrorResponseWithDetails(w, http.StatusPreconditionFailed, "Precondition failed",
+		"the resource has been modified; fetch it again and retry with its current ETag")
+}
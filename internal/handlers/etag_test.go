@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionETag(t *testing.T) {
+	rr := httptest.NewRecorder()
+	SetVersionETag(rr, 3)
+	if got := rr.Header().Get("ETag"); got != `"3"` {
+		t.Errorf("Expected ETag \"3\", got %s", got)
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion int
+		wantOK      bool
+		wantErr     error
+	}{
+		{name: "absent"},
+		{name: "any", header: "*"},
+		{name: "version", header: `"7"`, wantVersion: 7, wantOK: true},
+		{name: "round trip", header: VersionETag(12), wantVersion: 12, wantOK: true},
+		{name: "weak", header: `W/"7"`, wantErr: ErrPreconditionFailed},
+		{name: "unquoted", header: "7", wantErr: ErrPreconditionFailed},
+		{name: "foreign", header: `"abc"`, wantErr: ErrPreconditionFailed},
+		{name: "list", header: `"6", "7"`, wantErr: ErrPreconditionFailed},
+		{name: "zero", header: `"0"`, wantErr: ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/users/1", nil)
+			if tt.header != "" {
+				req.Header.Set("If-Match", tt.header)
+			}
+
+			version, ok, err := IfMatchVersion(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if version != tt.wantVersion || ok != tt.wantOK {
+				t.Errorf("Expected (%d, %v), got (%d, %v)", tt.wantVersion, tt.wantOK, version, ok)
+			}
+		})
+	}
+}
+
+func TestPreconditionFailedResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	PreconditionFailedResponse(rr)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412, got %d", rr.Code)
+	}
+}
@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/tediscript/gostarterkit/internal/database"
 	"github.com/tediscript/gostarterkit/internal/health"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+	"github.com/tediscript/gostarterkit/internal/privacy"
+	"github.com/tediscript/gostarterkit/internal/tracing"
 )
 
 // TemplateCache interface for template rendering
@@ -24,6 +30,26 @@ type Handlers struct {
 	Templates    TemplateCache
 	TemplatesDir string
 	Health       *health.HealthChecker
+	// Users backs the /api/users routes, which are only registered when set
+	Users *models.UserRepository
+	// Outbox backs the /api/admin/outbox routes, which are only registered
+	// when set
+	Outbox *outbox.Store
+	// Transfer is the database the /api/admin/export and /api/admin/import
+	// routes move rows in and out of; they are only registered when set
+	Transfer *database.Database
+	// Privacy backs the /api/users/{id}/export and /api/users/{id}/erasure
+	// routes, which are only registered when it and Users are set
+	Privacy *privacy.Store
+}
+
+// renderTraced runs render, which executes the template name, in a span
+func renderTraced(ctx context.Context, name string, render func() error) error {
+	_, span := tracing.Start(ctx, "render "+name, tracing.WithAttributes(tracing.String("template.name", name)))
+	defer span.End()
+	err := render()
+	span.RecordError(err)
+	return err
 }
 
 // New creates a new Handlers instance
@@ -53,7 +79,10 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 	// Render home page template
 	// Note: For now, we're using base.html which includes the content block
 	// In the future, we can implement template inheritance with home.html
-	if err := h.Templates.RenderTemplate(w, "base.html", data); err != nil {
+	err := renderTraced(r.Context(), "base.html", func() error {
+		return h.Templates.RenderTemplate(w, "base.html", data)
+	})
+	if err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// maxLogLevelDuration caps how long a level change lasts before reverting, so
+// a forgotten debug level does not flood the logs indefinitely
+const maxLogLevelDuration = 24 * time.Hour
+
+// SetLogLevelRequest is the body of PUT /api/admin/log-level
+type SetLogLevelRequest struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// Logger names the logger to change; empty changes the default level
+	Logger string `json:"logger"`
+	// Duration is how long the change lasts before reverting, e.g. "15m";
+	// empty keeps it until changed again
+	Duration string `json:"duration"`
+}
+
+// GetLogLevel handles GET /api/admin/log-level - returns the current log level
+// and the overridden levels of named loggers; admins only
+func (h *Handlers) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	JSONResponse(w, http.StatusOK, logger.Levels())
+}
+
+// SetLogLevel handles PUT /api/admin/log-level - changes the default log level
+// or that of a named logger, optionally reverting after a duration; admins
+// only
+func (h *Handlers) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req SetLogLevelRequest
+	if err := DecodeJSONBody(w, r, &req); err != nil {
+		ErrorResponseFunc(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	level, ok := logger.LookupLevel(req.Level)
+	if !ok {
+		ValidationError(w, "level", "must be debug, info, warn or error")
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxLogLevelDuration {
+			ValidationError(w, "duration", "must be a positive duration of at most 24h, e.g. 15m")
+			return
+		}
+		duration = d
+	}
+
+	if req.Logger == "" {
+		logger.SetLevel(level, duration)
+	} else {
+		logger.SetLoggerLevel(req.Logger, level, duration)
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	logger.FromContext(r.Context()).Warn("Log level changed",
+		"principal", principal,
+		"logger", req.Logger,
+		"level", logger.LevelName(level),
+		"duration", duration,
+	)
+	JSONResponse(w, http.StatusOK, logger.Levels())
+}
+
+// ResetLogLevel handles DELETE /api/admin/log-level - restores the configured
+// log level, or with the logger parameter removes that logger's override;
+// admins only
+func (h *Handlers) ResetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.URL.Query().Get("logger")
+	if name == "" {
+		logger.ResetLevel()
+	} else {
+		logger.ResetLoggerLevel(name)
+	}
+	JSONResponse(w, http.StatusOK, logger.Levels())
+}
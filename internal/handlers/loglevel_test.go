@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// logLevelAPI serves the log level routes as principal without JWT middleware
+func logLevelAPI(principal, method, target, body string) *httptest.ResponseRecorder {
+	h := &Handlers{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/log-level", h.GetLogLevel)
+	mux.HandleFunc("PUT /api/admin/log-level", h.SetLogLevel)
+	mux.HandleFunc("DELETE /api/admin/log-level", h.ResetLogLevel)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLogLevelAdminAPI(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth.AdminUsers = "root"
+	auth.SetConfigForTesting(cfg)
+	defer auth.ResetConfigForTesting()
+	defer logger.ResetLevel()
+	defer logger.ResetLoggerLevel("database")
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) logger.LevelStatus {
+		t.Helper()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Data logger.LevelStatus `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		return response.Data
+	}
+
+	t.Run("admins only", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			if rr := logLevelAPI("ada", method, "/api/admin/log-level", `{"level":"debug"}`); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected 403, got %d", method, rr.Code)
+			}
+		}
+	})
+
+	t.Run("sets the default level", func(t *testing.T) {
+		status := decode(t, logLevelAPI("root", http.MethodPut, "/api/admin/log-level", `{"level":"debug","duration":"15m"}`))
+		if status.Level != "debug" || status.RevertAt == nil {
+			t.Errorf("Expected debug with a pending revert, got %+v", status)
+		}
+		if status := decode(t, logLevelAPI("root", http.MethodGet, "/api/admin/log-level", "")); status.Level != "debug" {
+			t.Errorf("Expected GET to report debug, got %+v", status)
+		}
+		if status := decode(t, logLevelAPI("root", http.MethodDelete, "/api/admin/log-level", "")); status.Level != status.Configured || status.RevertAt != nil {
+			t.Errorf("Expected the configured level restored, got %+v", status)
+		}
+	})
+
+	t.Run("sets a named logger's level", func(t *testing.T) {
+		status := decode(t, logLevelAPI("root", http.MethodPut, "/api/admin/log-level", `{"level":"debug","logger":"database"}`))
+		if len(status.Loggers) != 1 || status.Loggers[0].Name != "database" || status.Loggers[0].Level != "debug" {
+			t.Errorf("Expected the database logger overridden, got %+v", status)
+		}
+		status = decode(t, logLevelAPI("root", http.MethodDelete, "/api/admin/log-level?logger=database", ""))
+		if len(status.Loggers) != 0 {
+			t.Errorf("Expected the override removed, got %+v", status)
+		}
+	})
+
+	t.Run("validates the request", func(t *testing.T) {
+		for _, body := range []string{
+			`{"level":"verbose"}`,
+			`{"level":"debug","duration":"forever"}`,
+			`{"level":"debug","duration":"48h"}`,
+			`{"level":"debug","unknown":true}`,
+		} {
+			if rr := logLevelAPI("root", http.MethodPut, "/api/admin/log-level", body); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", body, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// ExportUserData handles GET /api/users/{id}/export - downloads a ZIP archive
+// of the personal data held about a user; the user or an admin only
+func (h *Handlers) ExportUserData(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	// Build the archive before responding, so a failure can still be
+	// reported with an error status
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	var buf bytes.Buffer
+	if err := h.Privacy.Export(r.Context(), user, principal, &buf); err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to export user data: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("user-%d-export.zip", user.ID)))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		logger.FromContext(r.Context()).Error("Failed to send user data export", "user_id", user.ID, "error", err.Error())
+	}
+}
+
+// RequestErasure handles POST /api/users/{id}/erasure - schedules the erasure
+// of a user's account at the end of the grace period and returns the request;
+// the user or an admin only
+func (h *Handlers) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	req, err := h.Privacy.RequestErasure(r.Context(), user, principal)
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to request erasure: %w", err))
+		return
+	}
+	JSONResponse(w, http.StatusAccepted, req)
+}
+
+// GetErasure handles GET /api/users/{id}/erasure - returns the pending
+// erasure request of a user; the user or an admin only
+func (h *Handlers) GetErasure(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	req, err := h.Privacy.PendingErasure(r.Context(), user.ID)
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to get erasure request: %w", err))
+		return
+	}
+	JSONResponse(w, http.StatusOK, req)
+}
+
+// CancelErasure handles DELETE /api/users/{id}/erasure - cancels the pending
+// erasure request of a user; the user or an admin only
+func (h *Handlers) CancelErasure(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if err := h.Privacy.CancelErasure(r.Context(), user.ID, principal); err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to cancel erasure: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
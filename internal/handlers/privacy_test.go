@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/privacy"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// privacyAPI serves the export and erasure routes as principal without JWT
+// middleware
+func privacyAPI(h *Handlers, principal, method, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/{id}/export", h.ExportUserData)
+	mux.HandleFunc("POST /api/users/{id}/erasure", h.RequestErasure)
+	mux.HandleFunc("GET /api/users/{id}/erasure", h.GetErasure)
+	mux.HandleFunc("DELETE /api/users/{id}/erasure", h.CancelErasure)
+
+	req := httptest.NewRequest(method, target, nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPrivacyAPI(t *testing.T) {
+	db := seedtest.NewDatabase(t, "../../migrations", "")
+	h := New(&mockTemplateCache{}, "./templates", nil)
+	h.Users = models.NewUserRepository(db)
+	h.Privacy = privacy.NewStore(db, time.Hour)
+
+	cfg := &config.Config{}
+	cfg.Auth.AdminUsers = "root"
+	auth.SetConfigForTesting(cfg)
+	defer auth.ResetConfigForTesting()
+
+	ada := &models.User{Username: "ada", Email: "ada@example.com"}
+	if err := h.Users.Create(context.Background(), ada); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("the user or an admin only", func(t *testing.T) {
+		for _, route := range []struct{ method, target string }{
+			{http.MethodGet, "/api/users/1/export"},
+			{http.MethodPost, "/api/users/1/erasure"},
+			{http.MethodGet, "/api/users/1/erasure"},
+			{http.MethodDelete, "/api/users/1/erasure"},
+		} {
+			if rr := privacyAPI(h, "alan", route.method, route.target); rr.Code != http.StatusForbidden {
+				t.Errorf("%s %s: expected 403, got %d", route.method, route.target, rr.Code)
+			}
+		}
+	})
+
+	t.Run("exports a ZIP archive", func(t *testing.T) {
+		rr := privacyAPI(h, "ada", http.MethodGet, "/api/users/1/export")
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("Expected a ZIP export, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+		}
+		if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="user-1-export.zip"` {
+			t.Errorf("Unexpected Content-Disposition %q", cd)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+		if err != nil {
+			t.Fatalf("Failed to open export: %v", err)
+		}
+		if len(zr.File) == 0 || zr.File[0].Name != "manifest.json" {
+			t.Errorf("Expected the manifest first in the export")
+		}
+	})
+
+	t.Run("no pending erasure", func(t *testing.T) {
+		if rr := privacyAPI(h, "ada", http.MethodGet, "/api/users/1/erasure"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rr.Code)
+		}
+		if rr := privacyAPI(h, "ada", http.MethodDelete, "/api/users/1/erasure"); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rr.Code)
+		}
+	})
+
+	rr := privacyAPI(h, "ada", http.MethodPost, "/api/users/1/erasure")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var response struct {
+		Data privacy.ErasureRequest `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	requested := response.Data
+	if requested.Status != privacy.ErasurePending || requested.RequestedBy != "ada" {
+		t.Errorf("Unexpected request %+v", requested)
+	}
+
+	if rr := privacyAPI(h, "ada", http.MethodPost, "/api/users/1/erasure"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second request, got %d", rr.Code)
+	}
+	if rr := privacyAPI(h, "root", http.MethodGet, "/api/users/1/erasure"); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rr.Code)
+	}
+	if rr := privacyAPI(h, "root", http.MethodDelete, "/api/users/1/erasure"); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rr.Code)
+	}
+	if rr := privacyAPI(h, "ada", http.MethodGet, "/api/users/1/erasure"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after cancelling, got %d", rr.Code)
+	}
+}
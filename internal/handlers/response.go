@@ -2,8 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/validation"
 )
 
 // ErrorResponse represents a standardized error response
@@ -116,6 +126,21 @@ func ValidationError(w http.ResponseWriter, field, message string) {
 	}
 }
 
+// ValidationErrorsResponse sends a JSON error response listing every failed
+// field of a validation.ValidationErrors, or the error text otherwise
+func ValidationErrorsResponse(w http.ResponseWriter, err error) {
+	details := err.Error()
+	var errs validation.ValidationErrors
+	if errors.As(err, &errs) {
+		fields := make([]string, len(errs))
+		for i, e := range errs {
+			fields[i] = e.Error()
+		}
+		details = strings.Join(fields, "; ")
+	}
+	ErrorResponseWithDetails(w, http.StatusBadRequest, "Validation failed", details)
+}
+
 // DecodeJSONBody decodes a JSON request body into the provided struct
 func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 	decoder := json.NewDecoder(r.Body)
@@ -127,3 +152,116 @@ func DecodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 
 	return nil
 }
+
+// ParsePageRequest reads the limit, cursor, sort and include_total query
+// parameters of a list request. Errors wrap database.ErrInvalid, so they can
+// be passed to RepositoryErrorResponse.
+func ParsePageRequest(r *http.Request) (models.PageRequest, error) {
+	query := r.URL.Query()
+	req := models.PageRequest{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return req, fmt.Errorf("%w: limit must be a positive integer", database.ErrInvalid)
+		}
+		req.Limit = limit
+	}
+	if v := query.Get("include_total"); v != "" {
+		includeTotal, err := strconv.ParseBool(v)
+		if err != nil {
+			return req, fmt.Errorf("%w: include_total must be a boolean", database.ErrInvalid)
+		}
+		req.IncludeTotal = includeTotal
+	}
+	return req, nil
+}
+
+// StatusFromError maps repository errors to HTTP status codes: ErrNotFound is
+// 404, ErrConflict is 409, ErrInvalid is 400 and anything else is 500
+func StatusFromError(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RepositoryErrorResponse sends a JSON error response for a repository error
+// with the status from StatusFromError. Internal errors are logged and not
+// exposed to the client.
+func RepositoryErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusFromError(err)
+
+	switch status {
+	case http.StatusNotFound:
+		ErrorResponseFunc(w, status, "Resource not found")
+
+	case http.StatusConflict:
+		details := "conflicts with existing data"
+		var conflict *database.ConflictError
+		if errors.As(err, &conflict) && len(conflict.Columns) > 0 {
+			details = strings.Join(conflict.Columns, ", ") + " already exists"
+		} else if errors.Is(err, database.ErrVersionConflict) {
+			details = "modified by another request"
+		}
+		ErrorResponseWithDetails(w, status, "Conflict", details)
+
+	case http.StatusBadRequest:
+		details := invalidDetails(err)
+		var invalid *database.InvalidError
+		if errors.As(err, &invalid) {
+			if invalid.Constraint == "not null" && len(invalid.Columns) > 0 {
+				details = strings.Join(invalid.Columns, ", ") + " is required"
+			} else {
+				details = "value violates a constraint"
+			}
+		}
+		ErrorResponseWithDetails(w, status, "Invalid data", details)
+
+	default:
+		logger.FromContext(r.Context()).Error("Repository error",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
+		ErrorResponseFunc(w, status, "Internal server error")
+	}
+}
+
+// invalidDetails returns the message an ErrInvalid error was created with,
+// e.g. "limit must be a positive integer" from
+// fmt.Errorf("%w: limit must be a positive integer", database.ErrInvalid),
+// without the context added by wrapping it further
+func invalidDetails(err error) string {
+	prefix := database.ErrInvalid.Error() + ": "
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if wrapsDirectly(e, database.ErrInvalid) {
+			if msg, ok := strings.CutPrefix(e.Error(), prefix); ok {
+				return msg
+			}
+			break
+		}
+	}
+	return "the request contains invalid data"
+}
+
+// wrapsDirectly reports whether err wraps target itself, not through another
+// wrapping error
+func wrapsDirectly(err, target error) bool {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap() == target
+	case interface{ Unwrap() []error }:
+		return slices.Contains(e.Unwrap(), target)
+	}
+	return false
+}
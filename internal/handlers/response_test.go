@@ -3,9 +3,14 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
 )
 
 // TestJSONResponse tests the JSONResponse helper function
@@ -506,3 +511,138 @@ func TestEmptyStringData(t *testing.T) {
 		})
 	}
 }
+
+// TestRepositoryErrorResponse tests mapping repository errors to HTTP responses
+func TestRepositoryErrorResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantError   string
+		wantDetails string
+	}{
+		{
+			name:       "not found",
+			err:        fmt.Errorf("user 7: %w", database.ErrNotFound),
+			wantStatus: http.StatusNotFound,
+			wantError:  "Resource not found",
+		},
+		{
+			name:        "conflict with column",
+			err:         fmt.Errorf("failed to create user: %w", &database.ConflictError{Constraint: "unique", Table: "users", Columns: []string{"email"}}),
+			wantStatus:  http.StatusConflict,
+			wantError:   "Conflict",
+			wantDetails: "email already exists",
+		},
+		{
+			name:        "conflict without column",
+			err:         &database.ConflictError{Constraint: "foreign key"},
+			wantStatus:  http.StatusConflict,
+			wantError:   "Conflict",
+			wantDetails: "conflicts with existing data",
+		},
+		{
+			name:        "version conflict",
+			err:         fmt.Errorf("users 1 at version 2: %w", database.ErrVersionConflict),
+			wantStatus:  http.StatusConflict,
+			wantError:   "Conflict",
+			wantDetails: "modified by another request",
+		},
+		{
+			name:        "not null violation",
+			err:         &database.InvalidError{Constraint: "not null", Table: "users", Columns: []string{"email"}},
+			wantStatus:  http.StatusBadRequest,
+			wantError:   "Invalid data",
+			wantDetails: "email is required",
+		},
+		{
+			name:        "plain invalid error",
+			err:         fmt.Errorf("%w: email must contain @", database.ErrInvalid),
+			wantStatus:  http.StatusBadRequest,
+			wantError:   "Invalid data",
+			wantDetails: "email must contain @",
+		},
+		{
+			name:        "wrapped invalid error keeps only its message",
+			err:         fmt.Errorf("failed to request erasure: %w", fmt.Errorf("%w: %w", database.ErrInvalid, errors.New("password is wrong"))),
+			wantStatus:  http.StatusBadRequest,
+			wantError:   "Invalid data",
+			wantDetails: "password is wrong",
+		},
+		{
+			name:        "bare invalid error",
+			err:         fmt.Errorf("failed to read row from driver xyz: %w", database.ErrInvalid),
+			wantStatus:  http.StatusBadRequest,
+			wantError:   "Invalid data",
+			wantDetails: "the request contains invalid data",
+		},
+		{
+			name:       "internal error is not exposed",
+			err:        errors.New("disk I/O error at /var/lib/app.db"),
+			wantStatus: http.StatusInternalServerError,
+			wantError:  "Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusFromError(tt.err); got != tt.wantStatus {
+				t.Errorf("StatusFromError() = %v, want %v", got, tt.wantStatus)
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/users/7", nil)
+			RepositoryErrorResponse(rr, req, tt.err)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("RepositoryErrorResponse() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			var got ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode JSON: %v", err)
+			}
+			if got.Error != tt.wantError || got.Details != tt.wantDetails {
+				t.Errorf("RepositoryErrorResponse() = %+v, want error %q details %q", got, tt.wantError, tt.wantDetails)
+			}
+		})
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    models.PageRequest
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: models.PageRequest{}},
+		{
+			name:  "all parameters",
+			query: "limit=25&cursor=abc&sort=-created_at&include_total=true",
+			want:  models.PageRequest{Limit: 25, Cursor: "abc", Sort: "-created_at", IncludeTotal: true},
+		},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: true},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "invalid include_total", query: "include_total=maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users?"+tt.query, nil)
+			got, err := ParsePageRequest(req)
+			if tt.wantErr {
+				if !errors.Is(err, database.ErrInvalid) {
+					t.Errorf("Expected ErrInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/transfer"
+)
+
+// maxTransferBatchSize caps the batch parameter of exports and imports
+const maxTransferBatchSize = 5000
+
+// ExportModel handles GET /api/admin/export/{model} - streams every row of a
+// registered model as JSON Lines, flushing after each batch of the batch
+// parameter's size; admins only
+func (h *Handlers) ExportModel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("model")
+	if !transfer.Registered(name) {
+		ErrorResponseFunc(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	batch, ok := parseBatchSize(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".jsonl"))
+	w.WriteHeader(http.StatusOK)
+
+	log := logger.FromContext(r.Context())
+	buf := bufio.NewWriter(w)
+	rc := http.NewResponseController(w)
+	n, err := transfer.Export(r.Context(), h.Transfer, name, buf, batch, func(int) {
+		if buf.Flush() == nil {
+			rc.Flush()
+		}
+	})
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		// The status is already sent; the client sees a truncated stream
+		log.Error("Export failed", "model", name, "rows", n, "error", err.Error())
+		return
+	}
+	log.Info("Exported model", "model", name, "rows", n)
+}
+
+// ImportModel handles POST /api/admin/import/{model} - reads JSON Lines rows
+// of a registered model from the request body and writes them. The mode
+// parameter is insert (the default) or upsert, and dry_run=true validates
+// every row and rolls back, reporting all problems in the returned stats;
+// admins only
+func (h *Handlers) ImportModel(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	name := r.PathValue("model")
+	if !transfer.Registered(name) {
+		ErrorResponseFunc(w, http.StatusNotFound, "Resource not found")
+		return
+	}
+	query := r.URL.Query()
+	opts := transfer.Options{Mode: transfer.Mode(query.Get("mode"))}
+	if opts.Mode != "" && opts.Mode != transfer.ModeInsert && opts.Mode != transfer.ModeUpsert {
+		ValidationError(w, "mode", "must be insert or upsert")
+		return
+	}
+	if v := query.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			ValidationError(w, "dry_run", "must be a boolean")
+			return
+		}
+		opts.DryRun = dryRun
+	}
+	batch, ok := parseBatchSize(w, r)
+	if !ok {
+		return
+	}
+	opts.BatchSize = batch
+
+	log := logger.FromContext(r.Context())
+	opts.Progress = func(s transfer.Stats) {
+		log.Info("Import progress", "model", name, "lines", s.Lines, "written", s.Written, "invalid", s.Invalid, "dry_run", s.DryRun)
+	}
+
+	stats, err := transfer.Import(r.Context(), h.Transfer, name, r.Body, opts)
+	if err != nil {
+		written := 0
+		if stats != nil {
+			written = stats.Written
+		}
+		// Invalid and conflicting rows are the client's to fix
+		if status := StatusFromError(err); status != http.StatusInternalServerError {
+			ErrorResponseWithDetails(w, status, "Import failed", fmt.Sprintf("%v; %d rows were imported before the error", err, written))
+			return
+		}
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to import %s after %d rows: %w", name, written, err))
+		return
+	}
+	log.Info("Imported model", "model", name, "lines", stats.Lines, "written", stats.Written, "invalid", stats.Invalid, "dry_run", stats.DryRun)
+	JSONResponse(w, http.StatusOK, stats)
+}
+
+// parseBatchSize reads the batch query parameter, responding with 400 if it
+// is invalid
+func parseBatchSize(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("batch")
+	if v == "" {
+		return transfer.DefaultBatchSize, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		ValidationError(w, "batch", "must be a positive integer")
+		return 0, false
+	}
+	return min(n, maxTransferBatchSize), true
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+	"github.com/tediscript/gostarterkit/internal/transfer"
+)
+
+// transferAPI serves the export and import routes as principal without JWT
+// middleware
+func transferAPI(h *Handlers, principal, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/admin/export/{model}", h.ExportModel)
+	mux.HandleFunc("POST /api/admin/import/{model}", h.ImportModel)
+
+	req := httptest.NewRequest(method, target, body)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+// setupTransferHandlers returns handlers with the user and transfer routes
+// backed by a fresh database
+func setupTransferHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	db := seedtest.NewDatabase(t, "../../migrations", "")
+	h := New(&mockTemplateCache{}, "./templates", nil)
+	h.Users = models.NewUserRepository(db)
+	h.Transfer = db
+	return h
+}
+
+func TestTransferAdminAPI(t *testing.T) {
+	source := setupTransferHandlers(t)
+	target := setupTransferHandlers(t)
+
+	cfg := &config.Config{}
+	cfg.Auth.AdminUsers = "root"
+	auth.SetConfigForTesting(cfg)
+	defer auth.ResetConfigForTesting()
+
+	for _, u := range []models.User{
+		{Username: "ada", Email: "ada@example.com"},
+		{Username: "alan", Email: "alan@example.com"},
+	} {
+		if err := source.Users.Create(context.Background(), &u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	t.Run("admins only", func(t *testing.T) {
+		if rr := transferAPI(source, "ada", http.MethodGet, "/api/admin/export/users", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+		if rr := transferAPI(source, "ada", http.MethodPost, "/api/admin/import/users", strings.NewReader("")); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+	})
+
+	rr := transferAPI(source, "root", http.MethodGet, "/api/admin/export/users?batch=1", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected a JSON Lines export, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	export := rr.Body.String()
+	if lines := strings.Split(strings.TrimSpace(export), "\n"); len(lines) != 2 || !strings.Contains(lines[0], `"username":"ada"`) {
+		t.Fatalf("Unexpected export %q", export)
+	}
+
+	// importUsers posts body to the import route and decodes the stats
+	importUsers := func(t *testing.T, query, body string) (int, transfer.Stats) {
+		t.Helper()
+		rr := transferAPI(target, "root", http.MethodPost, "/api/admin/import/users"+query, strings.NewReader(body))
+		var response struct {
+			Data transfer.Stats `json:"data"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse JSON response: %v", err)
+			}
+		}
+		return rr.Code, response.Data
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		code, stats := importUsers(t, "?dry_run=true", export+`{"username": "x"}`+"\n")
+		if code != http.StatusOK || !stats.DryRun || stats.Written != 2 || stats.Invalid != 1 || len(stats.Errors) != 2 {
+			t.Errorf("Unexpected dry run result %d %+v", code, stats)
+		}
+		if n, _ := target.Users.Count(context.Background()); n != 0 {
+			t.Errorf("Expected the dry run to write nothing, got %d users", n)
+		}
+	})
+
+	t.Run("insert then upsert", func(t *testing.T) {
+		if code, stats := importUsers(t, "", export); code != http.StatusOK || stats.Written != 2 {
+			t.Errorf("Unexpected import result %d %+v", code, stats)
+		}
+		if code, _ := importUsers(t, "?mode=insert", export); code != http.StatusConflict {
+			t.Errorf("Expected 409 importing existing rows, got %d", code)
+		}
+		if code, stats := importUsers(t, "?mode=upsert", export); code != http.StatusOK || stats.Written != 2 {
+			t.Errorf("Unexpected upsert result %d %+v", code, stats)
+		}
+		if code, _ := importUsers(t, "?mode=upsert", "not json\n"); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid row, got %d", code)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, target := range []string{"/api/admin/import/users?mode=merge", "/api/admin/import/users?dry_run=maybe", "/api/admin/import/users?batch=0"} {
+			if rr := transferAPI(source, "root", http.MethodPost, target, strings.NewReader("")); rr.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", target, rr.Code)
+			}
+		}
+		if rr := transferAPI(source, "root", http.MethodGet, "/api/admin/export/widgets", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown model, got %d", rr.Code)
+		}
+	})
+}
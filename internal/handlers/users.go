@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/validation"
+)
+
+// Pagination is the pagination metadata of list responses. Follow NextCursor
+// to fetch the next page.
+type Pagination struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// UserListResponse is the data of GET /api/users
+type UserListResponse struct {
+	Users      []models.User `json:"users"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// ListUsers handles GET /api/users - lists users with cursor pagination.
+// Besides the ParsePageRequest parameters it accepts the email_domain,
+// created_after and created_before (RFC 3339) filters.
+func (h *Handlers) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := ParsePageRequest(r)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err)
+		return
+	}
+	page.IncludeTotal = true
+
+	query := r.URL.Query()
+	filter := models.UserFilter{EmailDomain: query.Get("email_domain")}
+	for param, dst := range map[string]*time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		if v := query.Get(param); v != "" {
+			if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+				ValidationError(w, param, "must be an RFC 3339 timestamp")
+				return
+			}
+		}
+	}
+
+	users, err := h.Users.List(r.Context(), filter, page)
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to list users: %w", err))
+		return
+	}
+
+	JSONResponse(w, http.StatusOK, UserListResponse{
+		Users: users.Items,
+		Pagination: Pagination{
+			Page:       users.Number,
+			Limit:      users.Limit,
+			Total:      *users.Total,
+			NextCursor: users.NextCursor,
+		},
+	})
+}
+
+// CreateUser handles POST /api/users - creates a user; admins only
+func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	var req validation.CreateUserRequest
+	if err := DecodeJSONBody(w, r, &req); err != nil {
+		ErrorResponseFunc(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ValidationErrorsResponse(w, err)
+		return
+	}
+
+	user := &models.User{Username: req.Username, Email: req.Email, Name: req.Name}
+	if err := h.Users.Create(r.Context(), user); err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to create user: %w", err))
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/users/%d", user.ID))
+	SetVersionETag(w, user.Version)
+	JSONResponse(w, http.StatusCreated, user)
+}
+
+// GetUser handles GET /api/users/{id} - returns a user with its version as
+// the ETag
+func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	SetVersionETag(w, user.Version)
+	JSONResponse(w, http.StatusOK, user)
+}
+
+// UpdateUser handles PATCH /api/users/{id} - updates a user's name and email.
+// Users may only update themselves unless they are admins. An If-Match header
+// makes the update conditional on the user's version (412 if it has changed);
+// without one a concurrent change is reported as a 409.
+func (h *Handlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	version, conditional, err := IfMatchVersion(r)
+	if err != nil || conditional && version != user.Version {
+		PreconditionFailedResponse(w)
+		return
+	}
+
+	var req validation.UpdateProfileRequest
+	if err := DecodeJSONBody(w, r, &req); err != nil {
+		ErrorResponseFunc(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		ValidationErrorsResponse(w, err)
+		return
+	}
+	if req.Name == "" && req.Email == "" {
+		ValidationError(w, "request", "at least one of name or email is required")
+		return
+	}
+
+	if req.Name != "" {
+		user.Name = req.Name
+	}
+	if req.Email != "" {
+		user.Email = req.Email
+	}
+
+	if err := h.Users.Update(r.Context(), user); err != nil {
+		if conditional && errors.Is(err, database.ErrVersionConflict) {
+			PreconditionFailedResponse(w)
+			return
+		}
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to update user: %w", err))
+		return
+	}
+
+	SetVersionETag(w, user.Version)
+	JSONResponse(w, http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /api/users/{id} - soft-deletes a user. Users may
+// only delete themselves unless they are admins.
+func (h *Handlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userFromPath(w, r)
+	if !ok || !canEditUser(w, r, user) {
+		return
+	}
+
+	if err := h.Users.Delete(r.Context(), user.ID); err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to delete user: %w", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchUsers handles GET /api/users/search - full-text search over users.
+// The q parameter is required; limit, cursor and include_total page through
+// the results, best matches first.
+func (h *Handlers) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		ValidationError(w, "q", "is required")
+		return
+	}
+
+	page, err := ParsePageRequest(r)
+	if err != nil {
+		RepositoryErrorResponse(w, r, err)
+		return
+	}
+
+	results, err := h.Users.Search(r.Context(), query, page)
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to search users: %w", err))
+		return
+	}
+	JSONResponse(w, http.StatusOK, results)
+}
+
+// userFromPath loads the user named by the {id} path value, responding with
+// an error if it is malformed or missing
+func (h *Handlers) userFromPath(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		ValidationError(w, "id", "must be a positive integer")
+		return nil, false
+	}
+
+	user, err := h.Users.GetByID(r.Context(), uint(id))
+	if err != nil {
+		RepositoryErrorResponse(w, r, fmt.Errorf("failed to get user: %w", err))
+		return nil, false
+	}
+	return user, true
+}
+
+// canEditUser reports whether the request's principal may change user, which
+// requires being that user or an admin, responding with 403 if not
+func canEditUser(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if principal != "" && (principal == user.Username || auth.IsAdmin(principal)) {
+		return true
+	}
+	ErrorResponseFunc(w, http.StatusForbidden, "Forbidden")
+	return false
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// setupUserHandlers returns handlers backed by a migrated database
+func setupUserHandlers(t *testing.T) *Handlers {
+	t.Helper()
+
+	db := seedtest.NewDatabase(t, "../../migrations", "")
+
+	h := New(&mockTemplateCache{}, "./templates", nil)
+	h.Users = models.NewUserRepository(db)
+	h.Outbox = outbox.NewStore(db)
+	return h
+}
+
+func TestSearchUsers(t *testing.T) {
+	h := setupUserHandlers(t)
+	ctx := context.Background()
+	for _, u := range []models.User{
+		{Username: "ada", Email: "ada@example.com", Name: "Ada Lovelace"},
+		{Username: "alan", Email: "alan@example.com", Name: "Alan Turing"},
+	} {
+		if err := h.Users.Create(ctx, &u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{name: "matches", query: "?q=lovelace", wantStatus: http.StatusOK, wantCount: 1},
+		{name: "matches several", query: "?q=example&limit=5", wantStatus: http.StatusOK, wantCount: 2},
+		{name: "no matches", query: "?q=hopper", wantStatus: http.StatusOK, wantCount: 0},
+		{name: "missing query", query: "", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?q=ada&limit=-1", wantStatus: http.StatusBadRequest},
+		{name: "invalid cursor", query: "?q=ada&cursor=nope", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/users/search"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			h.SearchUsers(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data models.Page[models.UserSearchResult] `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse JSON response: %v", err)
+			}
+			if len(response.Data.Items) != tt.wantCount {
+				t.Errorf("Expected %d results, got %d", tt.wantCount, len(response.Data.Items))
+			}
+		})
+	}
+}
+
+// userAPI serves the user routes as principal without JWT middleware
+func userAPI(h *Handlers, principal, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users", h.ListUsers)
+	mux.HandleFunc("POST /api/users", h.CreateUser)
+	mux.HandleFunc("GET /api/users/{id}", h.GetUser)
+	mux.HandleFunc("PATCH /api/users/{id}", h.UpdateUser)
+	mux.HandleFunc("DELETE /api/users/{id}", h.DeleteUser)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if principal != "" {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUsersAPI(t *testing.T) {
+	h := setupUserHandlers(t)
+
+	cfg := &config.Config{}
+	cfg.Auth.AdminUsers = "root"
+	auth.SetConfigForTesting(cfg)
+	defer auth.ResetConfigForTesting()
+
+	var ada models.User
+	t.Run("only admins create users", func(t *testing.T) {
+		body := `{"username": "ada", "email": "ada@example.com", "name": "Ada Lovelace"}`
+		if rr := userAPI(h, "ada", http.MethodPost, "/api/users", body, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-admin, got %d", rr.Code)
+		}
+
+		rr := userAPI(h, "root", http.MethodPost, "/api/users", body, nil)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Data models.User `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		ada = response.Data
+		if ada.CreatedBy != "root" || rr.Header().Get("ETag") != `"1"` {
+			t.Errorf("Unexpected user %+v with ETag %s", ada, rr.Header().Get("ETag"))
+		}
+		if rr.Header().Get("Location") != fmt.Sprintf("/api/users/%d", ada.ID) {
+			t.Errorf("Unexpected Location %s", rr.Header().Get("Location"))
+		}
+
+		userAPI(h, "root", http.MethodPost, "/api/users", `{"username": "alan", "email": "alan@example.org"}`, nil)
+	})
+
+	t.Run("create validation and conflicts", func(t *testing.T) {
+		rr := userAPI(h, "root", http.MethodPost, "/api/users", `{"username": "x", "email": "nope"}`, nil)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "username") || !strings.Contains(rr.Body.String(), "email") {
+			t.Errorf("Expected 400 listing both fields, got %d: %s", rr.Code, rr.Body.String())
+		}
+		rr = userAPI(h, "root", http.MethodPost, "/api/users", `{"username": "ada", "email": "other@example.com"}`, nil)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected 409 for a duplicate username, got %d", rr.Code)
+		}
+	})
+
+	t.Run("list with pagination metadata", func(t *testing.T) {
+		rr := userAPI(h, "ada", http.MethodGet, "/api/users?limit=1&sort=username", "", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Data UserListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		got := response.Data
+		if len(got.Users) != 1 || got.Users[0].Username != "ada" {
+			t.Errorf("Unexpected users %+v", got.Users)
+		}
+		if got.Pagination.Page != 1 || got.Pagination.Limit != 1 || got.Pagination.Total != 2 || got.Pagination.NextCursor == "" {
+			t.Errorf("Unexpected pagination %+v", got.Pagination)
+		}
+
+		rr = userAPI(h, "ada", http.MethodGet, "/api/users?limit=1&sort=username&cursor="+got.Pagination.NextCursor, "", nil)
+		response.Data = UserListResponse{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse JSON response: %v", err)
+		}
+		if len(response.Data.Users) != 1 || response.Data.Users[0].Username != "alan" || response.Data.Pagination.Page != 2 {
+			t.Errorf("Unexpected second page %+v", response.Data)
+		}
+	})
+
+	t.Run("list filters", func(t *testing.T) {
+		rr := userAPI(h, "ada", http.MethodGet, "/api/users?email_domain=example.org", "", nil)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"total":1`) {
+			t.Errorf("Expected one user at example.org, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr := userAPI(h, "ada", http.MethodGet, "/api/users?created_after=yesterday", "", nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a bad timestamp, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "ada", http.MethodGet, "/api/users?sort=password", "", nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown sort, got %d", rr.Code)
+		}
+	})
+
+	path := fmt.Sprintf("/api/users/%d", ada.ID)
+
+	t.Run("get", func(t *testing.T) {
+		rr := userAPI(h, "alan", http.MethodGet, path, "", nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+			t.Errorf("Expected 200 with ETag \"1\", got %d %s", rr.Code, rr.Header().Get("ETag"))
+		}
+		if rr := userAPI(h, "alan", http.MethodGet, "/api/users/999", "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "alan", http.MethodGet, "/api/users/abc", "", nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", rr.Code)
+		}
+	})
+
+	t.Run("users only edit themselves", func(t *testing.T) {
+		if rr := userAPI(h, "alan", http.MethodPatch, path, `{"name": "Not Ada"}`, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+		rr := userAPI(h, "ada", http.MethodPatch, path, `{"name": "Countess Ada"}`, nil)
+		if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+			t.Errorf("Expected 200 with ETag \"2\", got %d %s: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+		}
+		rr = userAPI(h, "root", http.MethodPatch, path, `{"email": "countess@example.com"}`, nil)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"updated_by":"root"`) {
+			t.Errorf("Expected an admin to edit any user, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("patch validation", func(t *testing.T) {
+		if rr := userAPI(h, "ada", http.MethodPatch, path, `{"email": "invalid"}`, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid email, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "ada", http.MethodPatch, path, `{}`, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an empty patch, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "ada", http.MethodPatch, path, `{"username": "root"}`, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown field, got %d", rr.Code)
+		}
+	})
+
+	t.Run("if-match preconditions", func(t *testing.T) {
+		stale := map[string]string{"If-Match": `"1"`}
+		if rr := userAPI(h, "ada", http.MethodPatch, path, `{"name": "Stale Ada"}`, stale); rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 for a stale ETag, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "ada", http.MethodPatch, path, `{"name": "Weak Ada"}`, map[string]string{"If-Match": `W/"3"`}); rr.Code != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 for a weak ETag, got %d", rr.Code)
+		}
+
+		current := userAPI(h, "ada", http.MethodGet, path, "", nil).Header().Get("ETag")
+		rr := userAPI(h, "ada", http.MethodPatch, path, `{"name": "Fresh Ada"}`, map[string]string{"If-Match": current})
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected 200 for the current ETag, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if rr := userAPI(h, "alan", http.MethodDelete, path, "", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "ada", http.MethodDelete, path, "", nil); rr.Code != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", rr.Code)
+		}
+		if rr := userAPI(h, "root", http.MethodGet, path, "", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected a deleted user to be gone, got %d", rr.Code)
+		}
+	})
+
+	t.Run("anonymous requests cannot edit", func(t *testing.T) {
+		if rr := userAPI(h, "", http.MethodPost, "/api/users", `{}`, nil); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected 403, got %d", rr.Code)
+		}
+	})
+}
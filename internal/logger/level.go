@@ -0,0 +1,324 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// levelState holds the minimum level of the default logger and of named
+// loggers, which can be changed at runtime. Changes made with a revert delay
+// are undone by a timer.
+type levelState struct {
+	mu sync.Mutex
+	// configured is the level set by Init, which reverts restore
+	configured slog.Level
+	level      slog.LevelVar
+	revertAt   time.Time
+	timer      *time.Timer
+	named      map[string]*namedLevel
+}
+
+// namedLevel is the level override of a named logger. Entries of names a
+// logger was created with are never removed, so handlers can keep a pointer to
+// theirs; entries only overridden are removed with the override.
+type namedLevel struct {
+	level    slog.LevelVar
+	override atomic.Bool
+	revertAt time.Time
+	timer    *time.Timer
+	// registered is set once a logger has been created with the name
+	registered bool
+}
+
+var levels = &levelState{configured: slog.LevelInfo, named: make(map[string]*namedLevel)}
+
+// Level returns the leveler of the default logger, whose level can be changed
+// at runtime with SetLevel
+func Level() slog.Leveler {
+	return &levels.level
+}
+
+// configureLevel sets the level of the default logger and the level reverts
+// restore, cancelling a pending revert
+func configureLevel(level slog.Level) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.configured = level
+	levels.stopTimer()
+	levels.level.Set(level)
+}
+
+// SetLevel changes the level of the default logger. If revertAfter is
+// positive, the configured level is restored once it has passed.
+func SetLevel(level slog.Level, revertAfter time.Duration) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.stopTimer()
+	levels.level.Set(level)
+	if revertAfter > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(revertAfter, func() {
+			levels.mu.Lock()
+			defer levels.mu.Unlock()
+			// A later change may have replaced the revert after this timer fired
+			if levels.timer == timer {
+				levels.stopTimer()
+				levels.level.Set(levels.configured)
+			}
+		})
+		levels.revertAt = time.Now().Add(revertAfter)
+		levels.timer = timer
+	}
+}
+
+// ResetLevel restores the configured level of the default logger
+func ResetLevel() {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.stopTimer()
+	levels.level.Set(levels.configured)
+}
+
+// ToggleDebug switches the default logger to debug, or back to the configured
+// level if it is already at debug, and returns the new level
+func ToggleDebug() slog.Level {
+	if levels.level.Level() == slog.LevelDebug {
+		ResetLevel()
+	} else {
+		SetLevel(slog.LevelDebug, 0)
+	}
+	return levels.level.Level()
+}
+
+// stopTimer cancels a pending revert; callers hold mu
+func (s *levelState) stopTimer() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.revertAt = time.Time{}
+}
+
+// namedLevel returns the override entry of the named logger, creating it;
+// callers hold mu
+func (s *levelState) namedLevel(name string) *namedLevel {
+	n, ok := s.named[name]
+	if !ok {
+		n = &namedLevel{}
+		s.named[name] = n
+	}
+	return n
+}
+
+// register returns the override entry of the named logger for a handler to
+// keep, so it is never removed
+func (s *levelState) register(name string) *namedLevel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.namedLevel(name)
+	n.registered = true
+	return n
+}
+
+// clearOverride removes the override of the named logger, and its entry if
+// no logger was created with the name; callers hold mu
+func (s *levelState) clearOverride(name string, n *namedLevel) {
+	n.stopTimer()
+	n.override.Store(false)
+	if !n.registered && s.named[name] == n {
+		delete(s.named, name)
+	}
+}
+
+// SetLoggerLevel overrides the level of the loggers named name, regardless
+// of the level of the default logger. If revertAfter is positive, the
+// override is removed once it has passed.
+func SetLoggerLevel(name string, level slog.Level, revertAfter time.Duration) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	n := levels.namedLevel(name)
+	n.stopTimer()
+	n.level.Set(level)
+	n.override.Store(true)
+	if revertAfter > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(revertAfter, func() {
+			levels.mu.Lock()
+			defer levels.mu.Unlock()
+			if n.timer == timer {
+				levels.clearOverride(name, n)
+			}
+		})
+		n.revertAt = time.Now().Add(revertAfter)
+		n.timer = timer
+	}
+}
+
+// ResetLoggerLevel removes the level override of the loggers named name, so
+// they follow the default logger again
+func ResetLoggerLevel(name string) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	if n, ok := levels.named[name]; ok {
+		levels.clearOverride(name, n)
+	}
+}
+
+// stopTimer cancels a pending revert; callers hold levels.mu
+func (n *namedLevel) stopTimer() {
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.revertAt = time.Time{}
+}
+
+// LevelStatus describes the current log levels
+type LevelStatus struct {
+	Level      string     `json:"level"`
+	Configured string     `json:"configured"`
+	RevertAt   *time.Time `json:"revert_at,omitempty"`
+	// Loggers holds the named loggers whose level is overridden
+	Loggers []LoggerLevelStatus `json:"loggers"`
+}
+
+// LoggerLevelStatus describes the level override of a named logger
+type LoggerLevelStatus struct {
+	Name     string     `json:"name"`
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// Levels returns the current log levels
+func Levels() LevelStatus {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+
+	status := LevelStatus{
+		Level:      LevelName(levels.level.Level()),
+		Configured: LevelName(levels.configured),
+		RevertAt:   timePtr(levels.revertAt),
+		Loggers:    []LoggerLevelStatus{},
+	}
+	for name, n := range levels.named {
+		if !n.override.Load() {
+			continue
+		}
+		status.Loggers = append(status.Loggers, LoggerLevelStatus{
+			Name:     name,
+			Level:    LevelName(n.level.Level()),
+			RevertAt: timePtr(n.revertAt),
+		})
+	}
+	sort.Slice(status.Loggers, func(i, j int) bool { return status.Loggers[i].Name < status.Loggers[j].Name })
+	return status
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// LookupLevel returns the level called name (debug, info, warn or error),
+// reporting false for any other name
+func LookupLevel(name string) (slog.Level, bool) {
+	switch name {
+	case "debug", "info", "warn", "error":
+		return ParseLogLevel(name), true
+	}
+	return 0, false
+}
+
+// LevelName returns the lowercase name of level, e.g. "debug"
+func LevelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
+
+// Named returns a logger based on the default logger whose records carry
+// name as the logger attribute and whose level can be overridden with
+// SetLoggerLevel, e.g. to debug one package in production
+func Named(name string) *Logger {
+	return Default().Named(name)
+}
+
+// Named returns a logger whose records carry name as the logger attribute and
+// whose level can be overridden with SetLoggerLevel
+func (l *Logger) Named(name string) *Logger {
+	return NewLoggerName(name).Logger(l)
+}
+
+// LoggerName is the name of a named logger with its level override resolved,
+// for code that names a logger often, e.g. on every request
+type LoggerName struct {
+	name  string
+	level *namedLevel
+}
+
+// NewLoggerName resolves the level override of the loggers named name
+func NewLoggerName(name string) *LoggerName {
+	return &LoggerName{name: name, level: levels.register(name)}
+}
+
+// Logger returns l named n, like l.Named, without looking up the level
+// override or copying the handler's attributes
+func (n *LoggerName) Logger(l *Logger) *Logger {
+	return &Logger{Logger: slog.New(&namedHandler{inner: l.Handler(), level: n.level, name: n.name})}
+}
+
+// namedHandler applies the level override of a named logger. The wrapped
+// handlers write whatever they are given, and a MultiHandler lets overridden
+// records through the sinks that follow the runtime level, so an override
+// below the default logger's level takes effect.
+type namedHandler struct {
+	inner slog.Handler
+	level *namedLevel
+	// name is added to records as the logger attribute until attributes or
+	// groups are added, which take it into inner first
+	name string
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (h *namedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.level.override.Load() {
+		return level >= h.level.level.Level()
+	}
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle handles the Record, letting it through sinks that follow the runtime
+// level when the override is active
+func (h *namedHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.level.override.Load() {
+		ctx = withLevelOverride(ctx)
+	}
+	if h.name != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String("logger", h.name))
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler with attributes added
+func (h *namedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &namedHandler{inner: h.named().WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a new Handler with a group
+func (h *namedHandler) WithGroup(name string) slog.Handler {
+	return &namedHandler{inner: h.named().WithGroup(name), level: h.level}
+}
+
+// named returns inner with the logger attribute if records do not get it yet
+func (h *namedHandler) named() slog.Handler {
+	if h.name == "" {
+		return h.inner
+	}
+	return h.inner.WithAttrs([]slog.Attr{slog.String("logger", h.name)})
+}
@@ -0,0 +1,198 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureDefault makes a logger at the runtime level writing to a buffer the
+// default logger for the rest of the test
+func captureDefault(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	configureLevel(level)
+	SetDefault(New(Config{Level: Level(), Format: "text", Output: &buf, ErrorOutput: &buf}))
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		configureLevel(slog.LevelInfo)
+	})
+	return &buf
+}
+
+func TestSetLevel(t *testing.T) {
+	buf := captureDefault(t, slog.LevelInfo)
+
+	Default().Debug("hidden")
+	SetLevel(slog.LevelDebug, 0)
+	Default().Debug("shown")
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, "shown") {
+		t.Errorf("Expected only the debug record logged after the change, got: %s", got)
+	}
+	if status := Levels(); status.Level != "debug" || status.Configured != "info" || status.RevertAt != nil {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	ResetLevel()
+	if Levels().Level != "info" {
+		t.Errorf("Expected the configured level restored, got %s", Levels().Level)
+	}
+}
+
+func TestSetLevelReverts(t *testing.T) {
+	captureDefault(t, slog.LevelWarn)
+
+	SetLevel(slog.LevelDebug, 20*time.Millisecond)
+	if status := Levels(); status.Level != "debug" || status.RevertAt == nil {
+		t.Fatalf("Expected a pending revert, got %+v", status)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for Levels().Level != "warn" {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the level to revert to warn")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if Levels().RevertAt != nil {
+		t.Error("Expected no pending revert after reverting")
+	}
+
+	t.Run("later change cancels the revert", func(t *testing.T) {
+		SetLevel(slog.LevelDebug, 20*time.Millisecond)
+		SetLevel(slog.LevelError, 0)
+		time.Sleep(50 * time.Millisecond)
+		if Levels().Level != "error" {
+			t.Errorf("Expected the later change kept, got %s", Levels().Level)
+		}
+	})
+}
+
+func TestSetLoggerLevel(t *testing.T) {
+	buf := captureDefault(t, slog.LevelInfo)
+	defer ResetLoggerLevel("db")
+
+	db := Named("db")
+	other := Named("other")
+
+	SetLoggerLevel("db", slog.LevelDebug, 0)
+	db.Debug("db debug")
+	other.Debug("other debug")
+	Default().Debug("default debug")
+
+	got := buf.String()
+	if !strings.Contains(got, "db debug") || !strings.Contains(got, "logger=db") {
+		t.Errorf("Expected the named logger's debug record with its name, got: %s", got)
+	}
+	if strings.Contains(got, "other debug") || strings.Contains(got, "default debug") {
+		t.Errorf("Expected other loggers to keep the default level, got: %s", got)
+	}
+	if status := Levels(); len(status.Loggers) != 1 || status.Loggers[0].Name != "db" || status.Loggers[0].Level != "debug" {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	SetLoggerLevel("db", slog.LevelError, 20*time.Millisecond)
+	buf.Reset()
+	db.With("key", "value").Warn("db warn")
+	if buf.Len() != 0 {
+		t.Errorf("Expected the override to raise the level too, got: %s", buf.String())
+	}
+	time.Sleep(50 * time.Millisecond)
+	db.Warn("db warn")
+	if !strings.Contains(buf.String(), "db warn") || len(Levels().Loggers) != 0 {
+		t.Errorf("Expected the override to revert, got: %s", buf.String())
+	}
+}
+
+func TestLoggerLevelEntries(t *testing.T) {
+	captureDefault(t, slog.LevelInfo)
+
+	// entry reports whether name has an override entry
+	entry := func(name string) bool {
+		levels.mu.Lock()
+		defer levels.mu.Unlock()
+		_, ok := levels.named[name]
+		return ok
+	}
+
+	SetLoggerLevel("no-such-logger", slog.LevelDebug, 0)
+	ResetLoggerLevel("no-such-logger")
+	if entry("no-such-logger") {
+		t.Error("Expected the entry of a name no logger has to be removed on reset")
+	}
+
+	SetLoggerLevel("reverted-logger", slog.LevelDebug, 10*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for entry("reverted-logger") {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the entry of a name no logger has to be removed on revert")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ResetLoggerLevel("never-set")
+	if entry("never-set") {
+		t.Error("Expected resetting an unknown name not to create an entry")
+	}
+
+	// A logger's entry is kept, so its handler sees later overrides
+	name := NewLoggerName("kept-logger")
+	SetLoggerLevel("kept-logger", slog.LevelDebug, 0)
+	ResetLoggerLevel("kept-logger")
+	if !entry("kept-logger") {
+		t.Fatal("Expected the entry of a created logger to be kept")
+	}
+	SetLoggerLevel("kept-logger", slog.LevelError, 0)
+	defer ResetLoggerLevel("kept-logger")
+	if name.Logger(Default()).Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Expected the logger to follow the override set after a reset")
+	}
+}
+
+func TestLoggerName(t *testing.T) {
+	buf := captureDefault(t, slog.LevelInfo)
+	name := NewLoggerName("requests")
+
+	log := name.Logger(&Logger{Logger: Default().With("request", "r-1")})
+	log.Info("first", "status", 200)
+	log.WithGroup("g").Info("second", "status", 404)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got: %s", buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "request=r-1") || strings.Count(line, "logger=requests") != 1 {
+			t.Errorf("Expected the record to carry the name once, got: %s", line)
+		}
+	}
+	if !strings.Contains(lines[1], "g.status=404") || strings.Contains(lines[1], "g.logger") {
+		t.Errorf("Expected the name outside the group, got: %s", lines[1])
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	captureDefault(t, slog.LevelWarn)
+
+	if level := ToggleDebug(); level != slog.LevelDebug {
+		t.Errorf("Expected debug, got %v", level)
+	}
+	if level := ToggleDebug(); level != slog.LevelWarn {
+		t.Errorf("Expected the configured level back, got %v", level)
+	}
+}
+
+func TestLookupLevel(t *testing.T) {
+	if level, ok := LookupLevel("warn"); !ok || level != slog.LevelWarn {
+		t.Errorf("Expected warn, got %v %v", level, ok)
+	}
+	for _, name := range []string{"", "WARN", "verbose"} {
+		if _, ok := LookupLevel(name); ok {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/google/uuid"
+	"github.com/tediscript/gostarterkit/internal/tracing"
 )
 
 // contextKey is a custom type to avoid context key collisions
@@ -31,6 +32,11 @@ type Config struct {
 	Format      string // "json" or "text"
 	Output      io.Writer
 	ErrorOutput io.Writer
+	// Redact, if set, removes secrets from records before they are written
+	Redact *RedactOptions
+	// Sinks receive records alongside Output and ErrorOutput, which are
+	// skipped when Output is nil
+	Sinks []Sink
 }
 
 // New creates a new logger with the given configuration
@@ -39,19 +45,35 @@ func New(cfg Config) *Logger {
 
 	// Determine output writer based on log level
 	// We'll use a custom handler to route to stdout/stderr
-	handler = NewLevelWriterHandler(
-		cfg.Output,
-		cfg.ErrorOutput,
-		cfg.Format,
-		cfg.Level,
-	)
+	if cfg.Output != nil {
+		handler = NewLevelWriterHandler(
+			cfg.Output,
+			cfg.ErrorOutput,
+			cfg.Format,
+			cfg.Level,
+		)
+	}
+
+	// Fan out to the additional sinks, each filtering by its own level
+	if len(cfg.Sinks) > 0 {
+		var sinks []Sink
+		if handler != nil {
+			sinks = append(sinks, Sink{Handler: handler, Level: cfg.Level})
+		}
+		handler = NewMultiHandler(append(sinks, cfg.Sinks...)...)
+	}
+
+	if cfg.Redact != nil {
+		handler = NewRedactHandler(handler, *cfg.Redact)
+	}
 
 	return &Logger{
 		Logger: slog.New(handler),
 	}
 }
 
-// FromContext extracts a logger with correlation ID and request ID from context
+// FromContext extracts a logger with correlation ID, request ID and trace
+// context from context
 func FromContext(ctx context.Context) *Logger {
 	logger := slog.Default()
 
@@ -69,10 +91,16 @@ func FromContext(ctx context.Context) *Logger {
 		}
 	}
 
+	// Add trace and span IDs so log records can be joined with traces
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+
 	return &Logger{Logger: logger}
 }
 
-// WithContext returns a logger with correlation ID and request ID from context
+// WithContext returns a logger with correlation ID, request ID and trace
+// context from context
 func (l *Logger) WithContext(ctx context.Context) *Logger {
 	logger := l.Logger
 
@@ -90,6 +118,11 @@ func (l *Logger) WithContext(ctx context.Context) *Logger {
 		}
 	}
 
+	// Add trace and span IDs so log records can be joined with traces
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With(slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+
 	return &Logger{Logger: logger}
 }
 
@@ -261,13 +294,23 @@ func SetDefault(l *Logger) {
 	slog.SetDefault(l.Logger)
 }
 
-// Init initializes the default logger from environment variables
+// Init initializes the default logger from environment variables, redacting
+// records with DefaultRedactOptions. Its level can be changed at runtime with
+// SetLevel.
 func Init(level, format string) *Logger {
+	return InitWithOptions(level, format, DefaultRedactOptions())
+}
+
+// InitWithOptions is Init with custom redaction, also writing records to sinks
+func InitWithOptions(level, format string, redact RedactOptions, sinks ...Sink) *Logger {
+	configureLevel(ParseLogLevel(level))
 	cfg := Config{
-		Level:       ParseLogLevel(level),
+		Level:       Level(),
 		Format:      ValidateLogFormat(format),
 		Output:      os.Stdout,
 		ErrorOutput: os.Stderr,
+		Redact:      &redact,
+		Sinks:       sinks,
 	}
 
 	logger := New(cfg)
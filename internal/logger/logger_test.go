@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"strings"
 	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/tracing"
 )
 
 func TestParseLogLevel(t *testing.T) {
@@ -218,6 +220,30 @@ func TestLoggerWithContext(t *testing.T) {
 	}
 }
 
+// TestLoggerWithTraceContext tests that trace and span IDs are logged
+func TestLoggerWithTraceContext(t *testing.T) {
+	ctx, span := tracing.Start(context.Background(), "operation")
+	defer span.End()
+
+	var buf bytes.Buffer
+	log := New(Config{Level: slog.LevelInfo, Format: "json", Output: &buf, ErrorOutput: &buf})
+	log.WithContext(ctx).Info("test message")
+
+	sc := span.SpanContext()
+	output := buf.String()
+	for _, want := range []string{`"trace_id":"` + sc.TraceID.String() + `"`, `"span_id":"` + sc.SpanID.String() + `"`} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %s in output, got: %s", want, output)
+		}
+	}
+
+	buf.Reset()
+	log.WithContext(context.Background()).Info("untraced")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("Expected no trace ID without a span, got: %s", buf.String())
+	}
+}
+
 func TestContextLoggingFunctions(t *testing.T) {
 	ctx := context.Background()
 	id := GenerateCorrelationID()
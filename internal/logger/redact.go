@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue replaces redacted values in log records
+const RedactedValue = "[REDACTED]"
+
+// Sensitive is a string that renders as [REDACTED] in logs, in fmt output and
+// in JSON, so secrets can be passed around without being leaked by accident.
+// Convert it back with string(s) where the value is needed.
+type Sensitive string
+
+// LogValue implements slog.LogValuer
+func (Sensitive) LogValue() slog.Value {
+	return slog.StringValue(RedactedValue)
+}
+
+// String implements fmt.Stringer
+func (Sensitive) String() string {
+	return RedactedValue
+}
+
+// GoString implements fmt.GoStringer, for %#v
+func (Sensitive) GoString() string {
+	return RedactedValue
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (Sensitive) MarshalText() ([]byte, error) {
+	return []byte(RedactedValue), nil
+}
+
+// DefaultRedactKeys are the attribute keys redacted by default
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "apikey"}
+
+// RedactRule replaces the matches of Pattern in string values and messages
+type RedactRule struct {
+	Pattern *regexp.Regexp
+	// Replacement is a regexp template such as "${1}[REDACTED]"; empty
+	// replaces the whole match with [REDACTED]
+	Replacement string
+}
+
+var (
+	// JWTRule redacts strings that look like JSON Web Tokens
+	JWTRule = RedactRule{Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)}
+	// BearerRule redacts the credentials of Bearer and Basic authorization
+	// header values
+	BearerRule = RedactRule{
+		Pattern:     regexp.MustCompile(`(?i)\b(Bearer|Basic)\s+[A-Za-z0-9._~+/=-]+`),
+		Replacement: "${1} " + RedactedValue,
+	}
+	// EmailRule redacts email addresses
+	EmailRule = RedactRule{Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)}
+)
+
+// pairPattern matches name=value pairs in strings such as query strings
+var pairPattern = regexp.MustCompile(`([A-Za-z0-9_.\-\[\]]+)=([^&\s;,"]*)`)
+
+// RedactOptions configures a RedactHandler
+type RedactOptions struct {
+	// Keys redacts the whole value of attributes whose key contains one of
+	// them, ignoring case, - and _ (so apikey matches X-Api-Key), and the
+	// values of matching name=value pairs inside strings
+	Keys []string
+	// Rules are applied to string values, errors and messages
+	Rules []RedactRule
+}
+
+// DefaultRedactOptions redacts DefaultRedactKeys, JWTs and authorization
+// credentials
+func DefaultRedactOptions() RedactOptions {
+	return RedactOptions{
+		Keys:  DefaultRedactKeys,
+		Rules: []RedactRule{JWTRule, BearerRule},
+	}
+}
+
+// RedactHandler is a slog.Handler that redacts secrets from records before
+// passing them on. Attributes are redacted by key, and strings by RedactRule.
+// Values logged with slog.Any are only inspected if they are errors or
+// http.Header; redact other types with Sensitive or a LogValue method.
+type RedactHandler struct {
+	inner slog.Handler
+	keys  []string
+	rules []RedactRule
+}
+
+// NewRedactHandler wraps inner with redaction configured by opts
+func NewRedactHandler(inner slog.Handler, opts RedactOptions) *RedactHandler {
+	h := &RedactHandler{inner: inner, rules: opts.Rules}
+	for _, key := range opts.Keys {
+		if key = normalizeKey(strings.TrimSpace(key)); key != "" {
+			h.keys = append(h.keys, key)
+		}
+	}
+	return h
+}
+
+// normalizeKey lowercases key and removes - and _
+func normalizeKey(key string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+}
+
+// sensitiveKey reports whether values of key are redacted
+func (h *RedactHandler) sensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range h.keys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString applies the rules and redacts sensitive name=value pairs
+func (h *RedactHandler) redactString(s string) string {
+	if len(h.keys) > 0 && strings.Contains(s, "=") {
+		s = pairPattern.ReplaceAllStringFunc(s, func(pair string) string {
+			name, _, _ := strings.Cut(pair, "=")
+			if h.sensitiveKey(name) {
+				return name + "=" + RedactedValue
+			}
+			return pair
+		})
+	}
+	for _, rule := range h.rules {
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = RedactedValue
+		}
+		s = rule.Pattern.ReplaceAllString(s, replacement)
+	}
+	return s
+}
+
+// redactAttr returns a with secrets redacted
+func (h *RedactHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Key != "" && h.sensitiveKey(a.Key) {
+		return slog.String(a.Key, RedactedValue)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(h.redactString(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = h.redactAttr(attr)
+		}
+		a.Value = slog.GroupValue(redacted...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(h.redactString(v.Error()))
+		case http.Header:
+			a.Value = slog.AnyValue(h.redactHeader(v))
+		}
+	}
+	return a
+}
+
+// redactHeader returns a copy of header with sensitive values redacted
+func (h *RedactHandler) redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		copied := make([]string, len(values))
+		for i, v := range values {
+			if h.sensitiveKey(name) {
+				copied[i] = RedactedValue
+			} else {
+				copied[i] = h.redactString(v)
+			}
+		}
+		redacted[name] = copied
+	}
+	return redacted
+}
+
+// Enabled reports whether the handler handles records at the given level
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle redacts the record and passes it on
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.inner.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a new Handler with attributes added, redacted
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &RedactHandler{inner: h.inner.WithAttrs(redacted), keys: h.keys, rules: h.rules}
+}
+
+// WithGroup returns a new Handler with a group
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{inner: h.inner.WithGroup(name), keys: h.keys, rules: h.rules}
+}
@@ -0,0 +1,292 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeFormat is the timestamp added to the names of rotated files
+const rotatedTimeFormat = "20060102T150405.000"
+
+// rotateRetryDelay is how long a failed rotation waits before another attempt
+const rotateRetryDelay = time.Minute
+
+// RotateOptions configures when a RotatingFile rotates and which rotated
+// files it keeps. Zero values disable the corresponding limit.
+type RotateOptions struct {
+	// MaxSize rotates the file before a write would take it past this many bytes
+	MaxSize int64
+	// Interval rotates the file at every multiple of the interval since the
+	// Unix epoch, e.g. at midnight UTC for 24h
+	Interval time.Duration
+	// Compress gzips rotated files
+	Compress bool
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int
+	// MaxAge removes rotated files older than this
+	MaxAge time.Duration
+}
+
+// RotatingFile is an io.WriteCloser appending to a file that it rotates by
+// size and time. A rotated file is renamed with a timestamp, e.g. app.log
+// becomes app-20250101T120000.000.log, then compressed and pruned in the
+// background.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+	// now is replaceable in tests
+	now func() time.Time
+
+	mu           sync.Mutex
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+	// retryAt delays rotating again after a failed rotation
+	retryAt time.Time
+
+	// mill compresses and prunes rotated files, one run at a time
+	millMu sync.Mutex
+	millWG sync.WaitGroup
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory
+// if needed
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, opts: opts, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current file; callers hold mu
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.opts.Interval > 0 {
+		f.nextRotation = f.now().Truncate(f.opts.Interval).Add(f.opts.Interval)
+	}
+	return nil
+}
+
+// Write appends p to the file, rotating it first if it is due
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	now := f.now()
+	due := f.opts.Interval > 0 && !now.Before(f.nextRotation)
+	full := f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize
+	if (due || full) && !now.Before(f.retryAt) {
+		// A failed rotation still leaves a file to append to
+		if err := f.rotate(); err != nil && f.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate renames the current file and opens a new one; callers hold mu. If
+// that fails, it keeps appending to the current file, reports the error to
+// stderr like mill does, and leaves the next attempt for rotateRetryDelay.
+func (f *RotatingFile) rotate() error {
+	now := f.now()
+	if err := f.swap(now); err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation: %v\n", err)
+		f.retryAt = now.Add(rotateRetryDelay)
+		if f.file == nil {
+			if err := f.open(); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	f.retryAt = time.Time{}
+
+	f.millWG.Add(1)
+	go func() {
+		defer f.millWG.Done()
+		f.mill(now)
+	}()
+	return nil
+}
+
+// swap closes the current file, renames it to its backup name and opens a
+// new one; callers hold mu
+func (f *RotatingFile) swap(now time.Time) error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if f.size == 0 {
+		return f.open()
+	}
+
+	backup := f.backupName(now)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		// Move the file back so logging carries on where it was
+		os.Rename(backup, f.path)
+		return err
+	}
+	return nil
+}
+
+// backupName returns the name of the file rotated at t
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext)
+	return fmt.Sprintf("%s-%s%s", prefix, t.UTC().Format(rotatedTimeFormat), ext)
+}
+
+// backup is a rotated file
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the rotated files, newest first
+func (f *RotatingFile) backups() ([]backup, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log directory: %w", err)
+	}
+	var result []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimSuffix(stamp, ".gz"), ext)
+		rotated, err := time.Parse(rotatedTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		result = append(result, backup{path: filepath.Join(dir, name), rotated: rotated})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].rotated.After(result[j].rotated) })
+	return result, nil
+}
+
+// mill compresses rotated files and removes those beyond the retention
+// limits as of now. Failures are reported to stderr, since the logger itself
+// may be what writes to this file.
+func (f *RotatingFile) mill(now time.Time) {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation: %v\n", err)
+		return
+	}
+
+	cutoff := time.Time{}
+	if f.opts.MaxAge > 0 {
+		cutoff = now.Add(-f.opts.MaxAge)
+	}
+	for i, b := range backups {
+		if (f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups) || (!cutoff.IsZero() && b.rotated.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "log rotation: failed to remove %s: %v\n", b.path, err)
+			}
+			continue
+		}
+		if f.opts.Compress && !strings.HasSuffix(b.path, ".gz") {
+			if err := compressFile(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "log rotation: failed to compress %s: %v\n", b.path, err)
+			}
+		}
+	}
+}
+
+// compressFile gzips path to path.gz and removes path
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// Close closes the file and waits for compression and pruning to finish
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.millWG.Wait()
+	return err
+}
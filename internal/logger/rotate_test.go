@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestFile opens a rotating file in a temporary directory whose clock is
+// controlled by the returned pointer
+func openTestFile(t *testing.T, opts RotateOptions) (*RotatingFile, *time.Time) {
+	t.Helper()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := &RotatingFile{path: filepath.Join(t.TempDir(), "logs", "app.log"), opts: opts, now: func() time.Time { return now }}
+	if err := f.open(); err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, &now
+}
+
+func write(t *testing.T, f *RotatingFile, s string) {
+	t.Helper()
+	if _, err := f.Write([]byte(s)); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	f, now := openTestFile(t, RotateOptions{MaxSize: 12})
+
+	write(t, f, "12345\n")
+	write(t, f, "1234\n")
+	*now = now.Add(time.Second)
+	write(t, f, "next\n")
+	f.Close()
+
+	current, _ := os.ReadFile(f.path)
+	if string(current) != "next\n" {
+		t.Errorf("Expected the current file to hold the last write, got %q", current)
+	}
+	rotated, err := os.ReadFile(filepath.Join(filepath.Dir(f.path), "app-20250101T120001.000.log"))
+	if err != nil {
+		t.Fatalf("Expected a rotated file: %v", err)
+	}
+	if string(rotated) != "12345\n1234\n" {
+		t.Errorf("Unexpected rotated file %q", rotated)
+	}
+
+	t.Run("oversized write goes to an empty file", func(t *testing.T) {
+		f, _ := openTestFile(t, RotateOptions{MaxSize: 4})
+		write(t, f, "longer than four\n")
+		backups, _ := f.backups()
+		if len(backups) != 0 {
+			t.Errorf("Expected no rotation of an empty file, got %v", backups)
+		}
+	})
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	f, now := openTestFile(t, RotateOptions{Interval: time.Hour})
+
+	write(t, f, "first\n")
+	*now = now.Add(59 * time.Minute)
+	write(t, f, "second\n")
+	if backups, _ := f.backups(); len(backups) != 0 {
+		t.Fatalf("Expected no rotation within the hour, got %v", backups)
+	}
+
+	*now = now.Add(time.Minute)
+	write(t, f, "third\n")
+	backups, _ := f.backups()
+	if len(backups) != 1 || !backups[0].rotated.Equal(*now) {
+		t.Fatalf("Expected one rotation on the hour, got %v", backups)
+	}
+	if f.nextRotation != now.Add(time.Hour) {
+		t.Errorf("Expected the next rotation an hour later, got %v", f.nextRotation)
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	f, now := openTestFile(t, RotateOptions{Compress: true, MaxBackups: 2})
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+		write(t, f, line)
+		*now = now.Add(time.Minute)
+		if err := f.Rotate(); err != nil {
+			t.Fatalf("Failed to rotate: %v", err)
+		}
+	}
+	f.Close()
+
+	backups, _ := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups kept, got %v", backups)
+	}
+	for i, want := range []string{"four\n", "three\n"} {
+		if !strings.HasSuffix(backups[i].path, ".log.gz") {
+			t.Fatalf("Expected a compressed backup, got %s", backups[i].path)
+		}
+		file, _ := os.Open(backups[i].path)
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", backups[i].path, err)
+		}
+		got, _ := io.ReadAll(gz)
+		file.Close()
+		if string(got) != want {
+			t.Errorf("Expected %q in %s, got %q", want, backups[i].path, got)
+		}
+	}
+
+	t.Run("max age", func(t *testing.T) {
+		f, now := openTestFile(t, RotateOptions{MaxAge: time.Hour})
+		write(t, f, "old\n")
+		f.Rotate()
+		*now = now.Add(2 * time.Hour)
+		write(t, f, "new\n")
+		f.Rotate()
+		f.Close()
+
+		backups, _ := f.backups()
+		if len(backups) != 1 || !backups[0].rotated.Equal(*now) {
+			t.Errorf("Expected only the recent backup kept, got %v", backups)
+		}
+	})
+}
+
+func TestRotatingFileRotateFailure(t *testing.T) {
+	f, now := openTestFile(t, RotateOptions{MaxSize: 8})
+
+	// A non-empty directory at the backup name makes the rename fail
+	blocker := f.backupName(*now)
+	if err := os.MkdirAll(filepath.Join(blocker, "keep"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, f, "first\n")
+	write(t, f, "second\n")
+	current, _ := os.ReadFile(f.path)
+	if string(current) != "first\nsecond\n" {
+		t.Fatalf("Expected writes to continue in the current file, got %q", current)
+	}
+
+	// Rotation is retried once the delay has passed
+	os.RemoveAll(blocker)
+	*now = now.Add(rotateRetryDelay)
+	write(t, f, "third\n")
+	current, _ = os.ReadFile(f.path)
+	if string(current) != "third\n" {
+		t.Errorf("Expected the retried rotation to start a new file, got %q", current)
+	}
+	if backups, _ := f.backups(); len(backups) != 1 {
+		t.Errorf("Expected one backup after the retry, got %v", backups)
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	f, _ := openTestFile(t, RotateOptions{})
+	f.Close()
+	if _, err := f.Write([]byte("late\n")); err == nil {
+		t.Error("Expected writing to a closed file to fail")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package logger
+
+// ToggleDebugOnSignal does nothing on platforms without SIGUSR1
+func ToggleDebugOnSignal() (stop func()) {
+	return func() {}
+}
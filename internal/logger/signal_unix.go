@@ -0,0 +1,37 @@
+//go:build unix
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ToggleDebugOnSignal toggles debug logging with ToggleDebug every time the
+// process receives SIGUSR1, until stop is called
+func ToggleDebugOnSignal() (stop func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGUSR1)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sigChan:
+				level := ToggleDebug()
+				Default().Warn("Log level changed by signal",
+					"signal", "SIGUSR1",
+					"level", LevelName(level),
+				)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigChan)
+		close(done)
+	}
+}
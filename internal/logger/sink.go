@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+)
+
+// Sink is a destination for log records with its own minimum level
+type Sink struct {
+	Handler slog.Handler
+	// Level is the sink's minimum level. With the runtime leveler returned by
+	// Level, the sink follows SetLevel and the overrides of named loggers;
+	// any other leveler is applied as is.
+	Level slog.Leveler
+}
+
+// NewWriterSink returns a sink writing records at or above level to w in the
+// given format ("json" or "text"), e.g. to a RotatingFile
+func NewWriterSink(w io.Writer, format string, level slog.Leveler) Sink {
+	opts := &slog.HandlerOptions{Level: level}
+	if ValidateLogFormat(format) == "json" {
+		return Sink{Handler: slog.NewJSONHandler(w, opts), Level: level}
+	}
+	return Sink{Handler: slog.NewTextHandler(w, opts), Level: level}
+}
+
+// follows reports whether the sink follows the runtime level
+func (s Sink) follows() bool {
+	return s.Level == Level()
+}
+
+// accepts reports whether the sink writes records at level
+func (s Sink) accepts(ctx context.Context, level slog.Level) bool {
+	if s.follows() && levelOverridden(ctx) {
+		return true
+	}
+	return level >= s.Level.Level()
+}
+
+// MultiHandler is a slog.Handler that fans records out to several sinks, each
+// writing only the records at or above its own level, e.g. JSON to a file at
+// debug and text to the console at info
+type MultiHandler struct {
+	sinks []Sink
+}
+
+// NewMultiHandler returns a handler writing to sinks. Sinks without a level
+// follow the runtime level.
+func NewMultiHandler(sinks ...Sink) *MultiHandler {
+	h := &MultiHandler{sinks: make([]Sink, len(sinks))}
+	for i, s := range sinks {
+		if s.Level == nil {
+			s.Level = Level()
+		}
+		h.sinks[i] = s
+	}
+	return h
+}
+
+// Enabled reports whether any sink handles records at the given level
+func (h *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, s := range h.sinks {
+		if level >= s.Level.Level() {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes the record to every sink that accepts its level
+func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, s := range h.sinks {
+		if !s.accepts(ctx, r.Level) {
+			continue
+		}
+		if err := s.Handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a new Handler with attributes added to every sink
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = Sink{Handler: s.Handler.WithAttrs(attrs), Level: s.Level}
+	}
+	return &MultiHandler{sinks: sinks}
+}
+
+// WithGroup returns a new Handler with a group added to every sink
+func (h *MultiHandler) WithGroup(name string) slog.Handler {
+	sinks := make([]Sink, len(h.sinks))
+	for i, s := range h.sinks {
+		sinks[i] = Sink{Handler: s.Handler.WithGroup(name), Level: s.Level}
+	}
+	return &MultiHandler{sinks: sinks}
+}
+
+// overrideKey marks contexts of records let through by a named logger's level
+// override
+type overrideKey struct{}
+
+// withLevelOverride marks ctx as carrying a record enabled by an override
+func withLevelOverride(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, overrideKey{}, true)
+}
+
+// levelOverridden reports whether ctx carries a record enabled by an override
+func levelOverridden(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	overridden, _ := ctx.Value(overrideKey{}).(bool)
+	return overridden
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMultiHandler(t *testing.T) {
+	var console, file bytes.Buffer
+	configureLevel(slog.LevelInfo)
+	defer configureLevel(slog.LevelInfo)
+
+	log := New(Config{
+		Level:       Level(),
+		Format:      "text",
+		Output:      &console,
+		ErrorOutput: &console,
+		Redact:      &RedactOptions{Keys: DefaultRedactKeys},
+		Sinks:       []Sink{NewWriterSink(&file, "json", slog.LevelDebug)},
+	})
+
+	log.With("component", "test").Debug("debug record", "password", "hunter2")
+	log.Info("info record")
+
+	if got := console.String(); strings.Contains(got, "debug record") || !strings.Contains(got, "msg=\"info record\"") {
+		t.Errorf("Expected only the info record as text on the console, got: %s", got)
+	}
+	lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected both records in the file, got: %s", file.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected JSON in the file: %v", err)
+	}
+	if record["component"] != "test" || record["password"] != RedactedValue {
+		t.Errorf("Expected attributes kept and secrets redacted in the file, got %v", record)
+	}
+
+	t.Run("runtime level", func(t *testing.T) {
+		console.Reset()
+		SetLevel(slog.LevelDebug, 0)
+		defer ResetLevel()
+		log.Debug("now shown")
+		if !strings.Contains(console.String(), "now shown") {
+			t.Errorf("Expected the console to follow the runtime level, got: %s", console.String())
+		}
+	})
+}
+
+func TestMultiHandlerNamedOverride(t *testing.T) {
+	var console, file bytes.Buffer
+	previous := slog.Default()
+	configureLevel(slog.LevelInfo)
+	SetDefault(New(Config{
+		Level:       Level(),
+		Format:      "text",
+		Output:      &console,
+		ErrorOutput: &console,
+		Sinks:       []Sink{NewWriterSink(&file, "text", slog.LevelWarn)},
+	}))
+	defer func() {
+		slog.SetDefault(previous)
+		ResetLoggerLevel("sinks")
+	}()
+
+	SetLoggerLevel("sinks", slog.LevelDebug, 0)
+	Named("sinks").Debug("overridden")
+
+	if !strings.Contains(console.String(), "overridden") {
+		t.Errorf("Expected the override to reach the console, got: %s", console.String())
+	}
+	if file.Len() != 0 {
+		t.Errorf("Expected the file to keep its own level, got: %s", file.String())
+	}
+}
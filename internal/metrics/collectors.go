@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"database/sql"
+	"runtime"
+	"time"
+)
+
+// NewDBStatsCollector reports the connection pool statistics returned by
+// stats, labelled with pool so the read and write pools of a database can be
+// told apart
+func NewDBStatsCollector(pool string, stats func() sql.DBStats) Collector {
+	return CollectorFunc(func() []Family {
+		s := stats()
+		labels := []Label{{Name: "pool", Value: pool}}
+		gauge := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Labels: labels, Value: v}}}
+		}
+		counter := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Labels: labels, Value: v}}}
+		}
+		return []Family{
+			gauge("db_max_open_connections", "Maximum number of open connections to the database.", float64(s.MaxOpenConnections)),
+			gauge("db_open_connections", "Number of established connections, in use and idle.", float64(s.OpenConnections)),
+			gauge("db_in_use_connections", "Number of connections currently in use.", float64(s.InUse)),
+			gauge("db_idle_connections", "Number of idle connections.", float64(s.Idle)),
+			counter("db_wait_count_total", "Total number of connections waited for.", float64(s.WaitCount)),
+			counter("db_wait_duration_seconds_total", "Total time spent waiting for a connection.", s.WaitDuration.Seconds()),
+			counter("db_max_idle_closed_total", "Total number of connections closed due to the idle connection limit.", float64(s.MaxIdleClosed)),
+			counter("db_max_idle_time_closed_total", "Total number of connections closed due to the idle time limit.", float64(s.MaxIdleTimeClosed)),
+			counter("db_max_lifetime_closed_total", "Total number of connections closed due to the connection lifetime limit.", float64(s.MaxLifetimeClosed)),
+		}
+	})
+}
+
+// processStart approximates the start time of the process
+var processStart = time.Now()
+
+// NewRuntimeCollector reports goroutine, memory and garbage collector
+// statistics of the Go runtime, under the names the Prometheus Go client uses
+func NewRuntimeCollector() Collector {
+	return CollectorFunc(func() []Family {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		gauge := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: v}}}
+		}
+		counter := func(name, help string, v float64) Family {
+			return Family{Name: name, Help: help, Type: TypeCounter, Samples: []Sample{{Value: v}}}
+		}
+		return []Family{
+			gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())),
+			gauge("go_threads", "Number of OS threads created.", threads()),
+			{Name: "go_info", Help: "Information about the Go environment.", Type: TypeGauge, Samples: []Sample{{Labels: []Label{{Name: "version", Value: runtime.Version()}}, Value: 1}}},
+			gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(m.Alloc)),
+			counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(m.TotalAlloc)),
+			gauge("go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(m.Sys)),
+			gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(m.HeapInuse)),
+			gauge("go_memstats_heap_idle_bytes", "Number of heap bytes waiting to be used.", float64(m.HeapIdle)),
+			gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(m.HeapObjects)),
+			counter("go_memstats_mallocs_total", "Total number of mallocs.", float64(m.Mallocs)),
+			counter("go_memstats_frees_total", "Total number of frees.", float64(m.Frees)),
+			gauge("go_memstats_next_gc_bytes", "Number of heap bytes when the next garbage collection will take place.", float64(m.NextGC)),
+			counter("go_gc_cycles_total", "Total number of completed garbage collection cycles.", float64(m.NumGC)),
+			counter("go_gc_pause_seconds_total", "Total time the world was stopped for garbage collection.", float64(m.PauseTotalNs)/1e9),
+			gauge("process_start_time_seconds", "Start time of the process since the Unix epoch in seconds.", float64(processStart.Unix())),
+		}
+	})
+}
+
+// threads returns the number of OS threads the runtime has created
+func threads() float64 {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return float64(n)
+}
@@ -0,0 +1,290 @@
+// Package metrics collects application metrics in-process and serves them in
+// the Prometheus text exposition format. Counters, gauges and histograms are
+// defined on a Registry, usually the Default one, and collectors add values
+// computed at scrape time, such as database pool and Go runtime statistics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Type is the kind of a metric family
+type Type string
+
+const (
+	TypeCounter   Type = "counter"
+	TypeGauge     Type = "gauge"
+	TypeHistogram Type = "histogram"
+)
+
+// DefBuckets are the default histogram buckets, in seconds, suited to
+// request latencies
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ContentType is the media type of the exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Label is a label name and value
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one series of a metric family. Histogram samples carry their
+// cumulative bucket counts, sum and count instead of Value.
+type Sample struct {
+	Labels []Label
+	Value  float64
+	// Buckets holds the cumulative count of each upper bound in Bounds
+	Bounds  []float64
+	Buckets []uint64
+	Sum     float64
+	Count   uint64
+}
+
+// Family is a named metric and its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector produces metric families when the registry is scraped
+type Collector interface {
+	Collect() []Family
+}
+
+// CollectorFunc adapts a function to the Collector interface
+type CollectorFunc func() []Family
+
+// Collect calls f
+func (f CollectorFunc) Collect() []Family {
+	return f()
+}
+
+// Registry holds the metrics served by one endpoint
+type Registry struct {
+	mu         sync.RWMutex
+	names      map[string]bool
+	collectors []Collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Default is the registry served at /metrics
+var Default = NewRegistry()
+
+// Register adds a collector. Families of the same name from several
+// collectors are merged.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// claim registers a metric name, panicking if it is taken or invalid, like
+// sql.Register
+func (r *Registry) claim(name string, labels []string) {
+	if !validName(name) {
+		panic("metrics: invalid metric name " + strconv.Quote(name))
+	}
+	for _, l := range labels {
+		if !validName(l) || strings.HasPrefix(l, "__") || l == "le" {
+			panic("metrics: invalid label name " + strconv.Quote(l) + " for " + name)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: metric registered twice: " + name)
+	}
+	r.names[name] = true
+}
+
+// validName reports whether s is a valid metric or label name
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Counter registers a counter partitioned by the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	r.claim(name, labels)
+	c := &Counter{vec: newVec(name, help, TypeCounter, labels, func() *value { return &value{} })}
+	r.Register(c)
+	return c
+}
+
+// Gauge registers a gauge partitioned by the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	r.claim(name, labels)
+	g := &Gauge{vec: newVec(name, help, TypeGauge, labels, func() *value { return &value{} })}
+	r.Register(g)
+	return g
+}
+
+// Histogram registers a histogram with the given upper bounds, partitioned by
+// the given label names. Nil buckets means DefBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	r.claim(name, labels)
+	h := &Histogram{vec: newVec(name, help, TypeHistogram, labels, func() *histogramValue {
+		return &histogramValue{bounds: bounds, counts: make([]atomic.Uint64, len(bounds))}
+	})}
+	r.Register(h)
+	return h
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.claim(name, nil)
+	r.Register(CollectorFunc(func() []Family {
+		return []Family{{Name: name, Help: help, Type: TypeGauge, Samples: []Sample{{Value: fn()}}}}
+	}))
+}
+
+// NewCounter registers a counter on the Default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return Default.Counter(name, help, labels...)
+}
+
+// NewGauge registers a gauge on the Default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return Default.Gauge(name, help, labels...)
+}
+
+// NewHistogram registers a histogram on the Default registry
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.Histogram(name, help, buckets, labels...)
+}
+
+// Gather collects every family, merged by name and sorted
+func (r *Registry) Gather() []Family {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	byName := map[string]*Family{}
+	var names []string
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			if existing, ok := byName[f.Name]; ok {
+				existing.Samples = append(existing.Samples, f.Samples...)
+				continue
+			}
+			f := f
+			byName[f.Name] = &f
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+
+	families := make([]Family, 0, len(names))
+	for _, name := range names {
+		families = append(families, *byName[name])
+	}
+	return families
+}
+
+// Write writes every metric to w in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range r.Gather() {
+		writeFamily(bw, f)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry's metrics
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		// A failed write means the scraper went away; there is no one to
+		// report it to
+		_ = r.Write(w)
+	})
+}
+
+// writeFamily writes the HELP and TYPE lines and samples of a family
+func writeFamily(w *bufio.Writer, f Family) {
+	if f.Help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.Name, helpEscaper.Replace(f.Help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Samples {
+		if f.Type != TypeHistogram {
+			writeSample(w, f.Name, s.Labels, formatFloat(s.Value))
+			continue
+		}
+		for i, bound := range s.Bounds {
+			writeSample(w, f.Name+"_bucket", append(s.Labels[:len(s.Labels):len(s.Labels)], Label{"le", formatFloat(bound)}), strconv.FormatUint(s.Buckets[i], 10))
+		}
+		writeSample(w, f.Name+"_bucket", append(s.Labels[:len(s.Labels):len(s.Labels)], Label{"le", "+Inf"}), strconv.FormatUint(s.Count, 10))
+		writeSample(w, f.Name+"_sum", s.Labels, formatFloat(s.Sum))
+		writeSample(w, f.Name+"_count", s.Labels, strconv.FormatUint(s.Count, 10))
+	}
+}
+
+// writeSample writes one sample line
+func writeSample(w *bufio.Writer, name string, labels []Label, value string) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l.Name)
+			w.WriteString(`="`)
+			w.WriteString(labelEscaper.Replace(l.Value))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(value)
+	w.WriteByte('\n')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// formatFloat formats a value as the exposition format expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// render returns the registry's exposition
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	return b.String()
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("jobs_total", "Jobs run.", "queue", "result")
+	c.Inc("mail", "ok")
+	c.With("mail", "ok").Add(2)
+	c.Inc("mail", "failed")
+
+	want := `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+jobs_total{queue="mail",result="failed"} 1
+jobs_total{queue="mail",result="ok"} 3
+`
+	if got := render(t, r); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	t.Run("panics on wrong label count", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic")
+			}
+		}()
+		c.Inc("mail")
+	})
+
+	t.Run("panics on decrease", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic")
+			}
+		}()
+		c.With("mail", "ok").Add(-1)
+	})
+}
+
+func TestGauge(t *testing.T) {
+	r := NewRegistry()
+	g := r.Gauge("queue_depth", "Items waiting.").With()
+	g.Set(5)
+	g.Inc()
+	g.Dec()
+	g.Add(-2.5)
+	r.GaugeFunc("temperature_celsius", "Current temperature.", func() float64 { return 21.5 })
+
+	got := render(t, r)
+	for _, want := range []string{"queue_depth 2.5\n", "temperature_celsius 21.5\n", "# TYPE queue_depth gauge\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "queue_depth") > strings.Index(got, "temperature_celsius") {
+		t.Error("Expected families sorted by name")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("op_seconds", "Operation latency.", []float64{1, 0.1}, "op")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "read")
+	}
+
+	want := `# HELP op_seconds Operation latency.
+# TYPE op_seconds histogram
+op_seconds_bucket{op="read",le="0.1"} 2
+op_seconds_bucket{op="read",le="1"} 3
+op_seconds_bucket{op="read",le="+Inf"} 4
+op_seconds_sum{op="read"} 3.65
+op_seconds_count{op="read"} 4
+`
+	if got := render(t, r); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestRegistration(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("dup_total", "")
+	for name, register := range map[string]func(){
+		"duplicate":     func() { r.Gauge("dup_total", "") },
+		"invalid name":  func() { r.Counter("bad-name", "") },
+		"reserved le":   func() { r.Histogram("h", "", nil, "le") },
+		"leading digit": func() { r.Counter("1st", "") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic")
+				}
+			}()
+			register()
+		})
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("escaped_total", "Line one\nback\\slash", "path").Inc("a\"b\\c\nd")
+
+	got := render(t, r)
+	if !strings.Contains(got, `# HELP escaped_total Line one\nback\\slash`) {
+		t.Errorf("Expected an escaped help line, got\n%s", got)
+	}
+	if !strings.Contains(got, `escaped_total{path="a\"b\\c\nd"} 1`) {
+		t.Errorf("Expected an escaped label value, got\n%s", got)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("hits_total", "", "shard")
+	h := r.Histogram("lat_seconds", "", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc("a")
+				h.Observe(0.01)
+				r.Gather()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v := c.With("a").Value(); v != 8000 {
+		t.Errorf("Expected 8000, got %v", v)
+	}
+	if n := h.With().Count(); n != 8000 {
+		t.Errorf("Expected 8000 observations, got %d", n)
+	}
+}
+
+func TestCollectors(t *testing.T) {
+	r := NewRegistry()
+	r.Register(NewRuntimeCollector())
+	r.Register(NewDBStatsCollector("read", func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: 1500 * time.Millisecond}
+	}))
+	r.Register(NewDBStatsCollector("write", func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 1}
+	}))
+
+	got := render(t, r)
+	for _, want := range []string{
+		`db_open_connections{pool="read"} 3`,
+		`db_wait_duration_seconds_total{pool="read"} 1.5`,
+		`db_max_open_connections{pool="write"} 1`,
+		"go_goroutines ",
+		"go_memstats_heap_inuse_bytes ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, "# TYPE db_open_connections gauge"); n != 1 {
+		t.Errorf("Expected the pools merged into one family, got %d TYPE lines", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("served_total", "").Inc()
+
+	rr := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Unexpected response %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), "served_total 1\n") {
+		t.Errorf("Expected the counter, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,265 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// vec holds the series of one metric, keyed by their label values
+type vec[V any] struct {
+	name   string
+	help   string
+	typ    Type
+	labels []string
+	create func() *V
+
+	mu     sync.RWMutex
+	series map[string]*series[V]
+}
+
+// series is one combination of label values and its value
+type series[V any] struct {
+	values []string
+	value  *V
+}
+
+func newVec[V any](name, help string, typ Type, labels []string, create func() *V) *vec[V] {
+	return &vec[V]{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: append([]string(nil), labels...),
+		create: create,
+		series: make(map[string]*series[V]),
+	}
+}
+
+// with returns the value of the series with the given label values, creating
+// it on first use. It panics if the number of values does not match the
+// labels.
+func (v *vec[V]) with(values []string) *V {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " takes " + strconv.Itoa(len(v.labels)) + " label values, got " + strconv.Itoa(len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.value
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	s = &series[V]{values: append([]string(nil), values...), value: v.create()}
+	v.series[key] = s
+	return s.value
+}
+
+// collect returns the family with one sample per series, sorted by label
+// values, built by sample
+func (v *vec[V]) collect(sample func(labels []Label, value *V) Sample) []Family {
+	v.mu.RLock()
+	all := make([]*series[V], 0, len(v.series))
+	for _, s := range v.series {
+		all = append(all, s)
+	}
+	v.mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+
+	family := Family{Name: v.name, Help: v.help, Type: v.typ, Samples: make([]Sample, 0, len(all))}
+	for _, s := range all {
+		labels := make([]Label, len(v.labels))
+		for i, name := range v.labels {
+			labels[i] = Label{Name: name, Value: s.values[i]}
+		}
+		family.Samples = append(family.Samples, sample(labels, s.value))
+	}
+	return []Family{family}
+}
+
+// value is a float64 updated atomically
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+// Counter is a value that only goes up, such as a number of requests
+type Counter struct {
+	vec *vec[value]
+}
+
+// CounterValue is the series of a counter with particular label values
+type CounterValue struct {
+	v *value
+}
+
+// With returns the series with the given label values, in the order of the
+// counter's label names
+func (c *Counter) With(values ...string) CounterValue {
+	return CounterValue{c.vec.with(values)}
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(values ...string) {
+	c.With(values...).Inc()
+}
+
+// Inc adds one
+func (c CounterValue) Inc() {
+	c.v.add(1)
+}
+
+// Add adds delta, which must not be negative
+func (c CounterValue) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+// Value returns the current value
+func (c CounterValue) Value() float64 {
+	return c.v.get()
+}
+
+// Collect implements Collector
+func (c *Counter) Collect() []Family {
+	return c.vec.collect(func(labels []Label, v *value) Sample {
+		return Sample{Labels: labels, Value: v.get()}
+	})
+}
+
+// Gauge is a value that goes up and down, such as a number of connections
+type Gauge struct {
+	vec *vec[value]
+}
+
+// GaugeValue is the series of a gauge with particular label values
+type GaugeValue struct {
+	v *value
+}
+
+// With returns the series with the given label values, in the order of the
+// gauge's label names
+func (g *Gauge) With(values ...string) GaugeValue {
+	return GaugeValue{g.vec.with(values)}
+}
+
+// Set sets the value
+func (g GaugeValue) Set(f float64) {
+	g.v.set(f)
+}
+
+// Add adds delta, which may be negative
+func (g GaugeValue) Add(delta float64) {
+	g.v.add(delta)
+}
+
+// Inc adds one
+func (g GaugeValue) Inc() {
+	g.v.add(1)
+}
+
+// Dec subtracts one
+func (g GaugeValue) Dec() {
+	g.v.add(-1)
+}
+
+// Value returns the current value
+func (g GaugeValue) Value() float64 {
+	return g.v.get()
+}
+
+// Collect implements Collector
+func (g *Gauge) Collect() []Family {
+	return g.vec.collect(func(labels []Label, v *value) Sample {
+		return Sample{Labels: labels, Value: v.get()}
+	})
+}
+
+// Histogram counts observations, such as request durations, in buckets
+type Histogram struct {
+	vec *vec[histogramValue]
+}
+
+// histogramValue holds the bucket counts and sum of one series; counts are
+// per bucket and made cumulative when collected
+type histogramValue struct {
+	bounds []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    value
+}
+
+// HistogramValue is the series of a histogram with particular label values
+type HistogramValue struct {
+	v *histogramValue
+}
+
+// With returns the series with the given label values, in the order of the
+// histogram's label names
+func (h *Histogram) With(values ...string) HistogramValue {
+	return HistogramValue{h.vec.with(values)}
+}
+
+// Observe records a value in the series with the given label values
+func (h *Histogram) Observe(f float64, values ...string) {
+	h.With(values...).Observe(f)
+}
+
+// Observe records a value
+func (h HistogramValue) Observe(f float64) {
+	i := sort.SearchFloat64s(h.v.bounds, f)
+	if i < len(h.v.counts) {
+		h.v.counts[i].Add(1)
+	}
+	h.v.sum.add(f)
+	h.v.count.Add(1)
+}
+
+// Count returns the number of observations
+func (h HistogramValue) Count() uint64 {
+	return h.v.count.Load()
+}
+
+// Collect implements Collector
+func (h *Histogram) Collect() []Family {
+	return h.vec.collect(func(labels []Label, v *histogramValue) Sample {
+		// Read the total first, so the buckets never exceed it
+		count := v.count.Load()
+		buckets := make([]uint64, len(v.bounds))
+		var cumulative uint64
+		for i := range v.counts {
+			cumulative += v.counts[i].Load()
+			buckets[i] = min(cumulative, count)
+		}
+		return Sample{Labels: labels, Bounds: v.bounds, Buckets: buckets, Sum: v.sum.get(), Count: count}
+	})
+}
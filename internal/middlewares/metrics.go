@@ -0,0 +1,79 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("http_requests_total",
+		"Total number of HTTP requests by method, route pattern and status.",
+		"method", "route", "status")
+	httpRequestDuration = metrics.NewHistogram("http_request_duration_seconds",
+		"HTTP request latency by method, route pattern and status.",
+		metrics.DefBuckets, "method", "route", "status")
+	httpRequestsInFlight = metrics.NewGauge("http_requests_in_flight",
+		"Number of HTTP requests being served.").With()
+	rateLimitRejections = metrics.NewCounter("http_rate_limit_rejections_total",
+		"Total number of requests rejected by the rate limiter.")
+)
+
+// MetricsMiddleware counts requests and records their latency, labelled with
+// the matched route pattern rather than the path so that ids in paths do not
+// create a series each. Middleware between it and the ServeMux must pass the
+// *http.Request through rather than replace it, e.g. with WithContext, or the
+// pattern the ServeMux sets on it is not seen.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+
+		wrapped := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(wrapped, r)
+
+		status := wrapped.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{methodLabel(r.Method), route, strconv.Itoa(status)}
+		httpRequests.Inc(labels...)
+		httpRequestDuration.Observe(time.Since(start).Seconds(), labels...)
+	})
+}
+
+// methodLabel returns the method as a label value, mapping non-standard
+// methods to OTHER so clients cannot create unbounded series
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// MetricsTokenMiddleware requires requests to send token as a bearer token,
+// so only scrapers that know it can read the metrics
+func MetricsTokenMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(sent)), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/metrics"
+)
+
+// TestMetricsMiddleware tests that requests are counted by route pattern
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("POST /things", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := MetricsMiddleware(mux)
+
+	before := httpRequests.With("GET", "GET /things/{id}", "200").Value()
+	for _, path := range []string{"/things/1", "/things/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/things", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if got := httpRequests.With("GET", "GET /things/{id}", "200").Value() - before; got != 2 {
+		t.Errorf("Expected 2 requests for the pattern, got %v", got)
+	}
+	if got := httpRequests.With("POST", "POST /things", "201").Value(); got < 1 {
+		t.Errorf("Expected the POST to be counted with its status, got %v", got)
+	}
+	if got := httpRequests.With("GET", "unmatched", "404").Value(); got < 1 {
+		t.Errorf("Expected the unmatched request to be counted, got %v", got)
+	}
+	before = httpRequests.With("OTHER", "unmatched", "405").Value()
+	for _, method := range []string{"FOO", "BAR"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/things", nil))
+	}
+	if got := httpRequests.With("OTHER", "unmatched", "405").Value() - before; got != 2 {
+		t.Errorf("Expected non-standard methods to be counted as OTHER, got %v", got)
+	}
+	if n := httpRequestDuration.With("GET", "GET /things/{id}", "200").Count(); n < 2 {
+		t.Errorf("Expected 2 latency observations, got %d", n)
+	}
+
+	var b strings.Builder
+	if err := metrics.Default.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !strings.Contains(b.String(), `http_requests_total{method="GET",route="GET /things/{id}",status="200"}`) {
+		t.Errorf("Expected the request counter in the exposition, got\n%s", b.String())
+	}
+}
+
+// TestRateLimitRejectionsCounted tests that rejected requests are counted
+func TestRateLimitRejectionsCounted(t *testing.T) {
+	handler := RateLimitMiddleware(1, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	before := rateLimitRejections.With().Value()
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+		req.RemoteAddr = "203.0.113.9:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if got := rateLimitRejections.With().Value() - before; got != 2 {
+		t.Errorf("Expected 2 rejections, got %v", got)
+	}
+}
+
+// TestMetricsTokenMiddleware tests that scrapes need the bearer token
+func TestMetricsTokenMiddleware(t *testing.T) {
+	handler := MetricsTokenMiddleware("s3cret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Authorization %q: expected %d, got %d", header, want, rr.Code)
+		}
+	}
+}
@@ -58,6 +58,10 @@ func RequestIDMiddleware(next http.Handler) http.Handler {
 
 // LoggingMiddleware logs request details with correlation ID
 func LoggingMiddleware(next http.Handler) http.Handler {
+	// Requests are logged under the "http" logger, whose level can be changed
+	// on its own; its name is resolved once rather than per request
+	httpLogger := logger.NewLoggerName("http")
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -74,7 +78,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		correlationID := logger.GetCorrelationID(r.Context())
 
 		// Log request details
-		logger.InfoCtx(r.Context(), "HTTP request",
+		log := httpLogger.Logger(logger.FromContext(r.Context()))
+		log.Info("HTTP request",
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 			slog.String("query", r.URL.RawQuery),
@@ -87,7 +92,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 		// Also log if correlation ID was set
 		if correlationID != "" {
-			logger.DebugCtx(r.Context(), "Request processed with correlation ID",
+			log.Debug("Request processed with correlation ID",
 				slog.String("correlation_id", correlationID),
 			)
 		}
@@ -114,6 +119,12 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the wrapped writer, so http.ResponseController can flush
+// streamed responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Context keys for storing values in request context
 type contextKey string
 
@@ -149,8 +160,9 @@ func JWTAuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Set user ID in context
+		// Set user ID and principal in context
 		ctx := context.WithValue(r.Context(), UserIDContextKey, userID)
+		ctx = auth.WithPrincipal(ctx, userID)
 
 		// Serve request with new context
 		next.ServeHTTP(w, r.WithContext(ctx))
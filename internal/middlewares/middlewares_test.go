@@ -156,6 +156,21 @@ func TestLoggingMiddleware(t *testing.T) {
 	}
 }
 
+func TestLoggingMiddlewareFlushes(t *testing.T) {
+	handler := LoggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Expected the wrapped writer to flush, got %v", err)
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/stream", nil))
+	if !rr.Flushed {
+		t.Error("Expected the response to be flushed")
+	}
+}
+
 func TestLoggingMiddlewareWithCorrelationID(t *testing.T) {
 	// Capture log output
 	var logBuf bytes.Buffer
@@ -152,8 +152,8 @@ func RateLimitMiddleware(maxRequests int, windowSize time.Duration) func(http.Ha
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip rate limiting for health endpoints (they're used by monitoring)
-			if r.URL.Path == "/healthz" || r.URL.Path == "/livez" || r.URL.Path == "/readyz" {
+			// Skip rate limiting for health and metrics endpoints (they're used by monitoring)
+			if r.URL.Path == "/healthz" || r.URL.Path == "/livez" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics" {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -164,6 +164,8 @@ func RateLimitMiddleware(maxRequests int, windowSize time.Duration) func(http.Ha
 			allowed, retryAfter := limiter.isAllowed(clientIP)
 
 			if !allowed {
+				rateLimitRejections.Inc()
+
 				// Return 429 Too Many Requests
 				w.Header().Set("Content-Type", "application/json")
 				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
@@ -363,7 +363,7 @@ func TestRateLimitMiddlewareHealthEndpoints(t *testing.T) {
 	server := httptest.NewServer(protectedHandler)
 	defer server.Close()
 
-	healthEndpoints := []string{"/healthz", "/livez", "/readyz"}
+	healthEndpoints := []string{"/healthz", "/livez", "/readyz", "/metrics"}
 
 	for _, endpoint := range healthEndpoints {
 		// Make multiple requests to health endpoint - all should succeed
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+	"github.com/tediscript/gostarterkit/internal/tracing"
+)
+
+// TracingMiddleware records a server span for each request, continuing the
+// trace of an incoming traceparent header. The span is named after the
+// matched route pattern, so like MetricsMiddleware it must not be separated
+// from the ServeMux by middleware that replaces the request.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method,
+			tracing.WithKind(tracing.KindServer),
+			tracing.WithAttributes(
+				tracing.String("http.request.method", r.Method),
+				tracing.String("url.path", r.URL.Path),
+				tracing.String("client.address", r.RemoteAddr),
+				tracing.String("user_agent.original", r.UserAgent()),
+				tracing.String("correlation_id", logger.GetCorrelationID(ctx)),
+				tracing.String("request_id", logger.GetRequestID(ctx)),
+			),
+		)
+		defer span.End()
+
+		wrapped := &responseWriter{ResponseWriter: w}
+		req := r.WithContext(ctx)
+		next.ServeHTTP(wrapped, req)
+
+		status := wrapped.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if req.Pattern != "" {
+			span.SetName(req.Pattern)
+			span.SetAttributes(tracing.String("http.route", req.Pattern))
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, strconv.Itoa(status))
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/tracing"
+)
+
+// spanRecorder is a tracing processor that keeps the spans it is given
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *spanRecorder) OnEnd(span tracing.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+// TestTracingMiddleware tests that requests get a server span named after
+// their route that continues an incoming trace
+func TestTracingMiddleware(t *testing.T) {
+	rec := &spanRecorder{}
+	previous := tracing.CurrentTracer()
+	tracing.SetTracer(tracing.NewTracer(1, rec))
+	defer tracing.SetTracer(previous)
+
+	var handlerTrace tracing.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTrace = tracing.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := TracingMiddleware(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/things/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(rec.spans))
+	}
+	span := rec.spans[0]
+	if span.Name != "GET /things/{id}" || span.Kind != tracing.KindServer {
+		t.Errorf("Expected a server span named after the route, got %q", span.Name)
+	}
+	if span.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.String() != "00f067aa0ba902b7" {
+		t.Error("Expected the incoming trace continued")
+	}
+	if handlerTrace.SpanID != span.SpanContext.SpanID {
+		t.Error("Expected the span in the handler's context")
+	}
+	if span.Status != tracing.StatusError {
+		t.Errorf("Expected a 500 to mark the span as failed, got %d", span.Status)
+	}
+	if rec.spans[1].Name != http.MethodGet || rec.spans[1].Status != tracing.StatusUnset {
+		t.Errorf("Expected an unmatched request named after its method, got %q", rec.spans[1].Name)
+	}
+}
@@ -2,210 +2,192 @@ package models
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/outbox"
 )
 
 // User represents a user in the system
 type User struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint       `json:"id" db:"id,pk,auto"`
+	Username  string     `json:"username" db:"username"`
+	Email     string     `json:"email" db:"email"`
+	Name      string     `json:"name" db:"name"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at,created"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at,updated"`
+	CreatedBy string     `json:"created_by" db:"created_by,creator"`
+	UpdatedBy string     `json:"updated_by" db:"updated_by,updater"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at,deleted"`
+	Version   int        `json:"version" db:"version,version"`
 }
 
+// TableName returns the table users are stored in
+func (User) TableName() string {
+	return "users"
+}
+
+// Outbox topics of user events. They are enqueued in the same transaction as
+// the change; created and updated events carry the user, deleted events its id.
+const (
+	UserCreatedEvent = "user.created"
+	UserUpdatedEvent = "user.updated"
+	UserDeletedEvent = "user.deleted"
+)
+
 // UserRepository handles database operations for users
 type UserRepository struct {
+	repo *Repository[User]
+	// db starts transactions for writes; tx is used instead once set
 	db *database.Database
+	tx *database.Tx
 }
 
 // NewUserRepository creates a new user repository
 func NewUserRepository(db *database.Database) *UserRepository {
-	return &UserRepository{db: db}
+	return &UserRepository{repo: NewRepository[User](db), db: db}
 }
 
-// Create creates a new user in the database
-func (r *UserRepository) Create(ctx context.Context, user *User) error {
-	query := `
-		INSERT INTO users (username, email, created_at, updated_at)
-		VALUES (?, ?, ?, ?)
-	`
-	result, err := r.db.Exec(ctx, query, user.Username, user.Email, time.Now(), time.Now())
-	if err != nil {
-		return fmt.Errorf("failed to create user: %w", err)
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *UserRepository) WithTx(tx *database.Tx) *UserRepository {
+	return &UserRepository{repo: r.repo.WithTx(tx), db: r.db, tx: tx}
+}
+
+// inTx runs fn in the repository's transaction, or in a new one if it has
+// none, so a change and its outbox event commit together
+func (r *UserRepository) inTx(ctx context.Context, fn func(repo *Repository[User], tx *database.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.repo, r.tx)
 	}
+	return r.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		return fn(r.repo.WithTx(tx), tx)
+	})
+}
 
-	id, err := result.LastInsertId()
+// Create creates a new user in the database and enqueues a UserCreatedEvent
+func (r *UserRepository) Create(ctx context.Context, user *User) error {
+	// Work on a copy: the transaction may be retried, and user must only
+	// change if it commits
+	var created User
+	err := r.inTx(ctx, func(repo *Repository[User], tx *database.Tx) error {
+		created = *user
+		if err := repo.Create(ctx, &created); err != nil {
+			return err
+		}
+		return outbox.Enqueue(ctx, tx, UserCreatedEvent, &created)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+		return err
 	}
-
-	user.ID = uint(id)
+	*user = created
 	return nil
 }
 
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(ctx context.Context, id uint) (*User, error) {
-	query := `
-		SELECT id, username, email, created_at, updated_at
-		FROM users
-		WHERE id = ?
-	`
-	var user User
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-	return &user, nil
+	return r.repo.Get(ctx, id)
 }
 
 // GetByUsername retrieves a user by username
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
-	query := `
-		SELECT id, username, email, created_at, updated_at
-		FROM users
-		WHERE username = ?
-	`
-	var user User
-	err := r.db.QueryRow(ctx, query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-	return &user, nil
+	return r.repo.GetBy(ctx, "username", username)
 }
 
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `
-		SELECT id, username, email, created_at, updated_at
-		FROM users
-		WHERE email = ?
-	`
-	var user User
-	err := r.db.QueryRow(ctx, query, email).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
-		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
-	}
-	return &user, nil
+	return r.repo.GetBy(ctx, "email", email)
 }
 
-// Update updates a user in the database
+// Update updates a user in the database and enqueues a UserUpdatedEvent. It
+// fails with database.ErrVersionConflict if user.Version is not the stored
+// version, and increments user.Version on success.
 func (r *UserRepository) Update(ctx context.Context, user *User) error {
-	query := `
-		UPDATE users
-		SET username = ?, email = ?, updated_at = ?
-		WHERE id = ?
-	`
-	result, err := r.db.Exec(ctx, query, user.Username, user.Email, time.Now(), user.ID)
-	if err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	var updated User
+	err := r.inTx(ctx, func(repo *Repository[User], tx *database.Tx) error {
+		updated = *user
+		if err := repo.Update(ctx, &updated); err != nil {
+			return err
+		}
+		return outbox.Enqueue(ctx, tx, UserUpdatedEvent, &updated)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return err
 	}
-
+	*user = updated
 	return nil
 }
 
-// Delete deletes a user from the database
+// Delete soft-deletes a user and enqueues a UserDeletedEvent; the user is
+// hidden from every other method until restored
 func (r *UserRepository) Delete(ctx context.Context, id uint) error {
-	query := `DELETE FROM users WHERE id = ?`
-	result, err := r.db.Exec(ctx, query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
+	return r.inTx(ctx, func(repo *Repository[User], tx *database.Tx) error {
+		if err := repo.Delete(ctx, id); err != nil {
+			return err
+		}
+		return outbox.Enqueue(ctx, tx, UserDeletedEvent, map[string]uint{"id": id})
+	})
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
+// Restore undoes the soft delete of a user
+func (r *UserRepository) Restore(ctx context.Context, id uint) error {
+	return r.repo.Restore(ctx, id)
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
-	}
+// Purge permanently deletes a user, whether or not it is soft-deleted
+func (r *UserRepository) Purge(ctx context.Context, id uint) error {
+	return r.repo.Purge(ctx, id)
+}
 
-	return nil
+// UserPagination lists the sort fields and page sizes accepted for users
+var UserPagination = Pagination{
+	Sorts: map[string]string{
+		"id":         "id",
+		"username":   "username",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+	DefaultSort:  "-created_at",
+	DefaultLimit: 20,
+	MaxLimit:     100,
 }
 
-// List retrieves a list of users with pagination
-func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]User, error) {
-	query := `
-		SELECT id, username, email, created_at, updated_at
-		FROM users
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`
-	rows, err := r.db.Query(ctx, query, limit, offset)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list users: %w", err)
+// UserFilter narrows a user listing; zero fields are ignored
+type UserFilter struct {
+	// EmailDomain matches users whose email is at this domain, e.g. "example.com"
+	EmailDomain string
+	// CreatedAfter and CreatedBefore bound created_at, inclusive and exclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// likeEscaper escapes LIKE wildcards, for use with ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Expr returns the filter as a condition
+func (f UserFilter) Expr() database.Expr {
+	var exprs []database.Expr
+	if f.EmailDomain != "" {
+		exprs = append(exprs, database.Raw(`email LIKE ? ESCAPE '\'`, "%@"+likeEscaper.Replace(f.EmailDomain)))
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.Email,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
-		}
-		users = append(users, user)
+	// Timestamps are stored in local time and compared as text, so bounds
+	// are converted to match
+	if !f.CreatedAfter.IsZero() {
+		exprs = append(exprs, database.Gte("created_at", f.CreatedAfter.Local()))
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %w", err)
+	if !f.CreatedBefore.IsZero() {
+		exprs = append(exprs, database.Lt("created_at", f.CreatedBefore.Local()))
 	}
+	return database.And(exprs...)
+}
 
-	return users, nil
+// List retrieves a page of users matching filter, newest first unless
+// page.Sort says otherwise
+func (r *UserRepository) List(ctx context.Context, filter UserFilter, page PageRequest) (*Page[User], error) {
+	return r.repo.Paginate(ctx, r.repo.Query().WhereExpr(filter.Expr()), UserPagination, page)
 }
 
 // Count returns the total number of users
 func (r *UserRepository) Count(ctx context.Context) (int, error) {
-	var count int
-	err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
-	if err != nil {
-		return 0, fmt.Errorf("failed to count users: %w", err)
-	}
-	return count, nil
+	return r.repo.Count(ctx, nil)
 }
@@ -2,66 +2,25 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/auth"
 	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
 )
 
 func setupTestDBWithUsers(t *testing.T) (*database.Database, *UserRepository, func()) {
 	t.Helper()
 
-	// Create a temporary database file
-	tmpDir := t.TempDir()
-	dbFile := tmpDir + "/test.db"
-
-	// Create test config
-	cfg := &config.Config{}
-	cfg.SQLite.DBFile = dbFile
-	cfg.SQLite.MaxOpenConnections = 5
-	cfg.SQLite.MaxIdleConnections = 2
-	cfg.SQLite.ConnectionMaxLifetimeSeconds = 300
-
-	// Create database
-	db, err := database.New(cfg)
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
-
-	// Create users table
-	ctx := context.Background()
-	_, err = db.Exec(ctx, `
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			username TEXT NOT NULL UNIQUE,
-			email TEXT NOT NULL UNIQUE,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		);
-	`)
-	if err != nil {
-		db.Close()
-		t.Fatalf("Failed to create users table: %v", err)
-	}
-
-	// Create user repository
-	repo := NewUserRepository(db)
-
-	// Cleanup function
-	cleanup := func() {
-		if err := db.Close(); err != nil {
-			t.Errorf("Failed to close database: %v", err)
-		}
-		// Remove database files
-		os.Remove(dbFile)
-		os.Remove(dbFile + "-wal")
-		os.Remove(dbFile + "-shm")
-	}
-
-	return db, repo, cleanup
+	// Create the schema from the real migrations; the database is closed when
+	// the test ends
+	db := seedtest.NewDatabase(t, "../../migrations", "")
+	return db, NewUserRepository(db), func() {}
 }
 
 func TestCreateUser(t *testing.T) {
@@ -116,8 +75,12 @@ func TestCreateUser(t *testing.T) {
 		}
 
 		err = repo.Create(ctx, user2)
-		if err == nil {
-			t.Error("Expected error for duplicate username")
+		var conflict *database.ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("Expected ConflictError for duplicate username, got: %v", err)
+		}
+		if conflict.Table != "users" || len(conflict.Columns) != 1 || conflict.Columns[0] != "username" {
+			t.Errorf("Expected conflict on users.username, got %s %v", conflict.Table, conflict.Columns)
 		}
 	})
 
@@ -137,8 +100,8 @@ func TestCreateUser(t *testing.T) {
 		}
 
 		err = repo.Create(ctx, user2)
-		if err == nil {
-			t.Error("Expected error for duplicate email")
+		if !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected ErrConflict for duplicate email, got: %v", err)
 		}
 	})
 }
@@ -183,8 +146,8 @@ func TestGetUserByID(t *testing.T) {
 			t.Error("Expected error for non-existent user")
 		}
 
-		if err.Error() != "user not found" {
-			t.Errorf("Expected 'user not found' error, got: %v", err)
+		if !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got: %v", err)
 		}
 	})
 }
@@ -315,8 +278,8 @@ func TestUpdateUser(t *testing.T) {
 		}
 
 		err := repo.Update(ctx, nonExistentUser)
-		if err == nil {
-			t.Error("Expected error for non-existent user")
+		if !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for non-existent user, got: %v", err)
 		}
 	})
 }
@@ -351,8 +314,185 @@ func TestDeleteUser(t *testing.T) {
 
 	t.Run("delete non-existent user", func(t *testing.T) {
 		err := repo.Delete(ctx, 999)
-		if err == nil {
-			t.Error("Expected error for non-existent user")
+		if !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for non-existent user, got: %v", err)
+		}
+	})
+}
+
+func TestSoftDeleteUser(t *testing.T) {
+	_, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	ctx := auth.WithPrincipal(context.Background(), "admin")
+
+	user := &User{Username: "testuser", Email: "test@example.com", Name: "Test User"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	t.Run("hidden from queries", func(t *testing.T) {
+		if _, err := repo.GetByUsername(ctx, "testuser"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if count, _ := repo.Count(ctx); count != 0 {
+			t.Errorf("Expected 0 users, got %d", count)
+		}
+		if page, _ := repo.List(ctx, UserFilter{}, PageRequest{}); len(page.Items) != 0 {
+			t.Errorf("Expected no listed users, got %+v", page.Items)
+		}
+		if page, _ := repo.Search(ctx, "test", PageRequest{}); len(page.Items) != 0 {
+			t.Errorf("Expected no search results, got %+v", page.Items)
+		}
+		if err := repo.Update(ctx, user); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected updating a deleted user to fail, got %v", err)
+		}
+		if err := repo.Delete(ctx, user.ID); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected deleting twice to fail, got %v", err)
+		}
+	})
+
+	t.Run("kept with a timestamp", func(t *testing.T) {
+		deleted, err := repo.repo.Unscoped().Get(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Expected the row to remain, got %v", err)
+		}
+		if deleted.DeletedAt == nil || deleted.DeletedAt.IsZero() {
+			t.Errorf("Expected DeletedAt to be set, got %v", deleted.DeletedAt)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		if err := repo.Restore(ctx, user.ID); err != nil {
+			t.Fatalf("Failed to restore user: %v", err)
+		}
+		restored, err := repo.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("Expected restored user to be visible, got %v", err)
+		}
+		if restored.DeletedAt != nil {
+			t.Errorf("Expected DeletedAt to be cleared, got %v", restored.DeletedAt)
+		}
+		if err := repo.Restore(ctx, user.ID); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected restoring a live user to fail, got %v", err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		if err := repo.Delete(ctx, user.ID); err != nil {
+			t.Fatalf("Failed to delete user: %v", err)
+		}
+		if err := repo.Purge(ctx, user.ID); err != nil {
+			t.Fatalf("Failed to purge user: %v", err)
+		}
+		if _, err := repo.repo.Unscoped().Get(ctx, user.ID); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected purged user to be gone, got %v", err)
+		}
+		if err := repo.Purge(ctx, user.ID); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestUserAuditColumns(t *testing.T) {
+	_, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	user := &User{Username: "testuser", Email: "test@example.com"}
+	if err := repo.Create(auth.WithPrincipal(context.Background(), "alice"), user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	user.Email = "new@example.com"
+	if err := repo.Update(auth.WithPrincipal(context.Background(), "bob"), user); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+
+	got, err := repo.GetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if got.CreatedBy != "alice" || got.UpdatedBy != "bob" {
+		t.Errorf("Expected created by alice and updated by bob, got %q and %q", got.CreatedBy, got.UpdatedBy)
+	}
+
+	// Without a principal the audit columns record nobody
+	if err := repo.Delete(context.Background(), user.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	got, _ = repo.repo.Unscoped().Get(context.Background(), user.ID)
+	if got.CreatedBy != "alice" || got.UpdatedBy != "" {
+		t.Errorf("Expected created by alice and updated by nobody, got %q and %q", got.CreatedBy, got.UpdatedBy)
+	}
+}
+
+func TestUserVersioning(t *testing.T) {
+	_, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	user := &User{Username: "testuser", Email: "test@example.com"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if user.Version != 1 {
+		t.Errorf("Expected a new user at version 1, got %d", user.Version)
+	}
+
+	// Two editors load the same version
+	first, _ := repo.GetByID(ctx, user.ID)
+	second, _ := repo.GetByID(ctx, user.ID)
+
+	t.Run("update increments the version", func(t *testing.T) {
+		first.Email = "first@example.com"
+		if err := repo.Update(ctx, first); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		if first.Version != 2 {
+			t.Errorf("Expected version 2, got %d", first.Version)
+		}
+		stored, _ := repo.GetByID(ctx, user.ID)
+		if stored.Version != 2 {
+			t.Errorf("Expected stored version 2, got %d", stored.Version)
+		}
+	})
+
+	t.Run("stale update conflicts", func(t *testing.T) {
+		second.Email = "second@example.com"
+		err := repo.Update(ctx, second)
+		if !errors.Is(err, database.ErrVersionConflict) || !errors.Is(err, database.ErrConflict) {
+			t.Fatalf("Expected ErrVersionConflict, got %v", err)
+		}
+		if second.Version != 1 {
+			t.Errorf("Expected a failed update to keep version 1, got %d", second.Version)
+		}
+		stored, _ := repo.GetByID(ctx, user.ID)
+		if stored.Email != "first@example.com" {
+			t.Errorf("Expected the first update to survive, got %s", stored.Email)
+		}
+	})
+
+	t.Run("missing user is not found", func(t *testing.T) {
+		err := repo.Update(ctx, &User{ID: 999, Username: "ghost", Email: "ghost@example.com", Version: 1})
+		if !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("soft delete and restore increment the version", func(t *testing.T) {
+		if err := repo.Delete(ctx, user.ID); err != nil {
+			t.Fatalf("Failed to delete user: %v", err)
+		}
+		if err := repo.Restore(ctx, user.ID); err != nil {
+			t.Fatalf("Failed to restore user: %v", err)
+		}
+		stored, _ := repo.GetByID(ctx, user.ID)
+		if stored.Version != 4 {
+			t.Errorf("Expected version 4, got %d", stored.Version)
 		}
 	})
 }
@@ -366,7 +506,7 @@ func TestListUsers(t *testing.T) {
 	// Create multiple users
 	users := []User{
 		{Username: "user1", Email: "user1@example.com"},
-		{Username: "user2", Email: "user2@example.com"},
+		{Username: "user2", Email: "user2@example.org"},
 		{Username: "user3", Email: "user3@example.com"},
 	}
 
@@ -377,44 +517,141 @@ func TestListUsers(t *testing.T) {
 	}
 
 	t.Run("list all users", func(t *testing.T) {
-		retrieved, err := repo.List(ctx, 10, 0)
+		page, err := repo.List(ctx, UserFilter{}, PageRequest{})
 		if err != nil {
 			t.Fatalf("Failed to list users: %v", err)
 		}
 
-		if len(retrieved) != 3 {
-			t.Errorf("Expected 3 users, got %d", len(retrieved))
+		if len(page.Items) != 3 {
+			t.Errorf("Expected 3 users, got %d", len(page.Items))
+		}
+		if page.Items[0].Username != "user3" {
+			t.Errorf("Expected newest user first, got %s", page.Items[0].Username)
+		}
+		if page.NextCursor != "" || page.Total != nil {
+			t.Errorf("Expected no cursor or total, got %q %v", page.NextCursor, page.Total)
 		}
 	})
 
-	t.Run("pagination", func(t *testing.T) {
-		retrieved, err := repo.List(ctx, 2, 0)
+	t.Run("cursor pagination", func(t *testing.T) {
+		page, err := repo.List(ctx, UserFilter{}, PageRequest{Limit: 2, Sort: "username", IncludeTotal: true})
 		if err != nil {
 			t.Fatalf("Failed to list users: %v", err)
 		}
+		if len(page.Items) != 2 || page.Items[0].Username != "user1" || page.Items[1].Username != "user2" {
+			t.Fatalf("Unexpected first page %+v", page.Items)
+		}
+		if page.Total == nil || *page.Total != 3 {
+			t.Errorf("Expected total 3, got %v", page.Total)
+		}
+		if page.Number != 1 || page.Limit != 2 {
+			t.Errorf("Expected page 1 of size 2, got page %d of size %d", page.Number, page.Limit)
+		}
+		if page.NextCursor == "" {
+			t.Fatal("Expected a next cursor")
+		}
 
-		if len(retrieved) != 2 {
-			t.Errorf("Expected 2 users, got %d", len(retrieved))
+		// A row inserted before the cursor must not shift the next page
+		early := &User{Username: "user0", Email: "user0@example.com"}
+		if err := repo.Create(ctx, early); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
 		}
+		defer repo.Delete(ctx, early.ID)
 
-		retrieved, err = repo.List(ctx, 2, 2)
+		page, err = repo.List(ctx, UserFilter{}, PageRequest{Limit: 2, Sort: "username", Cursor: page.NextCursor})
 		if err != nil {
 			t.Fatalf("Failed to list users: %v", err)
 		}
+		if len(page.Items) != 1 || page.Items[0].Username != "user3" {
+			t.Errorf("Expected only user3 on the last page, got %+v", page.Items)
+		}
+		if page.Number != 2 {
+			t.Errorf("Expected page 2, got %d", page.Number)
+		}
+		if page.NextCursor != "" {
+			t.Errorf("Expected no cursor on the last page, got %q", page.NextCursor)
+		}
+	})
 
-		if len(retrieved) != 1 {
-			t.Errorf("Expected 1 user, got %d", len(retrieved))
+	t.Run("descending by timestamp", func(t *testing.T) {
+		var seen []string
+		req := PageRequest{Limit: 1, Sort: "-created_at"}
+		for {
+			page, err := repo.List(ctx, UserFilter{}, req)
+			if err != nil {
+				t.Fatalf("Failed to list users: %v", err)
+			}
+			for _, u := range page.Items {
+				seen = append(seen, u.Username)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			req.Cursor = page.NextCursor
+		}
+		if strings.Join(seen, ",") != "user3,user2,user1" {
+			t.Errorf("Unexpected order %v", seen)
 		}
 	})
 
-	t.Run("empty result", func(t *testing.T) {
-		retrieved, err := repo.List(ctx, 10, 100)
+	t.Run("filters", func(t *testing.T) {
+		page, err := repo.List(ctx, UserFilter{EmailDomain: "example.com"}, PageRequest{IncludeTotal: true})
 		if err != nil {
 			t.Fatalf("Failed to list users: %v", err)
 		}
+		if len(page.Items) != 2 || *page.Total != 2 {
+			t.Errorf("Expected 2 users at example.com, got %+v", page.Items)
+		}
+
+		filter := UserFilter{CreatedAfter: users[1].CreatedAt, CreatedBefore: users[2].CreatedAt}
+		page, err = repo.List(ctx, filter, PageRequest{})
+		if err != nil {
+			t.Fatalf("Failed to list users: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Username != "user2" {
+			t.Errorf("Expected only user2 in the created range, got %+v", page.Items)
+		}
+
+		page, err = repo.List(ctx, UserFilter{EmailDomain: "%"}, PageRequest{})
+		if err != nil {
+			t.Fatalf("Failed to list users: %v", err)
+		}
+		if len(page.Items) != 0 {
+			t.Errorf("Expected wildcards in the domain to be literal, got %+v", page.Items)
+		}
+	})
 
-		if len(retrieved) != 0 {
-			t.Errorf("Expected 0 users, got %d", len(retrieved))
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := map[string]PageRequest{
+			"unknown sort":     {Sort: "password"},
+			"malformed cursor": {Cursor: "not a cursor"},
+			"negative limit":   {Limit: -1},
+		}
+		for name, req := range requests {
+			if _, err := repo.List(ctx, UserFilter{}, req); !errors.Is(err, database.ErrInvalid) {
+				t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+			}
+		}
+
+		page, err := repo.List(ctx, UserFilter{}, PageRequest{Limit: 1, Sort: "email"})
+		if err != nil {
+			t.Fatalf("Failed to list users: %v", err)
+		}
+		_, err = repo.List(ctx, UserFilter{}, PageRequest{Sort: "username", Cursor: page.NextCursor})
+		if !errors.Is(err, database.ErrInvalid) {
+			t.Errorf("Expected a cursor for another sort to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		pagination := UserPagination
+		pagination.MaxLimit = 2
+		page, err := repo.repo.Paginate(ctx, nil, pagination, PageRequest{Limit: 50})
+		if err != nil {
+			t.Fatalf("Failed to paginate users: %v", err)
+		}
+		if len(page.Items) != 2 || page.NextCursor == "" {
+			t.Errorf("Expected 2 users and a cursor, got %d %q", len(page.Items), page.NextCursor)
 		}
 	})
 }
@@ -461,6 +698,118 @@ func TestCountUsers(t *testing.T) {
 	})
 }
 
+func TestUserRepositoryWithTx(t *testing.T) {
+	db, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("commits with the caller's transaction", func(t *testing.T) {
+		err := db.WithTx(ctx, nil, func(tx *database.Tx) error {
+			txRepo := repo.WithTx(tx)
+			if err := txRepo.Create(ctx, &User{Username: "txuser", Email: "txuser@example.com"}); err != nil {
+				return err
+			}
+
+			// The new row is visible inside the transaction
+			_, err := txRepo.GetByUsername(ctx, "txuser")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Transaction failed: %v", err)
+		}
+
+		if _, err := repo.GetByUsername(ctx, "txuser"); err != nil {
+			t.Errorf("Expected committed user to exist: %v", err)
+		}
+	})
+
+	t.Run("rolls back with the caller's transaction", func(t *testing.T) {
+		err := db.WithTx(ctx, nil, func(tx *database.Tx) error {
+			if err := repo.WithTx(tx).Create(ctx, &User{Username: "rolledback", Email: "rolledback@example.com"}); err != nil {
+				return err
+			}
+			return fmt.Errorf("abort")
+		})
+		if err == nil {
+			t.Fatal("Expected transaction error")
+		}
+
+		if _, err := repo.GetByUsername(ctx, "rolledback"); err == nil {
+			t.Error("Expected user to be rolled back")
+		}
+	})
+}
+
+func TestUserEvents(t *testing.T) {
+	db, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	events := outbox.NewStore(db)
+
+	topics := func() []string {
+		t.Helper()
+		pending, err := events.List(ctx, outbox.StatusPending, 100)
+		if err != nil {
+			t.Fatalf("Failed to list events: %v", err)
+		}
+		var topics []string
+		for i := len(pending) - 1; i >= 0; i-- {
+			topics = append(topics, pending[i].Topic)
+		}
+		return topics
+	}
+
+	user := &User{Username: "evented", Email: "evented@example.com"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	user.Name = "Evented"
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	want := []string{UserCreatedEvent, UserUpdatedEvent, UserDeletedEvent}
+	if got := topics(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+
+	t.Run("failed writes enqueue nothing", func(t *testing.T) {
+		stale := &User{Username: "stale", Email: "stale@example.com"}
+		repo.Create(ctx, stale)
+		before := len(topics())
+
+		stale.Version = 99
+		if err := repo.Update(ctx, stale); !errors.Is(err, database.ErrVersionConflict) {
+			t.Fatalf("Expected a version conflict, got %v", err)
+		}
+		if stale.Version != 99 {
+			t.Errorf("Expected a failed update to leave the user unchanged, got version %d", stale.Version)
+		}
+		if err := repo.Create(ctx, &User{Username: "stale", Email: "other@example.com"}); err == nil {
+			t.Fatal("Expected a duplicate username to fail")
+		}
+		if got := len(topics()); got != before {
+			t.Errorf("Expected no new events, got %d", got-before)
+		}
+	})
+
+	t.Run("events roll back with the caller's transaction", func(t *testing.T) {
+		before := len(topics())
+		db.WithTx(ctx, nil, func(tx *database.Tx) error {
+			repo.WithTx(tx).Create(ctx, &User{Username: "ghost", Email: "ghost@example.com"})
+			return fmt.Errorf("abort")
+		})
+		if got := len(topics()); got != before {
+			t.Errorf("Expected the event to be rolled back, got %d new", got-before)
+		}
+	})
+}
+
 func TestConcurrentCRUD(t *testing.T) {
 	_, repo, cleanup := setupTestDBWithUsers(t)
 	defer cleanup()
@@ -518,13 +867,13 @@ func TestLargeQuery(t *testing.T) {
 	}
 
 	t.Run("list many users", func(t *testing.T) {
-		retrieved, err := repo.List(ctx, numUsers, 0)
+		page, err := repo.List(ctx, UserFilter{}, PageRequest{Limit: numUsers})
 		if err != nil {
 			t.Fatalf("Failed to list users: %v", err)
 		}
 
-		if len(retrieved) != numUsers {
-			t.Errorf("Expected %d users, got %d", numUsers, len(retrieved))
+		if len(page.Items) != numUsers {
+			t.Errorf("Expected %d users, got %d", numUsers, len(page.Items))
 		}
 	})
 
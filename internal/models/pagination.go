@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// Pagination describes how a list endpoint may be paged and sorted
+type Pagination struct {
+	// Sorts maps the sort names accepted from clients to columns; only these
+	// can be sorted on. Sort columns should be NOT NULL.
+	Sorts map[string]string
+	// DefaultSort is used when the request has none, e.g. "-created_at"
+	DefaultSort  string
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// PageRequest selects one page of a list
+type PageRequest struct {
+	// Limit is the page size; zero uses the default and larger values are
+	// capped at the maximum
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first
+	Cursor string
+	// Sort is a sort name, prefixed with "-" for descending order
+	Sort string
+	// IncludeTotal also counts every row matching the filters
+	IncludeTotal bool
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// Number is the 1-based position of the page, counted along its cursors
+	Number     int    `json:"page"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// cursor is the decoded form of an opaque page cursor: the sort it belongs
+// to, the number of the page it leads to and the sort column and primary key
+// values of the last row returned
+type cursor struct {
+	Sort   string            `json:"s"`
+	Page   int               `json:"p,omitempty"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// pageNumber returns the number of the page c leads to
+func (c *cursor) pageNumber() int {
+	if c == nil || c.Page < 1 {
+		return 1
+	}
+	return c.Page
+}
+
+// encodeCursor returns the opaque cursor for page number page, holding the
+// values of the last row before it
+func encodeCursor(sort string, page int, values ...interface{}) (string, error) {
+	c := cursor{Sort: sort, Page: page}
+	for _, v := range values {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode cursor: %w", err)
+		}
+		c.Values = append(c.Values, raw)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses an opaque cursor
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", database.ErrInvalid)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", database.ErrInvalid)
+	}
+	return &c, nil
+}
+
+// Paginate returns a page of the models matched by q, which should come from
+// Query and carry only filters; ordering and limits are set from req. Pages
+// are read with keyset conditions on the sort column and the primary key, so
+// deep pages stay cheap and concurrent inserts never shift rows between pages.
+// A nil q pages through every row.
+func (r *Repository[T]) Paginate(ctx context.Context, q *database.SelectBuilder, p Pagination, req PageRequest) (*Page[T], error) {
+	if q == nil {
+		q = r.Query()
+	}
+
+	sort := req.Sort
+	if sort == "" {
+		sort = p.DefaultSort
+	}
+	descending := strings.HasPrefix(sort, "-")
+	columnName, ok := p.Sorts[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort by %q", database.ErrInvalid, strings.TrimPrefix(sort, "-"))
+	}
+	sortColumn := r.meta.column(columnName)
+	if sortColumn == nil {
+		return nil, fmt.Errorf("%w: %s has no column %q", database.ErrInvalid, r.meta.table, columnName)
+	}
+	pk := r.meta.pk
+	keys := []*column{sortColumn, pk}
+	if sortColumn == pk {
+		keys = keys[:1]
+	}
+
+	limit := req.Limit
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: limit must not be negative", database.ErrInvalid)
+	}
+	if limit == 0 {
+		limit = p.DefaultLimit
+	}
+	if p.MaxLimit > 0 && limit > p.MaxLimit {
+		limit = p.MaxLimit
+	}
+
+	var after *cursor
+	if req.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(req.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	page := &Page[T]{Items: []T{}, Number: after.pageNumber(), Limit: limit}
+	if req.IncludeTotal {
+		total, err := r.Count(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	direction, op := "ASC", ">"
+	if descending {
+		direction, op = "DESC", "<"
+	}
+	q = q.Clone()
+	for _, c := range keys {
+		q.OrderBy(c.name + " " + direction)
+	}
+
+	if after != nil {
+		cond, err := r.keysetCondition(after, sort, keys, op)
+		if err != nil {
+			return nil, err
+		}
+		q.WhereExpr(cond)
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	items, err := r.List(ctx, q.Limit(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > limit {
+		items = items[:limit]
+		last := reflect.ValueOf(&items[limit-1]).Elem()
+		values := make([]interface{}, len(keys))
+		for i, c := range keys {
+			values[i] = last.FieldByIndex(c.index).Interface()
+		}
+		if page.NextCursor, err = encodeCursor(sort, page.Number+1, values...); err != nil {
+			return nil, err
+		}
+	}
+	if items != nil {
+		page.Items = items
+	}
+	return page, nil
+}
+
+// keysetCondition returns the condition selecting the rows after cursor c
+// for sort, e.g. "(created_at, id) < (?, ?)"
+func (r *Repository[T]) keysetCondition(c *cursor, sort string, keys []*column, op string) (database.Expr, error) {
+	if c.Sort != sort || len(c.Values) != len(keys) {
+		return database.Expr{}, fmt.Errorf("%w: cursor does not match sort %q", database.ErrInvalid, sort)
+	}
+
+	// Decode each value into its field's type so it binds exactly like the
+	// stored value, e.g. a time.Time keeps its zone offset
+	modelType := reflect.TypeOf((*T)(nil)).Elem()
+	names := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		value := reflect.New(modelType.FieldByIndex(key.index).Type)
+		if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+			return database.Expr{}, fmt.Errorf("%w: malformed cursor", database.ErrInvalid)
+		}
+		names[i] = key.name
+		args[i] = value.Elem().Interface()
+	}
+
+	if len(keys) == 1 {
+		return database.Raw(names[0]+" "+op+" ?", args...), nil
+	}
+	return database.Raw("("+strings.Join(names, ", ")+") "+op+" (?, ?)", args...), nil
+}
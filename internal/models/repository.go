@@ -0,0 +1,556 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// Model is implemented by structs stored with Repository. Columns are mapped
+// with `db` struct tags: `db:"column"` followed by optional comma-separated
+// flags:
+//
+//	pk       the primary key (exactly one field)
+//	auto     generated by the database; omitted on insert and read back with
+//	         LastInsertId, or RETURNING where the dialect lacks it
+//	created  set to the current time on insert
+//	updated  set to the current time on insert and update
+//	creator  set to the context's principal on insert (a string)
+//	updater  set to the context's principal on insert, update and soft delete (a string)
+//	deleted  soft-delete timestamp (a *time.Time): Delete sets it instead of
+//	         removing the row, and queries skip rows where it is set
+//	version  optimistic lock (an integer): starts at 1 and is incremented by
+//	         every write; Update fails with ErrVersionConflict if the model's
+//	         version is no longer the stored one
+//
+// Fields without a db tag, or tagged `db:"-"`, are ignored.
+type Model interface {
+	TableName() string
+}
+
+// column describes a mapped struct field
+type column struct {
+	name    string
+	index   []int
+	pk      bool
+	auto    bool
+	created bool
+	updated bool
+	creator bool
+	updater bool
+	deleted bool
+	version bool
+}
+
+// tableMeta describes how a model type maps to its table
+type tableMeta struct {
+	table   string
+	columns []column
+	pk      *column
+	deleted *column
+	version *column
+}
+
+// columnNames returns the names of all mapped columns
+func (m *tableMeta) columnNames() []string {
+	names := make([]string, len(m.columns))
+	for i, c := range m.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// hasColumn reports whether name is a mapped column
+func (m *tableMeta) hasColumn(name string) bool {
+	return m.column(name) != nil
+}
+
+// column returns the mapped column called name, or nil
+func (m *tableMeta) column(name string) *column {
+	for i := range m.columns {
+		if m.columns[i].name == name {
+			return &m.columns[i]
+		}
+	}
+	return nil
+}
+
+var metaCache sync.Map // reflect.Type -> *tableMeta
+
+// metaFor parses and caches the column mapping of model type T
+func metaFor[T Model]() *tableMeta {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if cached, ok := metaCache.Load(typ); ok {
+		return cached.(*tableMeta)
+	}
+
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("models: %s is not a struct", typ))
+	}
+
+	meta := &tableMeta{table: zero.TableName()}
+	for _, field := range reflect.VisibleFields(typ) {
+		tag, ok := field.Tag.Lookup("db")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		c := column{name: parts[0], index: field.Index}
+		for _, flag := range parts[1:] {
+			switch flag {
+			case "pk":
+				c.pk = true
+			case "auto":
+				c.auto = true
+			case "created":
+				c.created = true
+			case "updated":
+				c.updated = true
+			case "creator":
+				c.creator = true
+			case "updater":
+				c.updater = true
+			case "deleted":
+				c.deleted = true
+			case "version":
+				c.version = true
+			default:
+				panic(fmt.Sprintf("models: unknown db tag flag %q on %s.%s", flag, typ, field.Name))
+			}
+		}
+		if (c.created || c.updated) && field.Type != reflect.TypeOf(time.Time{}) {
+			panic(fmt.Sprintf("models: %s.%s must be a time.Time to be a created or updated timestamp", typ, field.Name))
+		}
+		if (c.creator || c.updater) && field.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("models: %s.%s must be a string to hold a principal", typ, field.Name))
+		}
+		if c.deleted && field.Type != reflect.TypeOf(&time.Time{}) {
+			panic(fmt.Sprintf("models: %s.%s must be a *time.Time to be a deleted timestamp", typ, field.Name))
+		}
+		if c.version && !isInteger(field.Type) {
+			panic(fmt.Sprintf("models: %s.%s must be an integer to be a version", typ, field.Name))
+		}
+		meta.columns = append(meta.columns, c)
+	}
+
+	for i := range meta.columns {
+		if meta.columns[i].pk {
+			if meta.pk != nil {
+				panic(fmt.Sprintf("models: %s has more than one primary key", typ))
+			}
+			meta.pk = &meta.columns[i]
+		}
+		if meta.columns[i].deleted {
+			if meta.deleted != nil {
+				panic(fmt.Sprintf("models: %s has more than one deleted timestamp", typ))
+			}
+			meta.deleted = &meta.columns[i]
+		}
+		if meta.columns[i].version {
+			if meta.version != nil {
+				panic(fmt.Sprintf("models: %s has more than one version", typ))
+			}
+			meta.version = &meta.columns[i]
+		}
+	}
+	if meta.pk == nil {
+		panic(fmt.Sprintf("models: %s has no primary key field", typ))
+	}
+
+	actual, _ := metaCache.LoadOrStore(typ, meta)
+	return actual.(*tableMeta)
+}
+
+// isInteger reports whether t is a signed or unsigned integer type
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// intValue returns the value of an integer field
+func intValue(field reflect.Value) int64 {
+	if field.CanInt() {
+		return field.Int()
+	}
+	return int64(field.Uint())
+}
+
+// setInt sets an integer field
+func setInt(field reflect.Value, n int64) {
+	if field.CanInt() {
+		field.SetInt(n)
+	} else {
+		field.SetUint(uint64(n))
+	}
+}
+
+// Repository provides CRUD operations for a model type using its db struct
+// tags. Models with a deleted column are soft-deleted, and soft-deleted rows
+// are hidden from every query unless the repository is Unscoped.
+type Repository[T Model] struct {
+	db       database.Querier
+	meta     *tableMeta
+	unscoped bool
+}
+
+// NewRepository creates a repository for model type T. It panics if T's tags
+// are invalid, which is a programming error.
+func NewRepository[T Model](db database.Querier) *Repository[T] {
+	return &Repository[T]{db: db, meta: metaFor[T]()}
+}
+
+// WithTx returns a copy of the repository that runs its queries in tx
+func (r *Repository[T]) WithTx(tx *database.Tx) *Repository[T] {
+	return &Repository[T]{db: tx, meta: r.meta, unscoped: r.unscoped}
+}
+
+// Unscoped returns a copy of the repository whose queries include
+// soft-deleted rows
+func (r *Repository[T]) Unscoped() *Repository[T] {
+	return &Repository[T]{db: r.db, meta: r.meta, unscoped: true}
+}
+
+// scope returns the condition hiding soft-deleted rows, or an empty
+// condition if the model isn't soft-deleted or the repository is Unscoped
+func (r *Repository[T]) scope() database.Expr {
+	if r.meta.deleted == nil || r.unscoped {
+		return database.Expr{}
+	}
+	return database.Raw(r.meta.deleted.name + " IS NULL")
+}
+
+// principal returns the authenticated principal in ctx, or "" if none
+func principal(ctx context.Context) string {
+	p, _ := auth.PrincipalFromContext(ctx)
+	return p
+}
+
+// Table returns the table name
+func (r *Repository[T]) Table() string {
+	return r.meta.table
+}
+
+// Query returns a builder selecting all mapped columns of the table, to be
+// refined and passed to List, First or Count
+func (r *Repository[T]) Query() *database.SelectBuilder {
+	return database.Select(r.meta.columnNames()...).From(r.meta.table).WhereExpr(r.scope())
+}
+
+// Create inserts model and sets its auto-generated primary key
+func (r *Repository[T]) Create(ctx context.Context, model *T) error {
+	v := reflect.ValueOf(model).Elem()
+	now := time.Now()
+	by := principal(ctx)
+
+	insert := database.Insert(r.meta.table)
+	for _, c := range r.meta.columns {
+		if c.auto {
+			continue
+		}
+		field := v.FieldByIndex(c.index)
+		if c.created || c.updated {
+			field.Set(reflect.ValueOf(now))
+		}
+		if c.creator || c.updater {
+			field.SetString(by)
+		}
+		if c.version {
+			setInt(field, 1)
+		}
+		insert.Set(c.name, field.Interface())
+	}
+
+	if r.meta.pk.auto && !r.db.Dialect().SupportsLastInsertID() {
+		// QueryRow may write here: dialects without LastInsertId read and
+		// write through a single pool
+		query, args := insert.Returning(r.meta.pk.name).Build()
+		var id int64
+		if err := r.db.QueryRow(ctx, query, args...).Scan(&id); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", r.meta.table, database.MapError(err))
+		}
+		setInt(v.FieldByIndex(r.meta.pk.index), id)
+		return nil
+	}
+
+	query, args := insert.Build()
+	result, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to insert into %s: %w", r.meta.table, database.MapError(err))
+	}
+
+	if r.meta.pk.auto {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+		setInt(v.FieldByIndex(r.meta.pk.index), id)
+	}
+	return nil
+}
+
+// Insert writes model exactly as given, including its primary key,
+// timestamps, audit columns and version, e.g. to copy rows from another
+// database. Use Create for new models.
+func (r *Repository[T]) Insert(ctx context.Context, model *T) error {
+	query, args := r.insertAll(model).Build()
+	if _, err := r.db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert into %s: %w", r.meta.table, database.MapError(err))
+	}
+	return nil
+}
+
+// Upsert writes model exactly as given like Insert, overwriting the row with
+// the same primary key if there is one
+func (r *Repository[T]) Upsert(ctx context.Context, model *T) error {
+	var columns []string
+	for _, c := range r.meta.columns {
+		if !c.pk {
+			columns = append(columns, c.name)
+		}
+	}
+	query, args := r.insertAll(model).OnConflictUpdate([]string{r.meta.pk.name}, columns...).Build()
+	if _, err := r.db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to upsert into %s: %w", r.meta.table, database.MapError(err))
+	}
+	return nil
+}
+
+// insertAll returns an insert of every mapped column of model
+func (r *Repository[T]) insertAll(model *T) *database.InsertBuilder {
+	v := reflect.ValueOf(model).Elem()
+	insert := database.Insert(r.meta.table)
+	for _, c := range r.meta.columns {
+		insert.Set(c.name, v.FieldByIndex(c.index).Interface())
+	}
+	return insert
+}
+
+// PrimaryKey returns the name of the primary key column
+func (r *Repository[T]) PrimaryKey() string {
+	return r.meta.pk.name
+}
+
+// AutoKey reports whether the database generates the primary key
+func (r *Repository[T]) AutoKey() bool {
+	return r.meta.pk.auto
+}
+
+// ID returns the primary key of model
+func (r *Repository[T]) ID(model *T) interface{} {
+	return reflect.ValueOf(model).Elem().FieldByIndex(r.meta.pk.index).Interface()
+}
+
+// Get returns the model with the given primary key
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	model, err := r.First(ctx, r.Query().WhereExpr(database.Eq(r.meta.pk.name, id)))
+	if err != nil {
+		return nil, fmt.Errorf("%s %v: %w", r.meta.table, id, err)
+	}
+	return model, nil
+}
+
+// GetBy returns the first model whose column equals value
+func (r *Repository[T]) GetBy(ctx context.Context, column string, value interface{}) (*T, error) {
+	if !r.meta.hasColumn(column) {
+		return nil, fmt.Errorf("%w: %s has no column %q", database.ErrInvalid, r.meta.table, column)
+	}
+	model, err := r.First(ctx, r.Query().WhereExpr(database.Eq(column, value)))
+	if err != nil {
+		return nil, fmt.Errorf("%s with %s %v: %w", r.meta.table, column, value, err)
+	}
+	return model, nil
+}
+
+// Update writes all columns of model except the primary key, created
+// timestamps, creator and deleted timestamp, and sets its updated timestamps
+// and updater
+func (r *Repository[T]) Update(ctx context.Context, model *T) error {
+	v := reflect.ValueOf(model).Elem()
+	now := time.Now()
+	by := principal(ctx)
+
+	update := database.Update(r.meta.table)
+	for _, c := range r.meta.columns {
+		if c.pk || c.auto || c.created || c.creator || c.deleted || c.version {
+			continue
+		}
+		field := v.FieldByIndex(c.index)
+		if c.updated {
+			field.Set(reflect.ValueOf(now))
+		}
+		if c.updater {
+			field.SetString(by)
+		}
+		update.Set(c.name, field.Interface())
+	}
+
+	id := v.FieldByIndex(r.meta.pk.index).Interface()
+	update.WhereExpr(database.Eq(r.meta.pk.name, id)).WhereExpr(r.scope())
+	if r.meta.version == nil {
+		query, args := update.Build()
+		return r.execAffectingOne(ctx, "update", id, query, args)
+	}
+
+	versionField := v.FieldByIndex(r.meta.version.index)
+	version := intValue(versionField)
+	query, args := update.
+		SetExpr(r.meta.version.name, database.Raw(r.meta.version.name+" + 1")).
+		WhereExpr(database.Eq(r.meta.version.name, version)).
+		Build()
+	err := r.execAffectingOne(ctx, "update", id, query, args)
+	if errors.Is(err, database.ErrNotFound) {
+		// Tell a stale version apart from a missing row
+		if _, getErr := r.Get(ctx, id); getErr == nil {
+			return fmt.Errorf("%s %v at version %d: %w", r.meta.table, id, version, database.ErrVersionConflict)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	setInt(versionField, version+1)
+	return nil
+}
+
+// Delete soft-deletes the model with the given primary key if it has a
+// deleted column, and removes it otherwise
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	if r.meta.deleted == nil {
+		return r.Purge(ctx, id)
+	}
+	query, args := r.touch(ctx, database.Update(r.meta.table).Set(r.meta.deleted.name, time.Now())).
+		WhereExpr(database.Eq(r.meta.pk.name, id)).
+		Where(r.meta.deleted.name + " IS NULL").
+		Build()
+	return r.execAffectingOne(ctx, "soft delete", id, query, args)
+}
+
+// Restore undoes the soft delete of the model with the given primary key,
+// returning ErrNotFound if it isn't soft-deleted
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	if r.meta.deleted == nil {
+		return fmt.Errorf("%w: %s is not soft-deleted", database.ErrInvalid, r.meta.table)
+	}
+	query, args := r.touch(ctx, database.Update(r.meta.table).Set(r.meta.deleted.name, nil)).
+		WhereExpr(database.Eq(r.meta.pk.name, id)).
+		Where(r.meta.deleted.name + " IS NOT NULL").
+		Build()
+	return r.execAffectingOne(ctx, "restore", id, query, args)
+}
+
+// Purge permanently removes the model with the given primary key, whether or
+// not it is soft-deleted
+func (r *Repository[T]) Purge(ctx context.Context, id interface{}) error {
+	query, args := database.Delete(r.meta.table).WhereExpr(database.Eq(r.meta.pk.name, id)).Build()
+	return r.execAffectingOne(ctx, "delete from", id, query, args)
+}
+
+// touch adds the updated timestamps, updater and version increment to an update
+func (r *Repository[T]) touch(ctx context.Context, update *database.UpdateBuilder) *database.UpdateBuilder {
+	now := time.Now()
+	for _, c := range r.meta.columns {
+		if c.updated {
+			update.Set(c.name, now)
+		}
+		if c.updater {
+			update.Set(c.name, principal(ctx))
+		}
+		if c.version {
+			update.SetExpr(c.name, database.Raw(c.name+" + 1"))
+		}
+	}
+	return update
+}
+
+// execAffectingOne runs a statement that must affect the row with the given
+// primary key, returning ErrNotFound if it affected none
+func (r *Repository[T]) execAffectingOne(ctx context.Context, action string, id interface{}, query string, args []interface{}) error {
+	result, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, r.meta.table, database.MapError(err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s %v: %w", r.meta.table, id, database.ErrNotFound)
+	}
+	return nil
+}
+
+// List returns the models matched by q, which should come from Query; a nil q
+// returns every row
+func (r *Repository[T]) List(ctx context.Context, q *database.SelectBuilder) ([]T, error) {
+	if q == nil {
+		q = r.Query()
+	}
+	query, args := q.Build()
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", r.meta.table, err)
+	}
+	defer rows.Close()
+
+	var models []T
+	for rows.Next() {
+		var model T
+		if err := rows.Scan(r.scanTargets(&model)...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", r.meta.table, err)
+		}
+		models = append(models, model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", r.meta.table, err)
+	}
+	return models, nil
+}
+
+// First returns the first model matched by q, or ErrNotFound
+func (r *Repository[T]) First(ctx context.Context, q *database.SelectBuilder) (*T, error) {
+	query, args := q.Clone().Limit(1).Build()
+
+	var model T
+	if err := r.db.QueryRow(ctx, query, args...).Scan(r.scanTargets(&model)...); err != nil {
+		return nil, database.MapError(err)
+	}
+	return &model, nil
+}
+
+// Count returns the number of rows matched by q; a nil q counts every row
+func (r *Repository[T]) Count(ctx context.Context, q *database.SelectBuilder) (int, error) {
+	if q == nil {
+		q = r.Query()
+	}
+	query, args := q.Count().Build()
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", r.meta.table, err)
+	}
+	return count, nil
+}
+
+// scanTargets returns pointers to model's mapped fields in column order
+func (r *Repository[T]) scanTargets(model *T) []interface{} {
+	v := reflect.ValueOf(model).Elem()
+	targets := make([]interface{}, len(r.meta.columns))
+	for i, c := range r.meta.columns {
+		targets[i] = v.FieldByIndex(c.index).Addr().Interface()
+	}
+	return targets
+}
@@ -0,0 +1,314 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/encryption"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// widget is a model with a text primary key and an embedded timestamp struct
+type widget struct {
+	Code  string `db:"code,pk"`
+	Name  string `db:"name"`
+	Price int    `db:"price"`
+	Notes string // not mapped
+	timestamps
+}
+
+type timestamps struct {
+	CreatedAt time.Time `db:"created_at,created"`
+	UpdatedAt time.Time `db:"updated_at,updated"`
+}
+
+func (widget) TableName() string {
+	return "widgets"
+}
+
+func setupWidgetRepository(t *testing.T) *Repository[widget] {
+	t.Helper()
+
+	db := seedtest.NewDatabase(t, "", "")
+	_, err := db.Exec(context.Background(), `
+		CREATE TABLE widgets (
+			code TEXT PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			price INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create widgets table: %v", err)
+	}
+
+	return NewRepository[widget](db)
+}
+
+func TestRepository(t *testing.T) {
+	repo := setupWidgetRepository(t)
+	ctx := context.Background()
+
+	w := &widget{Code: "w-1", Name: "Sprocket", Price: 300, Notes: "ignored"}
+	if err := repo.Create(ctx, w); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if w.CreatedAt.IsZero() || !w.CreatedAt.Equal(w.UpdatedAt) {
+		t.Errorf("Expected timestamps to be set on create, got %v / %v", w.CreatedAt, w.UpdatedAt)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		got, err := repo.Get(ctx, "w-1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Name != "Sprocket" || got.Price != 300 || got.Notes != "" {
+			t.Errorf("Unexpected widget %+v", got)
+		}
+		if !got.CreatedAt.Equal(w.CreatedAt) {
+			t.Errorf("Expected CreatedAt %v, got %v", w.CreatedAt, got.CreatedAt)
+		}
+	})
+
+	t.Run("get missing", func(t *testing.T) {
+		if _, err := repo.Get(ctx, "nope"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("get by column", func(t *testing.T) {
+		got, err := repo.GetBy(ctx, "name", "Sprocket")
+		if err != nil || got.Code != "w-1" {
+			t.Fatalf("GetBy failed: %v %+v", err, got)
+		}
+		if _, err := repo.GetBy(ctx, "name; DROP TABLE widgets", "x"); !errors.Is(err, database.ErrInvalid) {
+			t.Errorf("Expected unknown column to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("duplicate is a conflict", func(t *testing.T) {
+		err := repo.Create(ctx, &widget{Code: "w-2", Name: "Sprocket", Price: 1})
+		if !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		createdAt := w.CreatedAt
+		w.Price = 350
+		time.Sleep(time.Millisecond)
+		if err := repo.Update(ctx, w); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		got, _ := repo.Get(ctx, "w-1")
+		if got.Price != 350 {
+			t.Errorf("Expected price 350, got %d", got.Price)
+		}
+		if !got.CreatedAt.Equal(createdAt) || !got.UpdatedAt.After(createdAt) {
+			t.Errorf("Expected only UpdatedAt to change, got %v / %v", got.CreatedAt, got.UpdatedAt)
+		}
+
+		if err := repo.Update(ctx, &widget{Code: "nope"}); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("insert and upsert keep values as given", func(t *testing.T) {
+		stamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		copied := &widget{Code: "w-9", Name: "Copy", Price: 10, timestamps: timestamps{CreatedAt: stamp, UpdatedAt: stamp}}
+		if err := repo.Insert(ctx, copied); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if err := repo.Insert(ctx, copied); !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected a duplicate insert to conflict, got %v", err)
+		}
+
+		copied.Price = 20
+		if err := repo.Upsert(ctx, copied); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		got, err := repo.Get(ctx, "w-9")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Price != 20 || !got.CreatedAt.Equal(stamp) || !got.UpdatedAt.Equal(stamp) {
+			t.Errorf("Expected the widget as given, got %+v", got)
+		}
+		if repo.PrimaryKey() != "code" || repo.ID(got) != "w-9" {
+			t.Errorf("Unexpected primary key %s = %v", repo.PrimaryKey(), repo.ID(got))
+		}
+
+		if err := repo.Purge(ctx, "w-9"); err != nil {
+			t.Fatalf("Purge failed: %v", err)
+		}
+	})
+
+	t.Run("list and count with the query builder", func(t *testing.T) {
+		for _, extra := range []widget{{Code: "w-3", Name: "Gear", Price: 100}, {Code: "w-4", Name: "Cog", Price: 200}} {
+			if err := repo.Create(ctx, &extra); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+		}
+
+		cheap := repo.Query().WhereExpr(database.Lt("price", 300)).OrderBy("price")
+		widgets, err := repo.List(ctx, cheap)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(widgets) != 2 || widgets[0].Name != "Gear" || widgets[1].Name != "Cog" {
+			t.Errorf("Unexpected widgets %+v", widgets)
+		}
+
+		if count, err := repo.Count(ctx, cheap); err != nil || count != 2 {
+			t.Errorf("Expected 2 cheap widgets, got %d (%v)", count, err)
+		}
+		if count, err := repo.Count(ctx, nil); err != nil || count != 3 {
+			t.Errorf("Expected 3 widgets, got %d (%v)", count, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := repo.Delete(ctx, "w-1"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := repo.Delete(ctx, "w-1"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if _, err := repo.Unscoped().Get(ctx, "w-1"); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected a model without a deleted column to be removed, got %v", err)
+		}
+		if err := repo.Restore(ctx, "w-1"); !errors.Is(err, database.ErrInvalid) {
+			t.Errorf("Expected restore without a deleted column to be invalid, got %v", err)
+		}
+	})
+}
+
+// credential is a model with an encrypted field
+type credential struct {
+	ID     uint                       `db:"id,pk,auto"`
+	Name   string                     `db:"name"`
+	Secret encryption.EncryptedString `db:"secret"`
+}
+
+func (credential) TableName() string {
+	return "credentials"
+}
+
+// newTestKeyring returns a keyring holding a single key, primary
+func newTestKeyring(t *testing.T, id string, fill byte) *encryption.Keyring {
+	t.Helper()
+	k, err := encryption.NewKeyring(id, map[string][]byte{id: bytes.Repeat([]byte{fill}, encryption.KeySize)})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	return k
+}
+
+func TestRepositoryEncryptedField(t *testing.T) {
+	ctx := context.Background()
+	db := seedtest.NewDatabase(t, "", "")
+	_, err := db.Exec(ctx, `
+		CREATE TABLE credentials (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			secret TEXT NOT NULL DEFAULT ''
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create credentials table: %v", err)
+	}
+	// A row written before the column was encrypted
+	if _, err := db.Exec(ctx, "INSERT INTO credentials (name, secret) VALUES (?, ?)", "legacy", "plain-secret"); err != nil {
+		t.Fatalf("Failed to insert plaintext row: %v", err)
+	}
+
+	previous := encryption.CurrentKeyring()
+	t.Cleanup(func() { encryption.SetKeyring(previous) })
+	encryption.SetKeyring(newTestKeyring(t, "k1", 1))
+
+	repo := NewRepository[credential](db)
+	c := &credential{Name: "api", Secret: "s3cret"}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// stored returns the value of the secret column as written
+	stored := func(id uint) string {
+		t.Helper()
+		var value string
+		if err := db.QueryRow(ctx, "SELECT secret FROM credentials WHERE id = ?", id).Scan(&value); err != nil {
+			t.Fatalf("Failed to read stored value: %v", err)
+		}
+		return value
+	}
+
+	if value := stored(c.ID); !strings.HasPrefix(value, "enc:v1:k1:") || strings.Contains(value, "s3cret") {
+		t.Errorf("Expected the secret to be stored encrypted, got %q", value)
+	}
+	got, err := repo.Get(ctx, c.ID)
+	if err != nil || got.Secret != "s3cret" {
+		t.Fatalf("Expected the secret to be read back as plaintext, got %+v, %v", got, err)
+	}
+	legacy, err := repo.Get(ctx, uint(1))
+	if err != nil || legacy.Secret != "plain-secret" {
+		t.Fatalf("Expected the plaintext row to be readable, got %+v, %v", legacy, err)
+	}
+
+	// Rotating to a new key seals the plaintext row and re-encrypts the other
+	k2 := newTestKeyring(t, "k2", 2)
+	keys, err := encryption.NewKeyring("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, encryption.KeySize),
+		"k2": bytes.Repeat([]byte{2}, encryption.KeySize),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	encryption.SetKeyring(keys)
+	column := encryption.Column{Table: "credentials", Key: "id", Column: "secret"}
+	result, err := encryption.Rotate(ctx, db, keys, column, 10, nil)
+	if err != nil || result.Rotated != 2 {
+		t.Fatalf("Expected 2 values rotated, got %+v, %v", result, err)
+	}
+
+	// The old key is no longer needed once every value has been rotated
+	encryption.SetKeyring(k2)
+	for id, want := range map[uint]encryption.EncryptedString{legacy.ID: "plain-secret", c.ID: "s3cret"} {
+		if value := stored(id); !strings.HasPrefix(value, "enc:v1:k2:") {
+			t.Errorf("Expected row %d to be sealed with the new key, got %q", id, value)
+		}
+		got, err := repo.Get(ctx, id)
+		if err != nil || got.Secret != want {
+			t.Errorf("Expected row %d to read back %q, got %+v, %v", id, want, got, err)
+		}
+	}
+
+	got.Secret = "rotated"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got, err := repo.Get(ctx, got.ID); err != nil || got.Secret != "rotated" {
+		t.Errorf("Expected the updated secret, got %+v, %v", got, err)
+	}
+}
+
+type noPrimaryKey struct {
+	Name string `db:"name"`
+}
+
+func (noPrimaryKey) TableName() string { return "things" }
+
+func TestRepositoryRejectsInvalidModels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a model without a primary key to panic")
+		}
+	}()
+	NewRepository[noPrimaryKey](nil)
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// Highlight markers used inside the database, replaced with <mark> tags after the
+// text around them has been HTML-escaped
+const (
+	highlightOpen  = "\x01"
+	highlightClose = "\x02"
+)
+
+// searchSort is the only sort accepted by Search: best match first
+const searchSort = "rank"
+
+// UserHighlights holds snippets of a matched user's fields with the matching
+// terms wrapped in <mark> tags; the rest of the text is HTML-escaped
+type UserHighlights struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+}
+
+// UserSearchResult is a user matched by Search
+type UserSearchResult struct {
+	User
+	// Score is the relevance (bm25 on SQLite, negated ts_rank on PostgreSQL);
+	// lower is a better match
+	Score      float64        `json:"score"`
+	Highlights UserHighlights `json:"highlights"`
+}
+
+// ftsQuery turns free text into an FTS5 query matching every term as a
+// prefix, quoting each term so user input can't use FTS5 query syntax
+func ftsQuery(text string) string {
+	terms := strings.Fields(text)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// tsQuery turns free text into a PostgreSQL tsquery matching every word as a
+// prefix. Like the indexed text, terms are split on punctuation, and only
+// letters and digits are kept so user input can't use tsquery syntax.
+func tsQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// searchQuery selects the users matching query with their score and
+// highlights, reporting false if query has no terms
+func (r *UserRepository) searchQuery(query string) (*database.SelectBuilder, bool) {
+	columns := make([]string, 0, len(r.repo.meta.columns)+4)
+	for _, name := range r.repo.meta.columnNames() {
+		columns = append(columns, "users."+name)
+	}
+
+	if r.repo.db.Dialect() == database.Postgres {
+		match := tsQuery(query)
+		if match == "" {
+			return nil, false
+		}
+		headline := func(column string) string {
+			return fmt.Sprintf("ts_headline('simple', users.%s, q, 'StartSel=%s, StopSel=%s, HighlightAll=true')", column, highlightOpen, highlightClose)
+		}
+		// Weights are listed D, C, B, A: email, name, username
+		columns = append(columns,
+			"-ts_rank('{0.1, 0.1, 0.5, 1.0}', users.search, q) AS score",
+			headline("username"), headline("email"), headline("name"),
+		)
+		return database.Select(columns...).From("users").
+			Join("to_tsquery('simple', ?) AS q", "users.search @@ q", match).
+			WhereExpr(r.repo.scope()), true
+	}
+
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, false
+	}
+	snippet := func(column int) string {
+		return fmt.Sprintf("snippet(users_fts, %d, '%s', '%s', '…', 16)", column, highlightOpen, highlightClose)
+	}
+	columns = append(columns,
+		"bm25(users_fts, 10.0, 1.0, 5.0) AS score",
+		snippet(0), snippet(1), snippet(2),
+	)
+	return database.Select(columns...).From("users_fts").
+		Join("users", "users.id = users_fts.rowid").
+		Where("users_fts MATCH ?", match).
+		WhereExpr(r.repo.scope()), true
+}
+
+// highlight escapes s for HTML and turns the highlight markers into <mark> tags
+func highlight(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightOpen, "<mark>")
+	return strings.ReplaceAll(s, highlightClose, "</mark>")
+}
+
+// Search returns the users whose username, email or name contain words
+// starting with every term of query, best matches first. Username matches
+// rank above name matches, which rank above email matches. page.Sort must be
+// empty or "rank".
+func (r *UserRepository) Search(ctx context.Context, query string, page PageRequest) (*Page[UserSearchResult], error) {
+	base, ok := r.searchQuery(query)
+	if !ok {
+		return nil, fmt.Errorf("%w: search query is empty", database.ErrInvalid)
+	}
+	if page.Sort != "" && page.Sort != searchSort {
+		return nil, fmt.Errorf("%w: search results can only be sorted by %s", database.ErrInvalid, searchSort)
+	}
+
+	limit := page.Limit
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: limit must not be negative", database.ErrInvalid)
+	}
+	if limit == 0 {
+		limit = UserPagination.DefaultLimit
+	}
+	if limit > UserPagination.MaxLimit {
+		limit = UserPagination.MaxLimit
+	}
+
+	// Relevance changes as users are written, so search pages by offset; the
+	// offset still travels in an opaque cursor like other listings
+	offset := 0
+	var after *cursor
+	if page.Cursor != "" {
+		var err error
+		if after, err = decodeCursor(page.Cursor); err != nil {
+			return nil, err
+		}
+		if after.Sort != searchSort || len(after.Values) != 1 || json.Unmarshal(after.Values[0], &offset) != nil || offset < 0 {
+			return nil, fmt.Errorf("%w: cursor does not match sort %q", database.ErrInvalid, searchSort)
+		}
+	}
+
+	result := &Page[UserSearchResult]{Items: []UserSearchResult{}, Number: after.pageNumber(), Limit: limit}
+	if page.IncludeTotal {
+		countQuery, args := base.Count().Build()
+		var total int
+		if err := r.repo.db.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("failed to count user search results: %w", err)
+		}
+		result.Total = &total
+	}
+
+	searchQuery, args := base.OrderBy("score", "users.id").Limit(limit + 1).Offset(offset).Build()
+
+	rows, err := r.repo.db.Query(ctx, searchQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item UserSearchResult
+		h := &item.Highlights
+		targets := append(r.repo.scanTargets(&item.User), &item.Score, &h.Username, &h.Email, &h.Name)
+		if err := rows.Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to scan user search result: %w", err)
+		}
+		h.Username, h.Email, h.Name = highlight(h.Username), highlight(h.Email), highlight(h.Name)
+		result.Items = append(result.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating user search results: %w", err)
+	}
+
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		if result.NextCursor, err = encodeCursor(searchSort, result.Number+1, offset+limit); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
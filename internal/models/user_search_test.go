@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+func TestSearchUsers(t *testing.T) {
+	_, repo, cleanup := setupTestDBWithUsers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	users := []User{
+		{Username: "ada", Email: "countess@example.com", Name: "Ada Lovelace"},
+		{Username: "grace", Email: "ada.fan@example.org", Name: "Grace Hopper"},
+		{Username: "alan", Email: "alan@example.com", Name: "Alan <Turing>"},
+	}
+	for i := range users {
+		if err := repo.Create(ctx, &users[i]); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	search := func(t *testing.T, query string, page PageRequest) *Page[UserSearchResult] {
+		t.Helper()
+		result, err := repo.Search(ctx, query, page)
+		if err != nil {
+			t.Fatalf("Search %q failed: %v", query, err)
+		}
+		return result
+	}
+	usernames := func(result *Page[UserSearchResult]) []string {
+		var names []string
+		for _, item := range result.Items {
+			names = append(names, item.Username)
+		}
+		return names
+	}
+
+	t.Run("ranks username matches above email matches", func(t *testing.T) {
+		result := search(t, "ada", PageRequest{})
+		if got := usernames(result); len(got) != 2 || got[0] != "ada" || got[1] != "grace" {
+			t.Errorf("Expected [ada grace], got %v", got)
+		}
+		if result.Items[0].Score >= result.Items[1].Score {
+			t.Errorf("Expected the first result to score better, got %v and %v", result.Items[0].Score, result.Items[1].Score)
+		}
+	})
+
+	t.Run("matches prefixes of every term", func(t *testing.T) {
+		if got := usernames(search(t, "lov ad", PageRequest{})); len(got) != 1 || got[0] != "ada" {
+			t.Errorf("Expected [ada], got %v", got)
+		}
+		if got := usernames(search(t, "hopper ada", PageRequest{})); len(got) != 1 || got[0] != "grace" {
+			t.Errorf("Expected [grace], got %v", got)
+		}
+	})
+
+	t.Run("highlights matches and escapes HTML", func(t *testing.T) {
+		result := search(t, "tur", PageRequest{})
+		if len(result.Items) != 1 {
+			t.Fatalf("Expected 1 result, got %d", len(result.Items))
+		}
+		h := result.Items[0].Highlights
+		if h.Name != "Alan &lt;<mark>Turing</mark>&gt;" {
+			t.Errorf("Unexpected name highlight %q", h.Name)
+		}
+		if h.Username != "alan" {
+			t.Errorf("Expected unmatched username unchanged, got %q", h.Username)
+		}
+	})
+
+	t.Run("query syntax is treated as text", func(t *testing.T) {
+		for _, query := range []string{`ada OR grace`, `"ada`, `ada*`, `-ada`, `NEAR(ada)`, `name:ada`} {
+			if _, err := repo.Search(ctx, query, PageRequest{}); err != nil {
+				t.Errorf("Search %q failed: %v", query, err)
+			}
+		}
+	})
+
+	t.Run("index follows updates and deletes", func(t *testing.T) {
+		users[2].Name = "Alan Kay"
+		if err := repo.Update(ctx, &users[2]); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+		if got := usernames(search(t, "turing", PageRequest{})); len(got) != 0 {
+			t.Errorf("Expected old name to be unindexed, got %v", got)
+		}
+		if got := usernames(search(t, "kay", PageRequest{})); len(got) != 1 {
+			t.Errorf("Expected new name to be indexed, got %v", got)
+		}
+
+		if err := repo.Delete(ctx, users[2].ID); err != nil {
+			t.Fatalf("Failed to delete user: %v", err)
+		}
+		if got := usernames(search(t, "kay", PageRequest{})); len(got) != 0 {
+			t.Errorf("Expected deleted user to be unindexed, got %v", got)
+		}
+	})
+
+	t.Run("pages with a cursor", func(t *testing.T) {
+		first := search(t, "example", PageRequest{Limit: 1, IncludeTotal: true})
+		if len(first.Items) != 1 || first.NextCursor == "" || first.Total == nil || *first.Total != 2 {
+			t.Fatalf("Unexpected first page %+v", first)
+		}
+		second := search(t, "example", PageRequest{Limit: 1, Cursor: first.NextCursor})
+		if len(second.Items) != 1 || second.NextCursor != "" || second.Items[0].ID == first.Items[0].ID {
+			t.Errorf("Unexpected second page %+v", second)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		requests := map[string]PageRequest{
+			"sort":   {Sort: "username"},
+			"cursor": {Cursor: "bad"},
+		}
+		for name, page := range requests {
+			if _, err := repo.Search(ctx, "ada", page); !errors.Is(err, database.ErrInvalid) {
+				t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+			}
+		}
+		if _, err := repo.Search(ctx, "   ", PageRequest{}); !errors.Is(err, database.ErrInvalid) {
+			t.Errorf("Expected an empty query to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestTSQuery(t *testing.T) {
+	tests := map[string]string{
+		"ada":                  "ada:*",
+		"Ada  Love":            "ada:* & love:*",
+		"ada@example.com":      "ada:* & example:* & com:*",
+		"x' | !y & (z) <-> w:": "x:* & y:* & z:* & w:*",
+		"  !!  ":               "",
+	}
+	for text, want := range tests {
+		if got := tsQuery(text); got != want {
+			t.Errorf("tsQuery(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
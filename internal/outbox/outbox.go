@@ -0,0 +1,193 @@
+// Package outbox implements a transactional outbox. Events are written to the
+// outbox table in the same transaction as the changes they describe, so they
+// are recorded if and only if those changes commit, and a Relay publishes them
+// to registered handlers afterwards.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// Status is the delivery state of an event
+type Status string
+
+const (
+	// StatusPending events are waiting for their first or next attempt
+	StatusPending Status = "pending"
+	// StatusDelivered events were accepted by every handler of their topic
+	StatusDelivered Status = "delivered"
+	// StatusDead events failed every attempt and are no longer retried
+	StatusDead Status = "dead"
+)
+
+// Event is a domain event stored in the outbox
+type Event struct {
+	ID            int64           `json:"id"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        Status          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// eventColumns are the outbox columns in the order scanEvent reads them
+const eventColumns = "id, topic, payload, status, attempts, last_error, next_attempt_at, created_at, delivered_at"
+
+// Enqueue writes an event with payload marshaled as JSON. Pass the
+// transaction that makes the change the event describes, so both commit or
+// roll back together.
+func Enqueue(ctx context.Context, q database.Querier, topic string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", topic, err)
+	}
+
+	now := time.Now()
+	query, args := database.Insert("outbox").
+		Set("topic", topic).
+		Set("payload", string(data)).
+		Set("status", string(StatusPending)).
+		Set("next_attempt_at", now).
+		Set("created_at", now).
+		Build()
+	if _, err := q.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to enqueue %s event: %w", topic, database.MapError(err))
+	}
+	return nil
+}
+
+// Stats counts the events in the outbox by state
+type Stats struct {
+	Pending int `json:"pending"`
+	// Retrying counts the pending events that have failed at least once
+	Retrying  int `json:"retrying"`
+	Delivered int `json:"delivered"`
+	Dead      int `json:"dead"`
+	// OldestPending is when the oldest pending event was enqueued
+	OldestPending *time.Time `json:"oldest_pending,omitempty"`
+}
+
+// Store reads and manages the events in the outbox
+type Store struct {
+	db database.Querier
+}
+
+// NewStore creates a store over db
+func NewStore(db database.Querier) *Store {
+	return &Store{db: db}
+}
+
+// Stats returns the number of events in each state
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
+	rows, err := s.db.Query(ctx,
+		"SELECT status, COUNT(*), COUNT(CASE WHEN attempts > 0 THEN 1 END) FROM outbox GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &Stats{}
+	for rows.Next() {
+		var status Status
+		var count, failed int
+		if err := rows.Scan(&status, &count, &failed); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox stats: %w", err)
+		}
+		switch status {
+		case StatusPending:
+			stats.Pending, stats.Retrying = count, failed
+		case StatusDelivered:
+			stats.Delivered = count
+		case StatusDead:
+			stats.Dead = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count outbox events: %w", err)
+	}
+
+	if stats.Pending > 0 {
+		query, args := database.Select(eventColumns).From("outbox").
+			WhereExpr(database.Eq("status", string(StatusPending))).
+			OrderBy("id").Limit(1).Build()
+		oldest, err := scanEvent(s.db.QueryRow(ctx, query, args...))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to read oldest pending event: %w", err)
+		}
+		if err == nil {
+			stats.OldestPending = &oldest.CreatedAt
+		}
+	}
+	return stats, nil
+}
+
+// List returns up to limit events with status, most recently created first
+func (s *Store) List(ctx context.Context, status Status, limit int) ([]Event, error) {
+	query, args := database.Select(eventColumns).From("outbox").
+		WhereExpr(database.Eq("status", string(status))).
+		OrderBy("id DESC").Limit(limit).Build()
+	return s.query(ctx, query, args)
+}
+
+// Retry requeues a dead event for immediate delivery with a fresh set of
+// attempts. It fails with database.ErrNotFound if no dead event has the id.
+func (s *Store) Retry(ctx context.Context, id int64) error {
+	query, args := database.Update("outbox").
+		Set("status", string(StatusPending)).
+		Set("attempts", 0).
+		Set("next_attempt_at", time.Now()).
+		WhereExpr(database.And(database.Eq("id", id), database.Eq("status", string(StatusDead)))).
+		Build()
+	result, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to retry outbox event %d: %w", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: no dead outbox event %d", database.ErrNotFound, id)
+	}
+	return nil
+}
+
+// query runs a select of eventColumns
+func (s *Store) query(ctx context.Context, query string, args []interface{}) ([]Event, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	return events, nil
+}
+
+// scanEvent scans a row of eventColumns
+func scanEvent(row interface{ Scan(...interface{}) error }) (*Event, error) {
+	var e Event
+	var payload string
+	if err := row.Scan(&e.ID, &e.Topic, &payload, &e.Status, &e.Attempts, &e.LastError,
+		&e.NextAttemptAt, &e.CreatedAt, &e.DeliveredAt); err != nil {
+		return nil, err
+	}
+	e.Payload = json.RawMessage(payload)
+	return &e, nil
+}
@@ -0,0 +1,341 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// setupTestDB returns a migrated database with an empty outbox
+func setupTestDB(t *testing.T) *database.Database {
+	t.Helper()
+	return seedtest.NewDatabase(t, "../../migrations", "")
+}
+
+// newTestRelay returns a relay that retries immediately
+func newTestRelay(db *database.Database, maxAttempts int) *Relay {
+	r := NewRelay(db, time.Hour, 100, maxAttempts, 0)
+	r.backoff = func(int) time.Duration { return 0 }
+	return r
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	store := NewStore(db)
+
+	t.Run("commits with the transaction", func(t *testing.T) {
+		err := db.WithTx(ctx, nil, func(tx *database.Tx) error {
+			return Enqueue(ctx, tx, "thing.created", map[string]int{"id": 1})
+		})
+		if err != nil {
+			t.Fatalf("Failed to enqueue: %v", err)
+		}
+
+		events, err := store.List(ctx, StatusPending, 10)
+		if err != nil {
+			t.Fatalf("Failed to list events: %v", err)
+		}
+		if len(events) != 1 || events[0].Topic != "thing.created" || string(events[0].Payload) != `{"id":1}` {
+			t.Errorf("Unexpected events %+v", events)
+		}
+	})
+
+	t.Run("rolls back with the transaction", func(t *testing.T) {
+		err := db.WithTx(ctx, nil, func(tx *database.Tx) error {
+			if err := Enqueue(ctx, tx, "thing.created", map[string]int{"id": 2}); err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		if err == nil {
+			t.Fatal("Expected the transaction to fail")
+		}
+
+		stats, err := store.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read stats: %v", err)
+		}
+		if stats.Pending != 1 {
+			t.Errorf("Expected only the committed event, got %d pending", stats.Pending)
+		}
+	})
+
+	t.Run("rejects unmarshalable payloads", func(t *testing.T) {
+		if err := Enqueue(ctx, db, "thing.created", make(chan int)); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestRelay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivers to every handler of the topic", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 3)
+		var got []string
+		for _, name := range []string{"a", "b"} {
+			relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+				got = append(got, name+":"+string(e.Payload))
+				return nil
+			})
+		}
+
+		Enqueue(ctx, db, "thing.created", 1)
+		Enqueue(ctx, db, "other.topic", 2)
+
+		delivered, err := relay.RunOnce(ctx)
+		if err != nil || delivered != 1 {
+			t.Fatalf("Expected 1 delivery, got %d (%v)", delivered, err)
+		}
+		if strings.Join(got, ",") != "a:1,b:1" {
+			t.Errorf("Unexpected deliveries %v", got)
+		}
+
+		stats, _ := NewStore(db).Stats(ctx)
+		if stats.Delivered != 1 || stats.Pending != 1 || stats.OldestPending == nil {
+			t.Errorf("Expected the unhandled topic to stay pending, got %+v", stats)
+		}
+
+		if delivered, _ := relay.RunOnce(ctx); delivered != 0 {
+			t.Errorf("Expected delivered events not to be redelivered, got %d", delivered)
+		}
+	})
+
+	t.Run("retries failures and dead-letters them", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 3)
+		calls := 0
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			calls++
+			return errors.New("webhook unavailable")
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+		store := NewStore(db)
+
+		relay.RunOnce(ctx)
+		stats, _ := store.Stats(ctx)
+		if stats.Pending != 1 || stats.Retrying != 1 {
+			t.Errorf("Expected a retrying event, got %+v", stats)
+		}
+
+		relay.RunOnce(ctx)
+		relay.RunOnce(ctx)
+		relay.RunOnce(ctx)
+		if calls != 3 {
+			t.Errorf("Expected 3 attempts, got %d", calls)
+		}
+
+		dead, err := store.List(ctx, StatusDead, 10)
+		if err != nil {
+			t.Fatalf("Failed to list dead events: %v", err)
+		}
+		if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "webhook unavailable" {
+			t.Fatalf("Expected a dead-lettered event, got %+v", dead)
+		}
+
+		if err := store.Retry(ctx, dead[0].ID); err != nil {
+			t.Fatalf("Failed to retry: %v", err)
+		}
+		relay.RunOnce(ctx)
+		if calls != 4 {
+			t.Errorf("Expected the retried event to be redelivered, got %d calls", calls)
+		}
+		if err := store.Retry(ctx, 999); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("waits for the backoff", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := NewRelay(db, time.Hour, 100, 3, 0)
+		calls := 0
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			calls++
+			return errors.New("fail")
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+
+		relay.RunOnce(ctx)
+		relay.RunOnce(ctx)
+		if calls != 1 {
+			t.Errorf("Expected the retry to wait, got %d calls", calls)
+		}
+	})
+
+	t.Run("handler panics count as failures", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 1)
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			panic("boom")
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+
+		if _, err := relay.RunOnce(ctx); err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		dead, _ := NewStore(db).List(ctx, StatusDead, 10)
+		if len(dead) != 1 || !strings.Contains(dead[0].LastError, "panicked") {
+			t.Errorf("Expected a dead-lettered event, got %+v", dead)
+		}
+	})
+
+	t.Run("prunes delivered events after the retention", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 3)
+		relay.retention = time.Minute
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error { return nil })
+		Enqueue(ctx, db, "thing.created", 1)
+		relay.RunOnce(ctx)
+
+		if _, err := db.Exec(ctx, "UPDATE outbox SET delivered_at = ?", time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("Failed to age event: %v", err)
+		}
+		relay.RunOnce(ctx)
+
+		stats, _ := NewStore(db).Stats(ctx)
+		if stats.Delivered != 0 {
+			t.Errorf("Expected the delivered event to be pruned, got %+v", stats)
+		}
+	})
+
+	t.Run("concurrent relays deliver each event once", func(t *testing.T) {
+		db := setupTestDB(t)
+		for i := 0; i < 20; i++ {
+			Enqueue(ctx, db, "thing.created", i)
+		}
+
+		var mu sync.Mutex
+		deliveries := map[int64]int{}
+		relays := make([]*Relay, 3)
+		for i := range relays {
+			relays[i] = NewRelay(db, time.Hour, 5, 3, 0)
+			relays[i].Handle("thing.created", func(ctx context.Context, e Event) error {
+				mu.Lock()
+				defer mu.Unlock()
+				deliveries[e.ID]++
+				return nil
+			})
+		}
+
+		var wg sync.WaitGroup
+		for _, relay := range relays {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					delivered, err := relay.RunOnce(ctx)
+					if err != nil {
+						t.Errorf("RunOnce failed: %v", err)
+						return
+					}
+					if delivered == 0 {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) != 20 {
+			t.Errorf("Expected all 20 events delivered, got %d", len(deliveries))
+		}
+		for id, n := range deliveries {
+			if n != 1 {
+				t.Errorf("Expected event %d to be delivered once, got %d", id, n)
+			}
+		}
+	})
+
+	t.Run("skips events claimed by another relay", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 3)
+		calls := 0
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			calls++
+			return nil
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+
+		claim := "UPDATE outbox SET locked_by = 'other', locked_until = ?"
+		if _, err := db.Exec(ctx, claim, time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("Failed to claim event: %v", err)
+		}
+		relay.RunOnce(ctx)
+		if calls != 0 {
+			t.Errorf("Expected the claimed event to be skipped, got %d calls", calls)
+		}
+
+		// An expired claim, e.g. of a relay that crashed, is taken over
+		if _, err := db.Exec(ctx, claim, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("Failed to expire claim: %v", err)
+		}
+		if delivered, _ := relay.RunOnce(ctx); delivered != 1 || calls != 1 {
+			t.Errorf("Expected the expired claim to be taken over, got %d deliveries", delivered)
+		}
+	})
+
+	t.Run("does not record outcomes after losing the claim", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := newTestRelay(db, 3)
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			// Another relay takes over the event while this one delivers it
+			_, err := db.Exec(ctx, "UPDATE outbox SET locked_by = 'other'")
+			return err
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+
+		if delivered, err := relay.RunOnce(ctx); err != nil || delivered != 0 {
+			t.Fatalf("Expected no recorded delivery, got %d (%v)", delivered, err)
+		}
+		stats, _ := NewStore(db).Stats(ctx)
+		if stats.Pending != 1 {
+			t.Errorf("Expected the event to stay pending for the new claim holder, got %+v", stats)
+		}
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		db := setupTestDB(t)
+		relay := NewRelay(db, 10*time.Millisecond, 100, 3, 0)
+		delivered := make(chan Event, 1)
+		relay.Handle("thing.created", func(ctx context.Context, e Event) error {
+			delivered <- e
+			return nil
+		})
+		Enqueue(ctx, db, "thing.created", 1)
+
+		relay.Start(ctx)
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Error("Expected the event to be delivered in the background")
+		}
+		relay.Stop()
+		relay.Stop()
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{13, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempt); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// Handler publishes an event, e.g. by sending a notification or calling a
+// webhook. Delivery is at least once: an event is redelivered to every handler
+// of its topic until all of them succeed, so handlers must be idempotent (the
+// event ID makes a good idempotency key).
+type Handler func(ctx context.Context, event Event) error
+
+const (
+	// minRetryBackoff is the delay before the first retry; it doubles with
+	// every further attempt
+	minRetryBackoff = time.Second
+	// maxRetryBackoff caps the delay between attempts
+	maxRetryBackoff = time.Hour
+	// claimTimeout is how long a relay holds the events it claimed; events
+	// still claimed after it, e.g. of a relay that crashed, are claimed again
+	claimTimeout = 5 * time.Minute
+)
+
+// Relay publishes outbox events to the handlers registered for their topics.
+// Events of topics without handlers stay pending until one is registered.
+// Each batch is claimed before delivery, so relays of several app instances
+// can share an outbox without delivering the same event at the same time.
+type Relay struct {
+	db    *database.Database
+	store *Store
+	// owner identifies the relay in the claims it makes
+	owner        string
+	claimTimeout time.Duration
+	interval     time.Duration
+	batchSize    int
+	maxAttempts  int
+	retention    time.Duration
+	// backoff returns the delay before retrying after the given failed attempt
+	backoff func(attempt int) time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewRelay creates a relay that polls db every interval for up to batchSize
+// due events, dead-letters events after maxAttempts failed deliveries and
+// deletes delivered events older than retention (zero keeps them)
+func NewRelay(db *database.Database, interval time.Duration, batchSize, maxAttempts int, retention time.Duration) *Relay {
+	return &Relay{
+		db:           db,
+		store:        NewStore(db),
+		owner:        newRelayOwner(),
+		claimTimeout: claimTimeout,
+		interval:     interval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		retention:    retention,
+		backoff:      retryBackoff,
+		handlers:     make(map[string][]Handler),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// newRelayOwner returns a claim owner unique to this relay, e.g.
+// "web-1:4242:1a2b3c4d"
+func newRelayOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), uuid.New().String()[:8])
+}
+
+// retryBackoff doubles the delay with every failed attempt, up to
+// maxRetryBackoff
+func retryBackoff(attempt int) time.Duration {
+	if attempt > 20 {
+		return maxRetryBackoff
+	}
+	delay := minRetryBackoff << (attempt - 1)
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// Handle registers h for events of topic; register handlers before Start
+func (r *Relay) Handle(topic string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[topic] = append(r.handlers[topic], h)
+}
+
+// topics returns the topics with handlers
+func (r *Relay) topics() []interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	topics := make([]interface{}, 0, len(r.handlers))
+	for topic := range r.handlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Start runs the relay loop in the background until Stop is called
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.RunOnce(ctx); err != nil {
+					logger.FromContext(ctx).Error("Outbox relay failed",
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the relay loop and waits for the batch in flight to finish
+func (r *Relay) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+// RunOnce delivers one batch of due events and prunes old delivered events,
+// returning the number of events delivered
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	topics := r.topics()
+	if len(topics) == 0 {
+		return 0, r.prune(ctx)
+	}
+
+	events, err := r.claim(ctx, topics)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i, event := range events {
+		if ctx.Err() != nil {
+			r.release(ctx, events[i:])
+			break
+		}
+		ok, err := r.deliver(ctx, event)
+		if err != nil {
+			r.release(ctx, events[i+1:])
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, r.prune(ctx)
+}
+
+// claim marks up to batchSize due events of topics as claimed by this relay
+// until claimTimeout has passed and returns them. Postgres skips rows locked
+// by a concurrent claim; SQLite runs one write transaction at a time.
+func (r *Relay) claim(ctx context.Context, topics []interface{}) ([]Event, error) {
+	now := time.Now()
+	due, dueArgs := database.Select("id").From("outbox").
+		WhereExpr(database.And(
+			database.Eq("status", string(StatusPending)),
+			database.Lte("next_attempt_at", now),
+			database.In("topic", topics...),
+			unclaimed(now),
+		)).
+		OrderBy("id").Limit(r.batchSize).Build()
+	if r.db.Dialect() == database.Postgres {
+		due += " FOR UPDATE SKIP LOCKED"
+	}
+
+	query, args := database.Update("outbox").
+		Set("locked_by", r.owner).
+		Set("locked_until", now.Add(r.claimTimeout)).
+		WhereExpr(database.Raw("id IN ("+due+")", dueArgs...)).
+		// Recheck the claim, as Postgres reevaluates the outer condition
+		// on rows claimed by a transaction that committed meanwhile
+		WhereExpr(unclaimed(now)).
+		Build()
+	query += " RETURNING " + eventColumns
+
+	// The claim writes, so it cannot run on the read-only pool
+	var events []Event
+	err := r.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		var err error
+		events, err = NewStore(tx).query(ctx, query, args)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// unclaimed matches events without a claim or whose claim expired before now
+func unclaimed(now time.Time) database.Expr {
+	return database.Or(database.Raw("locked_until IS NULL"), database.Lt("locked_until", now))
+}
+
+// release gives up the claims on events that this relay did not deliver, so
+// another relay need not wait for them to expire
+func (r *Relay) release(ctx context.Context, events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	ids := make([]interface{}, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	query, args := database.Update("outbox").
+		Set("locked_by", "").
+		Set("locked_until", nil).
+		WhereExpr(database.And(database.In("id", ids...), database.Eq("locked_by", r.owner))).
+		Build()
+	// The relay may be stopping because ctx was cancelled
+	if _, err := r.db.Exec(context.WithoutCancel(ctx), query, args...); err != nil {
+		logger.FromContext(ctx).Warn("Failed to release outbox events",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// deliver publishes event to its handlers and records the outcome, reporting
+// whether every handler succeeded
+func (r *Relay) deliver(ctx context.Context, event Event) (bool, error) {
+	r.mu.RLock()
+	handlers := r.handlers[event.Topic]
+	r.mu.RUnlock()
+
+	var failures []string
+	for _, h := range handlers {
+		if err := callHandler(ctx, h, event); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	log := logger.FromContext(ctx)
+	// Only the claim holder records the outcome; a relay whose claim expired
+	// leaves the event to the relay that claimed it next
+	update := database.Update("outbox").
+		Set("locked_by", "").
+		Set("locked_until", nil).
+		WhereExpr(database.And(database.Eq("id", event.ID), database.Eq("locked_by", r.owner)))
+	attempts := event.Attempts + 1
+	switch {
+	case len(failures) == 0:
+		update.Set("status", string(StatusDelivered)).
+			Set("attempts", attempts).
+			Set("last_error", "").
+			Set("delivered_at", time.Now())
+	case attempts >= r.maxAttempts:
+		update.Set("status", string(StatusDead)).
+			Set("attempts", attempts).
+			Set("last_error", strings.Join(failures, "; "))
+		log.Error("Outbox event dead-lettered",
+			slog.Int64("event_id", event.ID),
+			slog.String("topic", event.Topic),
+			slog.Int("attempts", attempts),
+			slog.String("error", strings.Join(failures, "; ")),
+		)
+	default:
+		update.Set("attempts", attempts).
+			Set("last_error", strings.Join(failures, "; ")).
+			Set("next_attempt_at", time.Now().Add(r.backoff(attempts)))
+		log.Warn("Outbox event delivery failed",
+			slog.Int64("event_id", event.ID),
+			slog.String("topic", event.Topic),
+			slog.Int("attempts", attempts),
+			slog.String("error", strings.Join(failures, "; ")),
+		)
+	}
+
+	query, args := update.Build()
+	result, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to record delivery of outbox event %d: %w", event.ID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		log.Warn("Outbox event claim expired during delivery",
+			slog.Int64("event_id", event.ID),
+			slog.String("topic", event.Topic),
+		)
+		return false, nil
+	}
+	return len(failures) == 0, nil
+}
+
+// callHandler runs h, turning a panic into an error
+func callHandler(ctx context.Context, h Handler, event Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+	return h(ctx, event)
+}
+
+// prune deletes delivered events older than the retention period
+func (r *Relay) prune(ctx context.Context) error {
+	if r.retention <= 0 {
+		return nil
+	}
+	query, args := database.Delete("outbox").
+		WhereExpr(database.And(
+			database.Eq("status", string(StatusDelivered)),
+			database.Lt("delivered_at", time.Now().Add(-r.retention)),
+		)).
+		Build()
+	if _, err := r.db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to prune outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+)
+
+// ErasureStatus is the state of an erasure request
+type ErasureStatus string
+
+const (
+	// ErasurePending requests wait for their grace period to pass
+	ErasurePending ErasureStatus = "pending"
+	// ErasureCancelled requests were withdrawn during the grace period
+	ErasureCancelled ErasureStatus = "cancelled"
+	// ErasureCompleted requests have been carried out
+	ErasureCompleted ErasureStatus = "completed"
+)
+
+// Audit trail actions
+const (
+	ActionExported          = "exported"
+	ActionErasureRequested  = "erasure_requested"
+	ActionErasureCancelled  = "erasure_cancelled"
+	ActionErased            = "erased"
+	systemActor             = "system"
+	defaultErasureBatchSize = 100
+)
+
+// ErasureRequest is a request to erase a user's account
+type ErasureRequest struct {
+	ID          int64         `json:"id" db:"id,pk,auto"`
+	UserID      uint          `json:"user_id" db:"user_id"`
+	Status      ErasureStatus `json:"status" db:"status"`
+	RequestedBy string        `json:"requested_by" db:"requested_by"`
+	RequestedAt time.Time     `json:"requested_at" db:"requested_at"`
+	// ScheduledAt is when the grace period ends and the erasure is carried out
+	ScheduledAt time.Time  `json:"scheduled_at" db:"scheduled_at"`
+	CancelledBy string     `json:"cancelled_by,omitempty" db:"cancelled_by"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// TableName returns the table erasure requests are stored in
+func (ErasureRequest) TableName() string {
+	return "erasure_requests"
+}
+
+// AuditEvent is an entry of the privacy audit trail
+type AuditEvent struct {
+	ID        int64     `json:"id" db:"id,pk,auto"`
+	UserID    uint      `json:"user_id" db:"user_id"`
+	Action    string    `json:"action" db:"action"`
+	Actor     string    `json:"actor" db:"actor"`
+	Detail    string    `json:"detail,omitempty" db:"detail"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the table audit events are stored in
+func (AuditEvent) TableName() string {
+	return "privacy_audit"
+}
+
+// errNoLongerPending rolls back an erasure whose request was cancelled while
+// it ran
+var errNoLongerPending = errors.New("erasure request is no longer pending")
+
+// Store manages data exports, erasure requests and the audit trail
+type Store struct {
+	db    *database.Database
+	grace time.Duration
+}
+
+// NewStore creates a store over db that schedules erasures grace after they
+// are requested
+func NewStore(db *database.Database, grace time.Duration) *Store {
+	return &Store{db: db, grace: grace}
+}
+
+// RequestErasure schedules the erasure of user at the end of the grace
+// period. actor is the principal making the request. It fails with
+// database.ErrConflict if an erasure of the user is already pending.
+func (s *Store) RequestErasure(ctx context.Context, user *models.User, actor string) (*ErasureRequest, error) {
+	var req ErasureRequest
+	err := s.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		now := time.Now()
+		req = ErasureRequest{
+			UserID:      user.ID,
+			Status:      ErasurePending,
+			RequestedBy: actor,
+			RequestedAt: now,
+			ScheduledAt: now.Add(s.grace),
+		}
+		if err := models.NewRepository[ErasureRequest](tx).Create(ctx, &req); err != nil {
+			if errors.Is(err, database.ErrConflict) {
+				return fmt.Errorf("%w: an erasure of user %d is already pending", database.ErrConflict, user.ID)
+			}
+			return err
+		}
+		return s.audit(ctx, tx, user.ID, ActionErasureRequested, actor, "scheduled for "+req.ScheduledAt.UTC().Format(time.RFC3339))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// PendingErasure returns the pending erasure request of a user, or
+// database.ErrNotFound
+func (s *Store) PendingErasure(ctx context.Context, userID uint) (*ErasureRequest, error) {
+	repo := models.NewRepository[ErasureRequest](s.db)
+	req, err := repo.First(ctx, repo.Query().WhereExpr(database.And(
+		database.Eq("user_id", userID),
+		database.Eq("status", string(ErasurePending)),
+	)))
+	if err != nil {
+		return nil, fmt.Errorf("pending erasure of user %d: %w", userID, err)
+	}
+	return req, nil
+}
+
+// CancelErasure withdraws the pending erasure request of a user. It fails
+// with database.ErrNotFound if there is none.
+func (s *Store) CancelErasure(ctx context.Context, userID uint, actor string) error {
+	return s.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		query, args := database.Update("erasure_requests").
+			Set("status", string(ErasureCancelled)).
+			Set("cancelled_by", actor).
+			Set("cancelled_at", time.Now()).
+			WhereExpr(database.And(
+				database.Eq("user_id", userID),
+				database.Eq("status", string(ErasurePending)),
+			)).
+			Build()
+		result, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to cancel erasure: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("pending erasure of user %d: %w", userID, database.ErrNotFound)
+		}
+		return s.audit(ctx, tx, userID, ActionErasureCancelled, actor, "")
+	})
+}
+
+// Audit returns the audit trail of a user, oldest first
+func (s *Store) Audit(ctx context.Context, userID uint) ([]AuditEvent, error) {
+	return listAudit(ctx, s.db, userID)
+}
+
+// listAudit returns the audit trail of a user, oldest first
+func listAudit(ctx context.Context, q database.Querier, userID uint) ([]AuditEvent, error) {
+	repo := models.NewRepository[AuditEvent](q)
+	events, err := repo.List(ctx, repo.Query().WhereExpr(database.Eq("user_id", userID)).OrderBy("id"))
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []AuditEvent{}
+	}
+	return events, nil
+}
+
+// audit records an entry of the audit trail
+func (s *Store) audit(ctx context.Context, q database.Querier, userID uint, action, actor, detail string) error {
+	event := AuditEvent{UserID: userID, Action: action, Actor: actor, Detail: detail, CreatedAt: time.Now()}
+	if err := models.NewRepository[AuditEvent](q).Create(ctx, &event); err != nil {
+		return fmt.Errorf("failed to record %s audit event: %w", action, err)
+	}
+	return nil
+}
+
+// RunDue carries out up to batchSize erasures whose grace period has passed,
+// returning the number completed. A failing erasure is rolled back and
+// retried on the next run.
+func (s *Store) RunDue(ctx context.Context, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultErasureBatchSize
+	}
+	repo := models.NewRepository[ErasureRequest](s.db)
+	due, err := repo.List(ctx, repo.Query().WhereExpr(database.And(
+		database.Eq("status", string(ErasurePending)),
+		database.Lte("scheduled_at", time.Now()),
+	)).OrderBy("scheduled_at").Limit(batchSize))
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	var failures []string
+	for _, req := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		err := s.erase(ctx, &req)
+		if errors.Is(err, errNoLongerPending) {
+			continue
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("user %d: %v", req.UserID, err))
+			continue
+		}
+		completed++
+	}
+	if len(failures) > 0 {
+		return completed, fmt.Errorf("failed to erase %d users: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return completed, nil
+}
+
+// erase runs every eraser for the request's user and completes the request,
+// all in one transaction
+func (s *Store) erase(ctx context.Context, req *ErasureRequest) error {
+	return s.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		detail := "user no longer exists"
+		user, err := models.NewRepository[models.User](tx).Unscoped().Get(ctx, req.UserID)
+		switch {
+		case errors.Is(err, database.ErrNotFound):
+		case err != nil:
+			return err
+		default:
+			var names []string
+			for _, e := range registeredErasers() {
+				if err := e.fn(ctx, tx, user); err != nil {
+					return fmt.Errorf("failed to erase %s: %w", e.name, err)
+				}
+				names = append(names, e.name)
+			}
+			detail = "erased " + strings.Join(names, ", ")
+		}
+
+		query, args := database.Update("erasure_requests").
+			Set("status", string(ErasureCompleted)).
+			Set("completed_at", time.Now()).
+			WhereExpr(database.And(database.Eq("id", req.ID), database.Eq("status", string(ErasurePending)))).
+			Build()
+		result, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to complete erasure request: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return errNoLongerPending
+		}
+		return s.audit(ctx, tx, req.UserID, ActionErased, systemActor, detail)
+	})
+}
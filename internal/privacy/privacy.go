@@ -0,0 +1,149 @@
+// Package privacy lets users download the personal data held about them and
+// have their account erased. Each area of the application that stores
+// personal data registers an exporter, producing one JSON file of the user's
+// data export, and an eraser, deleting or anonymizing the user's rows. An
+// erasure is requested first and carried out after a grace period during
+// which it can be cancelled; every export and erasure step is recorded in an
+// audit trail that refers to the user by id only.
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+)
+
+// ExportFunc returns the data held about user in one area of the
+// application; it is written to the export as <name>.json. Return nil to
+// leave the file out.
+type ExportFunc func(ctx context.Context, q database.Querier, user *models.User) (interface{}, error)
+
+// EraseFunc deletes or anonymizes the data held about user in one area of the
+// application. It runs in the erasure's transaction and is passed the user as
+// it was before any eraser ran.
+type EraseFunc func(ctx context.Context, tx *database.Tx, user *models.User) error
+
+// named pairs a registered function with its name
+type named[F any] struct {
+	name string
+	fn   F
+}
+
+var (
+	registryMu sync.RWMutex
+	exporters  []named[ExportFunc]
+	erasers    []named[EraseFunc]
+)
+
+// RegisterExporter adds an exporter whose data is written to <name>.json.
+// It panics if the name is already registered, like sql.Register.
+func RegisterExporter(name string, fn ExportFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, e := range exporters {
+		if e.name == name {
+			panic("privacy: RegisterExporter called twice for " + name)
+		}
+	}
+	exporters = append(exporters, named[ExportFunc]{name: name, fn: fn})
+}
+
+// RegisterEraser adds an eraser. Erasers run in registration order. It panics
+// if the name is already registered, like sql.Register.
+func RegisterEraser(name string, fn EraseFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, e := range erasers {
+		if e.name == name {
+			panic("privacy: RegisterEraser called twice for " + name)
+		}
+	}
+	erasers = append(erasers, named[EraseFunc]{name: name, fn: fn})
+}
+
+// registeredExporters returns a snapshot of the exporters
+func registeredExporters() []named[ExportFunc] {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]named[ExportFunc](nil), exporters...)
+}
+
+// registeredErasers returns a snapshot of the erasers
+func registeredErasers() []named[EraseFunc] {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]named[EraseFunc](nil), erasers...)
+}
+
+// Manifest describes a data export; it is written to manifest.json
+type Manifest struct {
+	UserID      uint      `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+// Export writes a ZIP archive of everything the registered exporters hold
+// about user to w, and records the export in the audit trail. actor is the
+// principal who asked for it. The data is collected before anything is
+// written, so a failing exporter leaves w untouched.
+func (s *Store) Export(ctx context.Context, user *models.User, actor string, w io.Writer) error {
+	manifest := Manifest{UserID: user.ID, GeneratedAt: time.Now().UTC()}
+	files := map[string][]byte{}
+	for _, e := range registeredExporters() {
+		data, err := e.fn(ctx, s.db, user)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", e.name, err)
+		}
+		if data == nil {
+			continue
+		}
+		encoded, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", e.name, err)
+		}
+		name := e.name + ".json"
+		manifest.Files = append(manifest.Files, name)
+		files[name] = encoded
+	}
+
+	if err := s.audit(ctx, s.db, user.ID, ActionExported, actor, fmt.Sprintf("%d files", len(manifest.Files))); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeZipFile(zw, "manifest.json", encoded, manifest.GeneratedAt); err != nil {
+		return err
+	}
+	for _, name := range manifest.Files {
+		if err := writeZipFile(zw, name, files[name], manifest.GeneratedAt); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// writeZipFile adds a compressed file to an archive
+func writeZipFile(zw *zip.Writer, name string, data []byte, modified time.Time) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
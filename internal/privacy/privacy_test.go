@@ -0,0 +1,265 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/auth"
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// setupTestDB returns a migrated, empty database
+func setupTestDB(t *testing.T) *database.Database {
+	t.Helper()
+	return seedtest.NewDatabase(t, "../../migrations", "")
+}
+
+// createUser creates a user as actor
+func createUser(t *testing.T, db *database.Database, username, actor string) *models.User {
+	t.Helper()
+	ctx := context.Background()
+	if actor != "" {
+		ctx = auth.WithPrincipal(ctx, actor)
+	}
+	user := &models.User{Username: username, Email: username + "@example.com", Name: strings.ToUpper(username)}
+	if err := models.NewUserRepository(db).Create(ctx, user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return user
+}
+
+// readZip returns the files of a ZIP archive by name
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", f.Name, err)
+		}
+		files[f.Name], err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+	}
+	return files
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	store := NewStore(db, time.Hour)
+	ada := createUser(t, db, "ada", "")
+	createUser(t, db, "alan", "")
+
+	var buf bytes.Buffer
+	if err := store.Export(ctx, ada, "ada", &buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	files := readZip(t, buf.Bytes())
+
+	var manifest Manifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatalf("Failed to decode manifest: %v", err)
+	}
+	if manifest.UserID != ada.ID || len(manifest.Files) != 3 {
+		t.Errorf("Unexpected manifest %+v", manifest)
+	}
+	for _, name := range manifest.Files {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in the export", name)
+		}
+	}
+
+	var user models.User
+	if err := json.Unmarshal(files["user.json"], &user); err != nil {
+		t.Fatalf("Failed to decode user.json: %v", err)
+	}
+	if user.ID != ada.ID || user.Email != "ada@example.com" {
+		t.Errorf("Expected ada's account, got %+v", user)
+	}
+	if strings.Contains(string(buf.Bytes()), "alan") {
+		t.Error("Expected no data of other users in the export")
+	}
+
+	events, err := store.Audit(ctx, ada.ID)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Action != ActionExported || events[0].Actor != "ada" {
+		t.Errorf("Expected the export in the audit trail, got %+v", events)
+	}
+}
+
+func TestErasureRequests(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	store := NewStore(db, 24*time.Hour)
+	ada := createUser(t, db, "ada", "")
+
+	if _, err := store.PendingErasure(ctx, ada.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound before a request, got %v", err)
+	}
+
+	req, err := store.RequestErasure(ctx, ada, "ada")
+	if err != nil {
+		t.Fatalf("RequestErasure failed: %v", err)
+	}
+	if req.ID == 0 || req.Status != ErasurePending || req.ScheduledAt.Sub(req.RequestedAt) != 24*time.Hour {
+		t.Errorf("Unexpected request %+v", req)
+	}
+
+	t.Run("one pending request per user", func(t *testing.T) {
+		if _, err := store.RequestErasure(ctx, ada, "ada"); !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+
+	t.Run("not carried out during the grace period", func(t *testing.T) {
+		n, err := store.RunDue(ctx, 0)
+		if err != nil || n != 0 {
+			t.Errorf("Expected no erasures, got %d, %v", n, err)
+		}
+	})
+
+	pending, err := store.PendingErasure(ctx, ada.ID)
+	if err != nil || pending.ID != req.ID {
+		t.Fatalf("Expected the pending request, got %+v, %v", pending, err)
+	}
+
+	if err := store.CancelErasure(ctx, ada.ID, "root"); err != nil {
+		t.Fatalf("CancelErasure failed: %v", err)
+	}
+	if err := store.CancelErasure(ctx, ada.ID, "root"); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound cancelling again, got %v", err)
+	}
+	if _, err := store.RequestErasure(ctx, ada, "ada"); err != nil {
+		t.Errorf("Expected a new request after cancelling, got %v", err)
+	}
+
+	events, err := store.Audit(ctx, ada.ID)
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	var actions []string
+	for _, e := range events {
+		actions = append(actions, e.Action+":"+e.Actor)
+	}
+	want := "erasure_requested:ada erasure_cancelled:root erasure_requested:ada"
+	if strings.Join(actions, " ") != want {
+		t.Errorf("Expected audit trail %q, got %q", want, strings.Join(actions, " "))
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	store := NewStore(db, 0)
+	ada := createUser(t, db, "ada", "")
+	alan := createUser(t, db, "alan", "ada")
+
+	if _, err := store.RequestErasure(ctx, ada, "ada"); err != nil {
+		t.Fatalf("RequestErasure failed: %v", err)
+	}
+	n, err := NewWorker(store, time.Hour).RunOnce(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 erasure, got %d, %v", n, err)
+	}
+
+	users := models.NewRepository[models.User](db).Unscoped()
+	erased, err := users.Get(ctx, ada.ID)
+	if err != nil {
+		t.Fatalf("Failed to get erased user: %v", err)
+	}
+	if erased.Username != "erased-1" || erased.Email != "erased-1@invalid" || erased.Name != "" || erased.DeletedAt == nil {
+		t.Errorf("Expected an anonymized, deleted user, got %+v", erased)
+	}
+	if _, err := models.NewUserRepository(db).GetByID(ctx, ada.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Expected the erased user to be hidden, got %v", err)
+	}
+	other, err := users.Get(ctx, alan.ID)
+	if err != nil || other.CreatedBy != "erased-1" || other.Username != "alan" {
+		t.Errorf("Expected alan's creator to be anonymized, got %+v, %v", other, err)
+	}
+
+	t.Run("completes the request", func(t *testing.T) {
+		if _, err := store.PendingErasure(ctx, ada.ID); !errors.Is(err, database.ErrNotFound) {
+			t.Errorf("Expected no pending request, got %v", err)
+		}
+		repo := models.NewRepository[ErasureRequest](db)
+		req, err := repo.First(ctx, repo.Query().WhereExpr(database.Eq("user_id", ada.ID)))
+		if err != nil || req.Status != ErasureCompleted || req.CompletedAt == nil || req.RequestedBy != "erased-1" {
+			t.Errorf("Expected a completed, anonymized request, got %+v, %v", req, err)
+		}
+	})
+
+	t.Run("records the erasure without personal data", func(t *testing.T) {
+		events, err := store.Audit(ctx, ada.ID)
+		if err != nil {
+			t.Fatalf("Audit failed: %v", err)
+		}
+		last := events[len(events)-1]
+		if last.Action != ActionErased || last.Actor != "system" || !strings.Contains(last.Detail, "users") {
+			t.Errorf("Expected an erased audit event, got %+v", last)
+		}
+		for _, e := range events {
+			if strings.Contains(e.Actor+e.Detail, "ada") {
+				t.Errorf("Expected no username in the audit trail, got %+v", e)
+			}
+		}
+	})
+
+	t.Run("replaces the user events in the outbox", func(t *testing.T) {
+		events, err := outbox.NewStore(db).List(ctx, outbox.StatusPending, 100)
+		if err != nil {
+			t.Fatalf("Failed to list outbox: %v", err)
+		}
+		var topics []string
+		for _, e := range events {
+			if strings.Contains(string(e.Payload), "ada@example.com") {
+				t.Errorf("Expected ada's details to be removed from the outbox, got %s", e.Payload)
+			}
+			topics = append(topics, e.Topic)
+		}
+		sort.Strings(topics)
+		want := "user.created user.deleted"
+		if strings.Join(topics, " ") != want {
+			t.Errorf("Expected outbox topics %q, got %q", want, strings.Join(topics, " "))
+		}
+	})
+
+	t.Run("completes requests of vanished users", func(t *testing.T) {
+		if _, err := store.RequestErasure(ctx, alan, "alan"); err != nil {
+			t.Fatalf("RequestErasure failed: %v", err)
+		}
+		if err := models.NewUserRepository(db).Purge(ctx, alan.ID); err != nil {
+			t.Fatalf("Purge failed: %v", err)
+		}
+		if n, err := store.RunDue(ctx, 0); err != nil || n != 1 {
+			t.Fatalf("Expected 1 erasure, got %d, %v", n, err)
+		}
+		events, err := store.Audit(ctx, alan.ID)
+		if err != nil {
+			t.Fatalf("Audit failed: %v", err)
+		}
+		if last := events[len(events)-1]; last.Action != ActionErased || last.Detail != "user no longer exists" {
+			t.Errorf("Expected an erased audit event, got %+v", last)
+		}
+	})
+}
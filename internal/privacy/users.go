@@ -0,0 +1,127 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/outbox"
+)
+
+func init() {
+	RegisterExporter("user", exportUser)
+	RegisterExporter("erasure_requests", exportErasureRequests)
+	RegisterExporter("privacy_audit", exportAudit)
+
+	// Erasers match the user by username, so the users eraser, which
+	// replaces it, runs last
+	RegisterEraser("outbox", eraseOutbox)
+	RegisterEraser("privacy", erasePrivacy)
+	RegisterEraser("users", eraseUser)
+}
+
+// erasedName returns the placeholder that replaces the username of an erased
+// user
+func erasedName(id uint) string {
+	return fmt.Sprintf("erased-%d", id)
+}
+
+// exportUser exports the user's account
+func exportUser(ctx context.Context, q database.Querier, user *models.User) (interface{}, error) {
+	return models.NewRepository[models.User](q).Unscoped().Get(ctx, user.ID)
+}
+
+// exportErasureRequests exports the user's erasure requests
+func exportErasureRequests(ctx context.Context, q database.Querier, user *models.User) (interface{}, error) {
+	repo := models.NewRepository[ErasureRequest](q)
+	requests, err := repo.List(ctx, repo.Query().WhereExpr(database.Eq("user_id", user.ID)).OrderBy("id"))
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []ErasureRequest{}
+	}
+	return requests, nil
+}
+
+// exportAudit exports the user's audit trail
+func exportAudit(ctx context.Context, q database.Querier, user *models.User) (interface{}, error) {
+	return listAudit(ctx, q, user.ID)
+}
+
+// eraseOutbox deletes the user created and updated events, which carry the
+// user's details, whether or not they have been delivered. The user's events
+// are found by the id in their payload, so the rest of the outbox is not read.
+func eraseOutbox(ctx context.Context, tx *database.Tx, user *models.User) error {
+	payloadID := database.Raw("json_extract(payload, '$.id') = ?", user.ID)
+	if tx.Dialect() == database.Postgres {
+		payloadID = database.Raw("payload::jsonb->>'id' = ?", strconv.FormatUint(uint64(user.ID), 10))
+	}
+
+	query, args := database.Delete("outbox").
+		WhereExpr(database.And(
+			database.In("topic", models.UserCreatedEvent, models.UserUpdatedEvent),
+			payloadID,
+		)).
+		Build()
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete outbox events: %w", err)
+	}
+	return nil
+}
+
+// erasePrivacy replaces the username wherever the user acted on erasure
+// requests or the audit trail; the rows themselves refer to users by id and
+// are kept as the record of the erasure
+func erasePrivacy(ctx context.Context, tx *database.Tx, user *models.User) error {
+	placeholder := erasedName(user.ID)
+	for _, c := range []struct{ table, column string }{
+		{"erasure_requests", "requested_by"},
+		{"erasure_requests", "cancelled_by"},
+		{"privacy_audit", "actor"},
+	} {
+		query, args := database.Update(c.table).
+			Set(c.column, placeholder).
+			WhereExpr(database.Eq(c.column, user.Username)).
+			Build()
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to anonymize %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// eraseUser anonymizes and soft-deletes the user's account, replaces the
+// username wherever the user created or updated other users, and enqueues a
+// UserDeletedEvent. The row is kept so the id stays taken.
+func eraseUser(ctx context.Context, tx *database.Tx, user *models.User) error {
+	placeholder := erasedName(user.ID)
+	now := time.Now()
+	query, args := database.Update("users").
+		Set("username", placeholder).
+		Set("email", placeholder+"@invalid").
+		Set("name", "").
+		Set("updated_by", systemActor).
+		Set("updated_at", now).
+		SetExpr("deleted_at", database.Raw("COALESCE(deleted_at, ?)", now)).
+		SetExpr("version", database.Raw("version + 1")).
+		WhereExpr(database.Eq("id", user.ID)).
+		Build()
+	if _, err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", database.MapError(err))
+	}
+
+	for _, column := range []string{"created_by", "updated_by"} {
+		query, args := database.Update("users").
+			Set(column, placeholder).
+			WhereExpr(database.Eq(column, user.Username)).
+			Build()
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to anonymize users.%s: %w", column, err)
+		}
+	}
+	return outbox.Enqueue(ctx, tx, models.UserDeletedEvent, map[string]uint{"id": user.ID})
+}
@@ -0,0 +1,72 @@
+package privacy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tediscript/gostarterkit/internal/logger"
+)
+
+// Worker carries out erasures once their grace period has passed
+type Worker struct {
+	store     *Store
+	interval  time.Duration
+	batchSize int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewWorker creates a worker that checks store for due erasures every interval
+func NewWorker(store *Store, interval time.Duration) *Worker {
+	return &Worker{
+		store:     store,
+		interval:  interval,
+		batchSize: defaultErasureBatchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the erasure loop in the background until Stop is called
+func (w *Worker) Start(ctx context.Context) {
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := w.RunOnce(ctx); err != nil {
+					logger.FromContext(ctx).Error("Account erasure failed",
+						slog.String("error", err.Error()),
+					)
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the erasure loop and waits for the erasures in flight to finish
+func (w *Worker) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// RunOnce carries out the due erasures, returning the number completed
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	n, err := w.store.RunDue(ctx, w.batchSize)
+	if n > 0 {
+		logger.FromContext(ctx).Info("Accounts erased", slog.Int("count", n))
+	}
+	return n, err
+}
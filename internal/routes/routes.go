@@ -8,6 +8,7 @@ import (
 	"github.com/tediscript/gostarterkit/internal/auth"
 	"github.com/tediscript/gostarterkit/internal/config"
 	"github.com/tediscript/gostarterkit/internal/handlers"
+	"github.com/tediscript/gostarterkit/internal/metrics"
 	"github.com/tediscript/gostarterkit/internal/middlewares"
 )
 
@@ -35,6 +36,44 @@ func Routes(mux *http.ServeMux, h *handlers.Handlers, cfg *config.Config, tpl *t
 	mux.HandleFunc("POST /api/login", handlers.APILoginHandler)
 	mux.Handle("GET /api/protected", middlewares.JWTAuthMiddleware(http.HandlerFunc(handlers.APIProtectedHandler)))
 
+	// User API routes (JWT)
+	if h.Users != nil {
+		mux.Handle("GET /api/users", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.ListUsers)))
+		mux.Handle("POST /api/users", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.CreateUser)))
+		mux.Handle("GET /api/users/search", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.SearchUsers)))
+		mux.Handle("GET /api/users/{id}", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.GetUser)))
+		mux.Handle("PATCH /api/users/{id}", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.UpdateUser)))
+		mux.Handle("DELETE /api/users/{id}", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.DeleteUser)))
+	}
+	if h.Users != nil && h.Privacy != nil {
+		mux.Handle("GET /api/users/{id}/export", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.ExportUserData)))
+		mux.Handle("POST /api/users/{id}/erasure", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.RequestErasure)))
+		mux.Handle("GET /api/users/{id}/erasure", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.GetErasure)))
+		mux.Handle("DELETE /api/users/{id}/erasure", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.CancelErasure)))
+	}
+
+	// Admin API routes (JWT)
+	mux.Handle("GET /api/admin/log-level", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.GetLogLevel)))
+	mux.Handle("PUT /api/admin/log-level", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.SetLogLevel)))
+	mux.Handle("DELETE /api/admin/log-level", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.ResetLogLevel)))
+	if h.Outbox != nil {
+		mux.Handle("GET /api/admin/outbox", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.OutboxStatus)))
+		mux.Handle("POST /api/admin/outbox/{id}/retry", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.RetryOutboxEvent)))
+	}
+	if h.Transfer != nil {
+		mux.Handle("GET /api/admin/export/{model}", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.ExportModel)))
+		mux.Handle("POST /api/admin/import/{model}", middlewares.JWTAuthMiddleware(http.HandlerFunc(h.ImportModel)))
+	}
+
+	// Prometheus metrics
+	if cfg.Metrics.Enabled {
+		var metricsHandler http.Handler = metrics.Handler(metrics.Default)
+		if cfg.Metrics.Token != "" {
+			metricsHandler = middlewares.MetricsTokenMiddleware(cfg.Metrics.Token)(metricsHandler)
+		}
+		mux.Handle("GET /metrics", metricsHandler)
+	}
+
 	// Create rate limit middleware with configuration
 	rateLimitMiddleware := middlewares.RateLimitMiddleware(
 		cfg.RateLimit.RequestsPerWindow,
@@ -44,8 +83,12 @@ func Routes(mux *http.ServeMux, h *handlers.Handlers, cfg *config.Config, tpl *t
 	// Apply middlewares to all routes (order matters)
 	return middlewares.CorrelationIDMiddleware(
 		middlewares.RequestIDMiddleware(
-			middlewares.LoggingMiddleware(
-				rateLimitMiddleware(mux),
+			middlewares.TracingMiddleware(
+				middlewares.LoggingMiddleware(
+					middlewares.MetricsMiddleware(
+						rateLimitMiddleware(mux),
+					),
+				),
 			),
 		),
 	)
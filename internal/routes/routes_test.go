@@ -5,6 +5,7 @@ import (
 	"html/template"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/tediscript/gostarterkit/internal/config"
@@ -446,3 +447,49 @@ func TestRoutesWithQueryParameters(t *testing.T) {
 		})
 	}
 }
+
+// TestMetricsRoute tests that /metrics serves request counts by route pattern
+// and requires the token when one is configured
+func TestMetricsRoute(t *testing.T) {
+	h := handlers.New(&MockTemplateCache{}, "./templates", health.New(&mockDB{}))
+	tpl, err := template.New("base.html").Parse("{{define \"base.html\"}}{{end}}")
+	if err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.RateLimit.RequestsPerWindow = 100
+	cfg.RateLimit.WindowSeconds = 60
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Token = "scrape"
+	handler := Routes(http.NewServeMux(), h, cfg, tpl)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/status", nil))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the token, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scrape")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	if want := `http_requests_total{method="GET",route="GET /api/status",status="200"}`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("Expected %s in\n%s", want, rr.Body.String())
+	}
+
+	t.Run("not registered when disabled", func(t *testing.T) {
+		cfg.Metrics.Enabled = false
+		handler := Routes(http.NewServeMux(), h, cfg, tpl)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+		if strings.Contains(rr.Body.String(), "http_requests_total") {
+			t.Error("Expected no metrics when disabled")
+		}
+	})
+}
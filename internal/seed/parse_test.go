@@ -0,0 +1,33 @@
+package seed
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnValue(t *testing.T) {
+	tables, err := parseFile([]byte(`[{"table": "t", "key": ["id"], "rows": [
+		{"id": 1, "ratio": 0.5, "tags": ["a", "b"], "meta": {"k": true}, "name": null}
+	]}]`))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"id":    int64(1),
+		"ratio": 0.5,
+		"tags":  `["a","b"]`,
+		"meta":  `{"k":true}`,
+		"name":  nil,
+	}
+	if got := tables[0].Rows[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := parseFile([]byte(`[{"table": "t", "rows": [{"id": 1}]}]`)); err == nil {
+		t.Error("Expected an error for a missing key")
+	}
+	if _, err := parseFile([]byte(`[{"table": "t", "key": ["id"], "rows": [{"name": "x"}]}]`)); err == nil {
+		t.Error("Expected an error for a row without its key")
+	}
+}
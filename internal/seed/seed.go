@@ -0,0 +1,271 @@
+// Package seed loads named sets of development and test data into the
+// database. Sets are JSON files in a seeds directory or Go functions
+// registered with Register; every row is upserted, so running a set again
+// updates it in place instead of duplicating it.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+// ErrProduction is returned when seeding is attempted with APP_ENV=production
+var ErrProduction = errors.New("seeding is disabled in production")
+
+// ErrUnknownSet is returned for a set that is neither registered nor a file
+var ErrUnknownSet = errors.New("unknown seed set")
+
+// Func seeds data inside the transaction of a Run; use Upsert to keep it
+// idempotent
+type Func func(ctx context.Context, tx *database.Tx) error
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Func{}
+)
+
+// Register makes a Go seed set available under name. It panics if the name
+// is already registered, like sql.Register.
+func Register(name string, fn Func) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if fn == nil {
+		panic("seed: Register func is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("seed: Register called twice for set " + name)
+	}
+	registry[name] = fn
+}
+
+// registered returns the Go seed set named name
+func registered(name string) (Func, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	fn, ok := registry[name]
+	return fn, ok
+}
+
+// Table is the data a seed file holds for one table. Rows are upserted on
+// the Key columns, which must have a unique index.
+type Table struct {
+	Table string                   `json:"table"`
+	Key   []string                 `json:"key"`
+	Rows  []map[string]interface{} `json:"rows"`
+}
+
+var (
+	// identifierPattern matches the table and column names seeds may use
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// setNamePattern matches set names, keeping file sets inside the seeds dir
+	setNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Seeder loads seed sets into a database
+type Seeder struct {
+	db  *database.Database
+	dir string
+	env string
+}
+
+// NewSeeder creates a seeder reading file sets from dir for the APP_ENV env
+func NewSeeder(db *database.Database, dir, env string) *Seeder {
+	return &Seeder{db: db, dir: dir, env: env}
+}
+
+// Sets returns the names of the available sets, Go and file sets alike
+func (s *Seeder) Sets() ([]string, error) {
+	names := map[string]bool{}
+	registryMu.RLock()
+	for name := range registry {
+		names[name] = true
+	}
+	registryMu.RUnlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seed files: %w", err)
+	}
+	for _, file := range files {
+		names[strings.TrimSuffix(filepath.Base(file), ".json")] = true
+	}
+
+	sets := make([]string, 0, len(names))
+	for name := range names {
+		sets = append(sets, name)
+	}
+	sort.Strings(sets)
+	return sets, nil
+}
+
+// Run loads the named sets in order inside a single transaction, so either
+// all of them are applied or none. It refuses to run in production.
+func (s *Seeder) Run(ctx context.Context, names ...string) error {
+	if s.env == "production" {
+		return ErrProduction
+	}
+
+	// Resolve every set before touching the database
+	funcs := make([]Func, len(names))
+	for i, name := range names {
+		fn, err := s.load(name)
+		if err != nil {
+			return err
+		}
+		funcs[i] = fn
+	}
+
+	return s.db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		for i, fn := range funcs {
+			if err := fn(ctx, tx); err != nil {
+				return fmt.Errorf("failed to seed %s: %w", names[i], err)
+			}
+		}
+		return nil
+	})
+}
+
+// load returns the seed set named name, preferring a registered Go set over a
+// file of the same name
+func (s *Seeder) load(name string) (Func, error) {
+	if fn, ok := registered(name); ok {
+		return fn, nil
+	}
+	if !setNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSet, name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSet, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed file: %w", err)
+	}
+
+	tables, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid seed file %s.json: %w", name, err)
+	}
+	return func(ctx context.Context, tx *database.Tx) error {
+		for _, t := range tables {
+			for _, row := range t.Rows {
+				if err := Upsert(ctx, tx, t.Table, t.Key, row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}, nil
+}
+
+// parseFile decodes and checks a seed file: a JSON array of tables
+func parseFile(data []byte) ([]Table, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	var tables []Table
+	if err := dec.Decode(&tables); err != nil {
+		return nil, err
+	}
+
+	for i, t := range tables {
+		if !identifierPattern.MatchString(t.Table) {
+			return nil, fmt.Errorf("table %d: invalid table name %q", i, t.Table)
+		}
+		if len(t.Key) == 0 {
+			return nil, fmt.Errorf("table %s: key is required", t.Table)
+		}
+		for j, row := range t.Rows {
+			for _, k := range t.Key {
+				if _, ok := row[k]; !ok {
+					return nil, fmt.Errorf("table %s: row %d is missing key column %q", t.Table, j, k)
+				}
+			}
+			for column, value := range row {
+				v, err := columnValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("table %s: row %d column %q: %w", t.Table, j, column, err)
+				}
+				row[column] = v
+			}
+		}
+	}
+	return tables, nil
+}
+
+// columnValue converts a decoded JSON value to a value the database driver can bind.
+// Numbers become integers where possible, and arrays and objects are stored as
+// JSON text.
+func columnValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	default:
+		return v, nil
+	}
+}
+
+// Upsert inserts row into table, or updates the existing row whose key
+// columns match. The key columns must have a unique index.
+func Upsert(ctx context.Context, q database.Querier, table string, key []string, row map[string]interface{}) error {
+	if !identifierPattern.MatchString(table) {
+		return fmt.Errorf("invalid table name %q", table)
+	}
+	isKey := map[string]bool{}
+	for _, k := range key {
+		if !identifierPattern.MatchString(k) {
+			return fmt.Errorf("invalid key column %q", k)
+		}
+		isKey[k] = true
+	}
+
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		if !identifierPattern.MatchString(column) {
+			return fmt.Errorf("invalid column name %q", column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	insert := database.Insert(table)
+	var updates []string
+	for _, column := range columns {
+		insert.Set(column, row[column])
+		if !isKey[column] {
+			updates = append(updates, column)
+		}
+	}
+	if len(updates) > 0 {
+		insert.OnConflictUpdate(key, updates...)
+	} else {
+		insert.OnConflictDoNothing(key...)
+	}
+	query, args := insert.Build()
+
+	if _, err := q.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to upsert into %s: %w", table, database.MapError(err))
+	}
+	return nil
+}
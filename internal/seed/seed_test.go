@@ -0,0 +1,183 @@
+package seed_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/seed"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+const migrationsDir = "../../migrations"
+
+// userCount returns the number of users in db
+func userCount(t *testing.T, db *database.Database) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(context.Background(), "SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	return n
+}
+
+func TestSeederRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("is idempotent", func(t *testing.T) {
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata", "users")
+		if _, err := db.Exec(ctx, "UPDATE users SET name = 'Changed' WHERE username = 'ada'"); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+
+		if err := seed.NewSeeder(db, "testdata", "development").Run(ctx, "users", "users"); err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+		if n := userCount(t, db); n != 2 {
+			t.Errorf("Expected 2 users after reseeding, got %d", n)
+		}
+
+		var name string
+		if err := db.QueryRow(ctx, "SELECT name FROM users WHERE username = 'ada'").Scan(&name); err != nil {
+			t.Fatalf("Failed to read user: %v", err)
+		}
+		if name != "Ada Lovelace" {
+			t.Errorf("Expected the seeded name to be restored, got %q", name)
+		}
+	})
+
+	t.Run("refuses to run in production", func(t *testing.T) {
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata")
+		err := seed.NewSeeder(db, "testdata", "production").Run(ctx, "users")
+		if !errors.Is(err, seed.ErrProduction) {
+			t.Errorf("Expected ErrProduction, got %v", err)
+		}
+		if n := userCount(t, db); n != 0 {
+			t.Errorf("Expected no users, got %d", n)
+		}
+	})
+
+	t.Run("unknown sets", func(t *testing.T) {
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata")
+		for _, name := range []string{"missing", "../seed_test", ""} {
+			if err := seed.NewSeeder(db, "testdata", "test").Run(ctx, name); !errors.Is(err, seed.ErrUnknownSet) {
+				t.Errorf("Expected ErrUnknownSet for %q, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("invalid files are rejected", func(t *testing.T) {
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata")
+		err := seed.NewSeeder(db, "testdata", "test").Run(ctx, "invalid")
+		if err == nil || !strings.Contains(err.Error(), "invalid table name") {
+			t.Errorf("Expected an invalid table name error, got %v", err)
+		}
+	})
+
+	t.Run("go sets run in the same transaction", func(t *testing.T) {
+		seed.Register("test-failing", func(ctx context.Context, tx *database.Tx) error {
+			return errors.New("boom")
+		})
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata")
+
+		err := seed.NewSeeder(db, "testdata", "test").Run(ctx, "users", "test-failing")
+		if err == nil || !strings.Contains(err.Error(), "test-failing") {
+			t.Errorf("Expected the failing set to be named, got %v", err)
+		}
+		if n := userCount(t, db); n != 0 {
+			t.Errorf("Expected the users set to be rolled back, got %d users", n)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	seed.Register("test-users", func(ctx context.Context, tx *database.Tx) error {
+		return seed.Upsert(ctx, tx, "users", []string{"username"}, map[string]interface{}{
+			"username": "grace",
+			"email":    "grace@example.com",
+		})
+	})
+
+	t.Run("registered sets are listed and run", func(t *testing.T) {
+		db := seedtest.NewDatabase(t, migrationsDir, "testdata", "test-users")
+		if n := userCount(t, db); n != 1 {
+			t.Errorf("Expected 1 user, got %d", n)
+		}
+
+		sets, err := seed.NewSeeder(db, "testdata", "test").Sets()
+		if err != nil {
+			t.Fatalf("Failed to list sets: %v", err)
+		}
+		for _, want := range []string{"test-users", "users"} {
+			found := false
+			for _, name := range sets {
+				found = found || name == want
+			}
+			if !found {
+				t.Errorf("Expected %q in %v", want, sets)
+			}
+		}
+	})
+
+	t.Run("duplicate names panic", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic")
+			}
+		}()
+		seed.Register("test-users", func(ctx context.Context, tx *database.Tx) error { return nil })
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	ctx := context.Background()
+	db := seedtest.NewDatabase(t, migrationsDir, "testdata")
+
+	t.Run("rejects unsafe identifiers", func(t *testing.T) {
+		tests := []struct {
+			table string
+			key   []string
+			row   map[string]interface{}
+		}{
+			{"users x", []string{"username"}, map[string]interface{}{"username": "a"}},
+			{"users", []string{"username)"}, map[string]interface{}{"username": "a"}},
+			{"users", []string{"username"}, map[string]interface{}{"username": "a", "email = 1 --": "b"}},
+		}
+		for _, tt := range tests {
+			if err := seed.Upsert(ctx, db, tt.table, tt.key, tt.row); err == nil {
+				t.Errorf("Expected an error for %v", tt)
+			}
+		}
+	})
+
+	t.Run("key only rows do nothing on conflict", func(t *testing.T) {
+		if _, err := db.Exec(ctx, "CREATE TABLE tags (name TEXT PRIMARY KEY)"); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := seed.Upsert(ctx, db, "tags", []string{"name"}, map[string]interface{}{"name": "go"}); err != nil {
+				t.Fatalf("Failed to upsert: %v", err)
+			}
+		}
+		var n int
+		if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM tags").Scan(&n); err != nil || n != 1 {
+			t.Errorf("Expected 1 tag, got %d (%v)", n, err)
+		}
+	})
+
+	t.Run("conflicts on other unique columns are mapped", func(t *testing.T) {
+		row := map[string]interface{}{"username": "grace", "email": "grace@example.com"}
+		if err := seed.Upsert(ctx, db, "users", []string{"username"}, row); err != nil {
+			t.Fatalf("Failed to upsert: %v", err)
+		}
+		err := seed.Upsert(ctx, db, "users", []string{"username"}, map[string]interface{}{
+			"username": "other",
+			"email":    "grace@example.com",
+		})
+		if !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected ErrConflict, got %v", err)
+		}
+	})
+}
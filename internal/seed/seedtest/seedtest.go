@@ -0,0 +1,61 @@
+// Package seedtest provides migrated, seeded databases for tests. It is kept
+// apart from package seed so the testing package is not linked into the
+// application.
+package seedtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/config"
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/database/databasetest"
+	"github.com/tediscript/gostarterkit/internal/seed"
+)
+
+// NewDatabase returns a fresh database, migrated from migrationsDir and
+// loaded with the named sets from seedsDir. It is closed when the test ends,
+// so every test gets its own fixtures.
+//
+// The database is a SQLite file in a temporary directory. With
+// TEST_DB_DRIVER=postgres it is a new schema in the TEST_POSTGRES_URL database
+// instead, dropped when the test ends; the test is skipped if no URL is set.
+func NewDatabase(t testing.TB, migrationsDir, seedsDir string, sets ...string) *database.Database {
+	t.Helper()
+
+	cfg := &config.Config{}
+	if os.Getenv("TEST_DB_DRIVER") == "postgres" {
+		cfg.Database.Driver = "postgres"
+		cfg.Postgres.URL = databasetest.PostgresURL(t)
+		cfg.Postgres.MaxOpenConnections = 5
+		cfg.Postgres.MaxIdleConnections = 2
+		cfg.Postgres.ConnectionMaxLifetimeSeconds = 300
+	} else {
+		cfg.Database.Driver = "sqlite"
+		cfg.SQLite.DBFile = filepath.Join(t.TempDir(), "test.db")
+		cfg.SQLite.MaxOpenConnections = 5
+		cfg.SQLite.MaxIdleConnections = 2
+		cfg.SQLite.ConnectionMaxLifetimeSeconds = 300
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close test database: %v", err)
+		}
+	})
+
+	ctx := context.Background()
+	if err := database.NewMigrationRunner(db, migrationsDir).Migrate(ctx); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	if err := seed.NewSeeder(db, seedsDir, "test").Run(ctx, sets...); err != nil {
+		t.Fatalf("Failed to load fixtures: %v", err)
+	}
+	return db
+}
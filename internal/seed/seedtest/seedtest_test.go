@@ -0,0 +1,43 @@
+package seedtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+)
+
+const migrationsDir = "../../../migrations"
+
+// userCount returns the number of users in db
+func userCount(t *testing.T, db *database.Database) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(context.Background(), "SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("Failed to count users: %v", err)
+	}
+	return n
+}
+
+func TestNewDatabase(t *testing.T) {
+	t.Run("loads fixtures", func(t *testing.T) {
+		db := NewDatabase(t, migrationsDir, "../testdata", "users")
+		if n := userCount(t, db); n != 2 {
+			t.Errorf("Expected 2 users, got %d", n)
+		}
+	})
+
+	t.Run("each test gets a fresh database", func(t *testing.T) {
+		db := NewDatabase(t, migrationsDir, "../testdata")
+		if n := userCount(t, db); n != 0 {
+			t.Errorf("Expected no users, got %d", n)
+		}
+	})
+
+	t.Run("shipped dev seeds load", func(t *testing.T) {
+		db := NewDatabase(t, migrationsDir, "../../../seeds", "dev")
+		if n := userCount(t, db); n == 0 {
+			t.Error("Expected dev users")
+		}
+	})
+}
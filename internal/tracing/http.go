@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Header names of the W3C Trace Context specification
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Extract returns a context continuing the trace of the traceparent header in
+// h, or ctx unchanged if there is no valid one
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = h.Get(TracestateHeader)
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject sets the traceparent and tracestate headers in h from the span
+// context in ctx
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+// Transport is an http.RoundTripper that records a client span for each
+// outbound request and propagates the trace to the server
+type Transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport wraps base, or http.DefaultTransport if it is nil
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base}
+}
+
+// RoundTrip sends req within a client span
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), "HTTP "+req.Method,
+		WithKind(KindClient),
+		WithAttributes(
+			String("http.request.method", req.Method),
+			String("url.full", redactedURL(req)),
+			String("server.address", req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// RoundTrippers must not modify the request they are given
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(StatusError, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// redactedURL returns the request URL without credentials or query string,
+// which may hold secrets
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// BatchProcessor queues finished spans and exports them in batches, every
+// interval or as soon as a batch is full. Spans are dropped while the queue is
+// full, so a slow or unreachable backend never blocks requests.
+type BatchProcessor struct {
+	exporter  Exporter
+	interval  time.Duration
+	batchSize int
+	timeout   time.Duration
+
+	queue chan SpanData
+	// flush asks the loop to export the queue now, e.g. when a batch is full
+	flush chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewBatchProcessor creates a processor that queues up to queueSize spans and
+// exports up to batchSize of them at a time, at least every interval, giving
+// each export timeout to finish
+func NewBatchProcessor(exporter Exporter, interval time.Duration, batchSize, queueSize int, timeout time.Duration) *BatchProcessor {
+	return &BatchProcessor{
+		exporter:  exporter,
+		interval:  interval,
+		batchSize: batchSize,
+		timeout:   timeout,
+		queue:     make(chan SpanData, queueSize),
+		flush:     make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// OnEnd queues span for export
+func (p *BatchProcessor) OnEnd(span SpanData) {
+	select {
+	case p.queue <- span:
+	default:
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+		return
+	}
+	if len(p.queue) >= p.batchSize {
+		select {
+		case p.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of spans dropped because the queue was full
+func (p *BatchProcessor) Dropped() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped
+}
+
+// Start runs the export loop in the background until Stop is called
+func (p *BatchProcessor) Start(ctx context.Context) {
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				p.ExportQueued(context.WithoutCancel(ctx))
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.ExportQueued(ctx)
+			case <-p.flush:
+				p.ExportQueued(ctx)
+			}
+		}
+	}()
+}
+
+// Stop ends the export loop after exporting the spans still queued
+func (p *BatchProcessor) Stop() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+// ExportQueued exports the queued spans in batches, returning the number of
+// spans exported. Spans of a failed batch are logged and dropped rather than
+// requeued, so an unreachable backend cannot fill the queue.
+func (p *BatchProcessor) ExportQueued(ctx context.Context) int {
+	exported := 0
+	for {
+		batch := p.take()
+		if len(batch) == 0 {
+			return exported
+		}
+
+		exportCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		err := p.exporter.Export(exportCtx, batch)
+		cancel()
+		if err != nil {
+			slog.Default().Error("Span export failed",
+				slog.Int("spans", len(batch)),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		exported += len(batch)
+	}
+}
+
+// take removes up to batchSize spans from the queue
+func (p *BatchProcessor) take() []SpanData {
+	var batch []SpanData
+	for len(batch) < p.batchSize {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
@@ -0,0 +1,439 @@
+// Package tracing records OpenTelemetry-compatible spans and propagates trace
+// context between services with the W3C traceparent header. Spans are
+// started with Start, which continues the trace found in the context, and
+// sampled spans are handed to the tracer's processor when they end, usually a
+// BatchProcessor exporting them over OTLP/HTTP. Without a processor, spans
+// still carry trace and span IDs, so logs and downstream calls can be
+// correlated, but nothing is recorded.
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the ID as 32 lowercase hex digits
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the ID as 16 lowercase hex digits
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+// FlagSampled is the trace flag set when the trace is being recorded
+const FlagSampled byte = 0x01
+
+// SpanContext is the part of a span that is propagated across process
+// boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	// TraceState is the vendor-specific tracestate header, passed on as is
+	TraceState string
+	// Remote reports whether the context was received from another process
+	Remote bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent formats the context as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ErrInvalidTraceparent is returned for a malformed traceparent header
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses a traceparent header value. Versions after 00 are
+// accepted as long as they start with the fields of version 00, as the W3C
+// Trace Context specification requires.
+func ParseTraceparent(s string) (SpanContext, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	version, ok := decodeHex(s[0:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc SpanContext
+	traceID, ok1 := decodeHex(s[3:35])
+	spanID, ok2 := decodeHex(s[36:52])
+	flags, ok3 := decodeHex(s[53:55])
+	if !ok1 || !ok2 || !ok3 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes lowercase hex digits; uppercase is invalid in traceparent
+func decodeHex(s string) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// SpanKind describes the relationship of a span to its parent and children
+type SpanKind int
+
+// Span kinds, numbered as in OTLP
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode is the outcome of a span
+type StatusCode int
+
+// Status codes, numbered as in OTLP
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attribute is a key and a string, int64, float64 or bool value
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Int64 returns an integer attribute
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Float64 returns a floating point attribute
+func Float64(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Event is something that happened at a point in time during a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []Attribute
+}
+
+// SpanData is a finished span, as handed to processors and exporters
+type SpanData struct {
+	SpanContext  SpanContext
+	Parent       SpanID
+	Name         string
+	Kind         SpanKind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Events       []Event
+	Status       StatusCode
+	StatusDetail string
+}
+
+// Processor receives sampled spans when they end
+type Processor interface {
+	OnEnd(span SpanData)
+}
+
+// Tracer starts spans and hands the sampled ones to its processor
+type Tracer struct {
+	ratio     float64
+	processor Processor
+}
+
+// NewTracer creates a tracer that samples ratio of new traces (0 to 1) and
+// hands sampled spans to processor. Traces continued from a remote parent
+// follow the parent's sampling decision. A nil processor records nothing.
+func NewTracer(ratio float64, processor Processor) *Tracer {
+	return &Tracer{ratio: max(0, min(1, ratio)), processor: processor}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(1, nil))
+}
+
+// SetTracer replaces the tracer used by Start
+func SetTracer(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// CurrentTracer returns the tracer used by Start
+func CurrentTracer() *Tracer {
+	return defaultTracer.Load()
+}
+
+// StartOption configures a span started by Start
+type StartOption func(*Span)
+
+// WithKind sets the kind of the span; the default is KindInternal
+func WithKind(kind SpanKind) StartOption {
+	return func(s *Span) { s.kind = kind }
+}
+
+// WithAttributes sets attributes on the span
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(s *Span) { s.attrs = append(s.attrs, attrs...) }
+}
+
+// Start starts a span with the default tracer; see Tracer.Start
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	return CurrentTracer().Start(ctx, name, opts...)
+}
+
+// Start starts a span as a child of the span or remote span context in ctx,
+// or as the root of a new trace, and returns a context holding it. End the
+// span when the operation finishes.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	s := &Span{tracer: t, name: name, kind: KindInternal, start: time.Now()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if parent.IsValid() {
+		s.sc = SpanContext{TraceID: parent.TraceID, Flags: parent.Flags, TraceState: parent.TraceState}
+		s.parent = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		if t.sample(s.sc.TraceID) {
+			s.sc.Flags = FlagSampled
+		}
+	}
+	s.sc.SpanID = newSpanID()
+	s.recording = t.processor != nil && s.sc.IsSampled()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// sample decides whether a new trace is recorded, consistently for a trace ID
+func (t *Tracer) sample(id TraceID) bool {
+	switch {
+	case t.ratio >= 1:
+		return true
+	case t.ratio <= 0:
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:]) < uint64(t.ratio*math.MaxUint64)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+// spanKey is the context key of the current span
+type spanKey struct{}
+
+// remoteKey is the context key of a span context received from another process
+type remoteKey struct{}
+
+// SpanFromContext returns the current span, or nil. The methods of a nil
+// span do nothing.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext returns the span context of the current span, or
+// the remote span context the trace was continued from
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext returns a context that continues the trace of
+// sc, received from another process
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Span is an operation within a trace. Its methods are safe for concurrent use
+// and do nothing on a nil span.
+type Span struct {
+	tracer    *Tracer
+	sc        SpanContext
+	parent    SpanID
+	name      string
+	kind      SpanKind
+	start     time.Time
+	recording bool
+
+	mu           sync.Mutex
+	attrs        []Attribute
+	events       []Event
+	status       StatusCode
+	statusDetail string
+	ended        bool
+}
+
+// SpanContext returns the span's propagated context
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// IsRecording reports whether the span will be handed to a processor, so
+// callers can skip computing expensive attributes
+func (s *Span) IsRecording() bool {
+	return s != nil && s.recording
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes sets attributes on the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetStatus sets the outcome of the span; detail describes an error
+func (s *Span) SetStatus(code StatusCode, detail string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status, s.statusDetail = code, detail
+}
+
+// RecordError marks the span as failed with err and adds an exception event.
+// A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+	if !s.recording {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{
+		Name:       "exception",
+		Time:       time.Now(),
+		Attributes: []Attribute{String("exception.type", fmt.Sprintf("%T", err)), String("exception.message", err.Error())},
+	})
+}
+
+// End finishes the span and hands it to the tracer's processor if it is
+// sampled. Calls after the first do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		SpanContext:  s.sc,
+		Parent:       s.parent,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        s.start,
+		End:          end,
+		Attributes:   s.attrs,
+		Events:       s.events,
+		Status:       s.status,
+		StatusDetail: s.statusDetail,
+	}
+	s.mu.Unlock()
+
+	if s.recording {
+		s.tracer.processor.OnEnd(data)
+	}
+}
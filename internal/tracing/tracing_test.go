@@ -0,0 +1,372 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a processor that keeps the spans it is given
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) OnEnd(span SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *recorder) ended() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SpanData(nil), r.spans...)
+}
+
+// useTracer makes t the default tracer for the rest of the test
+func useTracer(tb testing.TB, t *Tracer) {
+	previous := CurrentTracer()
+	SetTracer(t)
+	tb.Cleanup(func() { SetTracer(previous) })
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatalf("ParseTraceparent failed: %v", err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Errorf("Unexpected span context %+v", sc)
+	}
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("Expected %s to round trip, got %s", valid, got)
+	}
+
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil {
+		t.Errorf("Expected a later version with extra fields to parse, got %v", err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(invalid); !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("Expected %q to be rejected, got %v", invalid, err)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	rec := &recorder{}
+	useTracer(t, NewTracer(1, rec))
+
+	ctx, root := Start(context.Background(), "root", WithKind(KindServer))
+	_, child := Start(ctx, "child", WithAttributes(String("key", "value")))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	root.End()
+	root.End()
+
+	spans := rec.ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.SpanContext.TraceID != r.SpanContext.TraceID || c.Parent != r.SpanContext.SpanID || r.Parent.IsValid() {
+		t.Error("Expected the child in the root's trace with the root as parent")
+	}
+	if r.Kind != KindServer || c.Kind != KindInternal {
+		t.Errorf("Unexpected kinds %d and %d", r.Kind, c.Kind)
+	}
+	if c.Status != StatusError || c.StatusDetail != "boom" || len(c.Events) != 1 || c.Events[0].Name != "exception" {
+		t.Errorf("Expected the error recorded on the child, got %+v", c)
+	}
+	if len(c.Attributes) != 1 || c.Attributes[0] != String("key", "value") {
+		t.Errorf("Unexpected attributes %v", c.Attributes)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	rec := &recorder{}
+	useTracer(t, NewTracer(0, rec))
+
+	_, span := Start(context.Background(), "unsampled")
+	if span.IsRecording() || span.SpanContext().IsSampled() || !span.SpanContext().IsValid() {
+		t.Error("Expected an unsampled span that still has IDs")
+	}
+	span.End()
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span = Start(ContextWithRemoteSpanContext(context.Background(), remote), "continued")
+	if !span.IsRecording() {
+		t.Error("Expected a sampled remote parent to be followed")
+	}
+	span.End()
+
+	if spans := rec.ended(); len(spans) != 1 || spans[0].Name != "continued" {
+		t.Errorf("Expected only the continued span recorded, got %v", spans)
+	}
+
+	half := NewTracer(0.5, rec)
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		if _, s := half.Start(context.Background(), "root"); s.SpanContext().IsSampled() {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Errorf("Expected about half the traces sampled, got %d of 1000", sampled)
+	}
+}
+
+func TestNilSpan(t *testing.T) {
+	span := SpanFromContext(context.Background())
+	span.SetName("name")
+	span.SetAttributes(String("key", "value"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	if span.IsRecording() || span.SpanContext().IsValid() {
+		t.Error("Expected a nil span to do nothing")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	useTracer(t, NewTracer(1, nil))
+
+	in := http.Header{}
+	in.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Set(TracestateHeader, "vendor=value")
+	ctx, span := Start(Extract(context.Background(), in), "handler")
+	defer span.End()
+
+	out := http.Header{}
+	Inject(ctx, out)
+	sc, err := ParseTraceparent(out.Get(TraceparentHeader))
+	if err != nil {
+		t.Fatalf("Expected a valid traceparent, got %v", err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID != span.SpanContext().SpanID {
+		t.Errorf("Expected the trace continued with the handler span, got %s", out.Get(TraceparentHeader))
+	}
+	if out.Get(TracestateHeader) != "vendor=value" {
+		t.Errorf("Expected tracestate passed on, got %q", out.Get(TracestateHeader))
+	}
+
+	out = http.Header{}
+	Inject(context.Background(), out)
+	if len(out) != 0 {
+		t.Errorf("Expected no headers without a trace, got %v", out)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	rec := &recorder{}
+	useTracer(t, NewTracer(1, rec))
+
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceparentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, parent := Start(context.Background(), "job")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/upstream?token=secret", nil)
+	resp, err := (&http.Client{Transport: NewTransport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := rec.ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected client and parent spans, got %d", len(spans))
+	}
+	client := spans[0]
+	if client.Kind != KindClient || client.Parent != parent.SpanContext().SpanID || client.Status != StatusError {
+		t.Errorf("Unexpected client span %+v", client)
+	}
+	if received != client.SpanContext.Traceparent() {
+		t.Errorf("Expected the server to receive %s, got %s", client.SpanContext.Traceparent(), received)
+	}
+	for _, a := range client.Attributes {
+		if a.Key == "url.full" && a.Value != srv.URL+"/upstream" {
+			t.Errorf("Expected the query string left out of the URL, got %v", a.Value)
+		}
+	}
+	if req.Header.Get(TraceparentHeader) != "" {
+		t.Error("Expected the caller's request left unmodified")
+	}
+}
+
+// collector is a stand-in for an OTLP/HTTP collector
+type collector struct {
+	mu       sync.Mutex
+	requests []map[string]interface{}
+	headers  []http.Header
+	status   int
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	var req map[string]interface{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func TestOTLPExport(t *testing.T) {
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	exporter := NewOTLPExporter(srv.URL+"/v1/traces", "test-service", map[string]string{"Authorization": "Bearer abc"})
+	processor := NewBatchProcessor(exporter, time.Hour, 2, 10, time.Second)
+	useTracer(t, NewTracer(1, processor))
+
+	ctx, root := Start(context.Background(), "GET /things/{id}", WithKind(KindServer), WithAttributes(Int("http.response.status_code", 200), Bool("cached", true), Float64("ratio", 0.5)))
+	_, child := Start(ctx, "SELECT", WithKind(KindClient))
+	child.RecordError(errors.New("no such table"))
+	child.End()
+	root.End()
+	_, other := Start(context.Background(), "other")
+	other.End()
+
+	if n := processor.ExportQueued(context.Background()); n != 3 {
+		t.Fatalf("Expected 3 spans exported, got %d", n)
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if len(col.requests) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(col.requests))
+	}
+	if col.headers[0].Get("Authorization") != "Bearer abc" {
+		t.Error("Expected the configured headers sent")
+	}
+
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpKeyValue `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	raw, _ := json.Marshal(col.requests[0])
+	if err := json.Unmarshal(raw, &req); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	resource := req.ResourceSpans[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || *resource[0].Value.StringValue != "test-service" {
+		t.Errorf("Unexpected resource %+v", resource)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans in the first batch, got %d", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if r.TraceID != root.SpanContext().TraceID.String() || r.SpanID != root.SpanContext().SpanID.String() || r.ParentSpanID != "" {
+		t.Errorf("Unexpected root IDs %+v", r)
+	}
+	if c.ParentSpanID != r.SpanID || c.Kind != KindClient || c.Status.Code != StatusError || c.Status.Message != "no such table" {
+		t.Errorf("Unexpected child %+v", c)
+	}
+	if r.StartTimeUnixNano == "" || r.EndTimeUnixNano < r.StartTimeUnixNano {
+		t.Errorf("Unexpected timestamps %s to %s", r.StartTimeUnixNano, r.EndTimeUnixNano)
+	}
+	if len(r.Attributes) != 3 || *r.Attributes[0].Value.IntValue != "200" || !*r.Attributes[1].Value.BoolValue || *r.Attributes[2].Value.DoubleValue != 0.5 {
+		t.Errorf("Unexpected attributes %+v", r.Attributes)
+	}
+
+}
+
+func TestOTLPExportError(t *testing.T) {
+	srv := httptest.NewServer(&collector{status: http.StatusServiceUnavailable})
+	defer srv.Close()
+
+	err := NewOTLPExporter(srv.URL+"/v1/traces", "test", nil).Export(context.Background(), []SpanData{{Name: "lost"}})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the collector's status in the error, got %v", err)
+	}
+}
+
+func TestBatchProcessor(t *testing.T) {
+	col := &collector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	processor := NewBatchProcessor(NewOTLPExporter(srv.URL+"/v1/traces", "test", nil), time.Hour, 2, 3, time.Second)
+	useTracer(t, NewTracer(1, processor))
+
+	for i := 0; i < 5; i++ {
+		_, span := Start(context.Background(), "queued")
+		span.End()
+	}
+	if processor.Dropped() != 2 {
+		t.Errorf("Expected 2 spans dropped by the full queue, got %d", processor.Dropped())
+	}
+
+	processor.Start(context.Background())
+	processor.Stop()
+	processor.Stop()
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	exported := 0
+	for _, req := range col.requests {
+		raw, _ := json.Marshal(req)
+		var decoded otlpRequest
+		json.Unmarshal(raw, &decoded)
+		exported += len(decoded.ResourceSpans[0].ScopeSpans[0].Spans)
+	}
+	if exported != 3 {
+		t.Errorf("Expected the queued spans exported on stop, got %d", exported)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders("Authorization=Bearer%20abc, X-Tenant = acme,")
+	if err != nil {
+		t.Fatalf("ParseHeaders failed: %v", err)
+	}
+	if headers["Authorization"] != "Bearer abc" || headers["X-Tenant"] != "acme" || len(headers) != 2 {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+	if _, err := ParseHeaders("novalue"); err == nil {
+		t.Error("Expected an error for a header without a value")
+	}
+}
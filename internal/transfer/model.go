@@ -0,0 +1,187 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+)
+
+// errDryRun rolls back the transaction of a dry-run batch
+var errDryRun = errors.New("dry run")
+
+// modelEntity exports and imports model T through its repository
+type modelEntity[T models.Model] struct {
+	validate func(*T) error
+}
+
+// record is a decoded row and the line it came from
+type record[T models.Model] struct {
+	line  int
+	model T
+}
+
+// export pages through the table by primary key, so every query is cheap no
+// matter how far into the table it is
+func (e *modelEntity[T]) export(ctx context.Context, db *database.Database, w io.Writer, batchSize int, progress func(int)) (int, error) {
+	repo := models.NewRepository[T](db).Unscoped()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	exported := 0
+	var after interface{}
+	for {
+		query := repo.Query().OrderBy(repo.PrimaryKey()).Limit(batchSize)
+		if after != nil {
+			query.WhereExpr(database.Gt(repo.PrimaryKey(), after))
+		}
+		rows, err := repo.List(ctx, query)
+		if err != nil {
+			return exported, err
+		}
+		for i := range rows {
+			if err := enc.Encode(&rows[i]); err != nil {
+				return exported, fmt.Errorf("failed to write %s: %w", repo.Table(), err)
+			}
+		}
+
+		exported += len(rows)
+		if progress != nil && len(rows) > 0 {
+			progress(exported)
+		}
+		if len(rows) < batchSize {
+			return exported, nil
+		}
+		after = repo.ID(&rows[len(rows)-1])
+	}
+}
+
+// load decodes, validates and writes the rows read from r
+func (e *modelEntity[T]) load(ctx context.Context, db *database.Database, r io.Reader, opts Options) (*Stats, error) {
+	repo := models.NewRepository[T](db)
+	stats := &Stats{DryRun: opts.DryRun}
+	batch := make([]record[T], 0, opts.BatchSize)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		stats.Lines++
+
+		rec := record[T]{line: line}
+		if err := e.decode(data, &rec.model); err != nil {
+			if !opts.DryRun {
+				return stats, &LineError{Line: line, Err: err}
+			}
+			stats.addInvalid(line, err)
+			continue
+		}
+
+		batch = append(batch, rec)
+		if len(batch) >= opts.BatchSize {
+			if err := e.write(ctx, db, repo, batch, stats, opts); err != nil {
+				return stats, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, &LineError{Line: line + 1, Err: fmt.Errorf("failed to read input: %w", err)}
+	}
+	if len(batch) > 0 {
+		if err := e.write(ctx, db, repo, batch, stats, opts); err != nil {
+			return stats, err
+		}
+	}
+
+	// Imported rows carry their keys, so move the key sequence past them
+	if !opts.DryRun && stats.Written > 0 && repo.AutoKey() {
+		if err := database.ResetSequence(ctx, db, repo.Table(), repo.PrimaryKey()); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// decode parses one line into model and validates it
+func (e *modelEntity[T]) decode(data []byte, model *T) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(model); err != nil {
+		return fmt.Errorf("%w: invalid JSON: %v", database.ErrInvalid, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w: invalid JSON: more than one value on the line", database.ErrInvalid)
+	}
+	if e.validate != nil {
+		if err := e.validate(model); err != nil {
+			return fmt.Errorf("%w: %w", database.ErrInvalid, err)
+		}
+	}
+	return nil
+}
+
+// write stores a batch in one transaction. In a dry run every row is written
+// in its own savepoint, so a failing row is reported without aborting the
+// others, and the transaction is rolled back.
+func (e *modelEntity[T]) write(ctx context.Context, db *database.Database, repo *models.Repository[T], batch []record[T], stats *Stats, opts Options) error {
+	put := func(tx *database.Tx, model *T) error {
+		if opts.Mode == ModeUpsert {
+			return repo.WithTx(tx).Upsert(ctx, model)
+		}
+		return repo.WithTx(tx).Insert(ctx, model)
+	}
+
+	var written int
+	var failed []LineError
+	err := db.WithTx(ctx, nil, func(tx *database.Tx) error {
+		// The transaction may be retried, so start the counts afresh
+		written, failed = 0, nil
+		for i := range batch {
+			rec := &batch[i]
+			if !opts.DryRun {
+				if err := put(tx, &rec.model); err != nil {
+					return &LineError{Line: rec.line, Err: err}
+				}
+				written++
+				continue
+			}
+
+			err := tx.WithTx(ctx, func(sp *database.Tx) error {
+				return put(sp, &rec.model)
+			})
+			if err != nil {
+				failed = append(failed, LineError{Line: rec.line, Err: err})
+				continue
+			}
+			written++
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+
+	stats.Written += written
+	for _, f := range failed {
+		stats.addInvalid(f.Line, f.Err)
+	}
+	if opts.Progress != nil {
+		opts.Progress(*stats)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package transfer exports and imports the rows of registered models as JSON
+// Lines: one JSON object per line, in the model's JSON encoding. Exports
+// stream every row, soft-deleted ones included, in primary key order; imports
+// write the rows back exactly as exported, keeping their keys, timestamps and
+// versions, so data can be moved between environments and database drivers.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/validation"
+)
+
+const (
+	// DefaultBatchSize is the number of rows read or written per query or
+	// transaction when no batch size is given
+	DefaultBatchSize = 500
+	// maxLineSize caps the length of an imported line
+	maxLineSize = 1 << 20
+	// maxRecordErrors caps the number of problems listed in Stats
+	maxRecordErrors = 100
+)
+
+// ErrUnknownModel is returned for a model name that is not registered
+var ErrUnknownModel = errors.New("unknown model")
+
+// Mode selects how imported rows are written
+type Mode string
+
+const (
+	// ModeInsert inserts every row; rows whose key already exists fail
+	ModeInsert Mode = "insert"
+	// ModeUpsert inserts new rows and overwrites existing ones
+	ModeUpsert Mode = "upsert"
+)
+
+// Options controls an import
+type Options struct {
+	Mode Mode
+	// BatchSize is the number of rows written per transaction
+	BatchSize int
+	// DryRun decodes and validates every record and writes each batch in a
+	// transaction that is rolled back, reporting every problem instead of
+	// stopping at the first
+	DryRun bool
+	// Progress, if not nil, is called after each batch
+	Progress func(Stats)
+}
+
+// Stats counts what an import did
+type Stats struct {
+	// Lines is the number of non-empty lines read
+	Lines int `json:"lines"`
+	// Written is the number of rows written, or that would have been in a
+	// dry run
+	Written int `json:"written"`
+	// Invalid is the number of records that failed to decode, validate or
+	// write in a dry run
+	Invalid int `json:"invalid"`
+	// Errors lists the first problems found
+	Errors []RecordError `json:"errors,omitempty"`
+	DryRun bool          `json:"dry_run"`
+}
+
+// RecordError describes a problem with one imported record
+type RecordError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// addInvalid counts an invalid record and lists its problems
+func (s *Stats) addInvalid(line int, err error) {
+	s.Invalid++
+	var errs validation.ValidationErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			s.addError(RecordError{Line: line, Field: e.Field, Message: e.Message})
+		}
+		return
+	}
+	s.addError(RecordError{Line: line, Message: err.Error()})
+}
+
+// addError lists a problem unless the list is full
+func (s *Stats) addError(e RecordError) {
+	if len(s.Errors) < maxRecordErrors {
+		s.Errors = append(s.Errors, e)
+	}
+}
+
+// LineError reports the imported line a record failed on
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// entity exports and imports the rows of one registered model
+type entity interface {
+	export(ctx context.Context, db *database.Database, w io.Writer, batchSize int, progress func(int)) (int, error)
+	load(ctx context.Context, db *database.Database, r io.Reader, opts Options) (*Stats, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]entity{}
+)
+
+// Register makes model T available for export and import under name.
+// validate, if not nil, checks every imported record before it is written;
+// return validation.ValidationErrors to report the failing fields. It panics
+// if the name is already registered, like sql.Register.
+func Register[T models.Model](name string, validate func(*T) error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("transfer: Register called twice for model " + name)
+	}
+	registry[name] = &modelEntity[T]{validate: validate}
+}
+
+// Models returns the names of the registered models, sorted
+func Models() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registered reports whether a model is registered under name
+func Registered(name string) bool {
+	_, err := lookup(name)
+	return err == nil
+}
+
+// lookup returns the model registered under name
+func lookup(name string) (entity, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownModel, name)
+	}
+	return e, nil
+}
+
+// Export writes every row of the named model to w as JSON Lines, reading
+// batchSize rows per query, and returns the number of rows written. progress,
+// if not nil, is called with the running total after each batch. Rows changed
+// during the export may or may not be included; it is not a point-in-time
+// snapshot.
+func Export(ctx context.Context, db *database.Database, name string, w io.Writer, batchSize int, progress func(int)) (int, error) {
+	e, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return e.export(ctx, db, w, batchSize, progress)
+}
+
+// Import reads JSON Lines rows of the named model from r and writes them in
+// batches, each in its own transaction. Outside a dry run it stops at the
+// first record that fails, with a *LineError; batches before it stay
+// committed, so fix the input and import again in upsert mode. Imports write
+// rows directly and enqueue no outbox events.
+func Import(ctx context.Context, db *database.Database, name string, r io.Reader, opts Options) (*Stats, error) {
+	e, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeInsert
+	case ModeInsert, ModeUpsert:
+	default:
+		return nil, fmt.Errorf("%w: mode must be insert or upsert, got %q", database.ErrInvalid, opts.Mode)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return e.load(ctx, db, r, opts)
+}
@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tediscript/gostarterkit/internal/database"
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/seed/seedtest"
+)
+
+// setupTestDB returns a migrated database loaded with the given seed sets
+func setupTestDB(t *testing.T, sets ...string) *database.Database {
+	t.Helper()
+	return seedtest.NewDatabase(t, "../../migrations", "../../seeds", sets...)
+}
+
+// listUsers returns every user, soft-deleted ones included, by id
+func listUsers(t *testing.T, db *database.Database) []models.User {
+	t.Helper()
+	repo := models.NewRepository[models.User](db).Unscoped()
+	users, err := repo.List(context.Background(), repo.Query().OrderBy("id"))
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	return users
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := setupTestDB(t, "dev")
+	users := models.NewUserRepository(source)
+	carol := &models.User{Username: "carol", Email: "carol@example.com"}
+	if err := users.Create(ctx, carol); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := users.Delete(ctx, carol.ID); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+
+	var out bytes.Buffer
+	var batches []int
+	n, err := Export(ctx, source, "users", &out, 2, func(n int) { batches = append(batches, n) })
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if n != 3 || len(lines) != 3 || len(batches) != 2 || batches[1] != 3 {
+		t.Fatalf("Expected 3 rows in 2 batches, got %d rows, %d lines, batches %v", n, len(lines), batches)
+	}
+	if !strings.Contains(lines[2], `"username":"carol"`) || !strings.Contains(lines[2], `"deleted_at"`) {
+		t.Errorf("Expected the soft-deleted user to be exported, got %s", lines[2])
+	}
+	export := out.String()
+
+	target := setupTestDB(t)
+
+	t.Run("inserts rows as exported", func(t *testing.T) {
+		var progress []Stats
+		stats, err := Import(ctx, target, "users", strings.NewReader(export), Options{BatchSize: 2, Progress: func(s Stats) { progress = append(progress, s) }})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if stats.Lines != 3 || stats.Written != 3 || stats.Invalid != 0 || len(progress) != 2 {
+			t.Errorf("Unexpected stats %+v after %d batches", stats, len(progress))
+		}
+
+		want, got := listUsers(t, source), listUsers(t, target)
+		if len(got) != len(want) {
+			t.Fatalf("Expected %d users, got %d", len(want), len(got))
+		}
+		for i := range want {
+			w, g := want[i], got[i]
+			if g.ID != w.ID || g.Username != w.Username || g.Version != w.Version || g.CreatedBy != w.CreatedBy ||
+				!g.CreatedAt.Equal(w.CreatedAt) || (g.DeletedAt == nil) != (w.DeletedAt == nil) {
+				t.Errorf("Expected %+v, got %+v", w, g)
+			}
+		}
+
+		// New users must not reuse imported keys
+		dave := &models.User{Username: "dave", Email: "dave@example.com"}
+		if err := models.NewUserRepository(target).Create(ctx, dave); err != nil {
+			t.Fatalf("Failed to create user after import: %v", err)
+		}
+		if dave.ID <= carol.ID {
+			t.Errorf("Expected a new id after %d, got %d", carol.ID, dave.ID)
+		}
+	})
+
+	t.Run("insert fails on existing rows", func(t *testing.T) {
+		_, err := Import(ctx, target, "users", strings.NewReader(export), Options{Mode: ModeInsert})
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) || lineErr.Line != 1 || !errors.Is(err, database.ErrConflict) {
+			t.Errorf("Expected a conflict on line 1, got %v", err)
+		}
+	})
+
+	t.Run("upsert overwrites existing rows", func(t *testing.T) {
+		edited := strings.Replace(export, `"name":"Alice Example"`, `"name":"Alice Edited"`, 1)
+		stats, err := Import(ctx, target, "users", strings.NewReader(edited), Options{Mode: ModeUpsert})
+		if err != nil || stats.Written != 3 {
+			t.Fatalf("Upsert failed: %v (%+v)", err, stats)
+		}
+		if got := listUsers(t, target); got[0].Name != "Alice Edited" {
+			t.Errorf("Expected the name to be overwritten, got %q", got[0].Name)
+		}
+	})
+}
+
+func TestImportValidation(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t, "dev")
+
+	input := strings.Join([]string{
+		`{"id": 10, "username": "erin", "email": "erin@example.com"}`,
+		`not json`,
+		``,
+		`{"id": 11, "username": "frank", "email": "frank@example.com", "role": "admin"}`,
+		`{"id": 12, "username": "x", "email": "nope"}`,
+		`{"id": 13, "username": "gina", "email": "alice@example.com"}`,
+		`{"id": 14, "username": "hal", "email": "hal@example.com"}`,
+	}, "\n")
+
+	t.Run("dry run reports every problem and writes nothing", func(t *testing.T) {
+		stats, err := Import(ctx, db, "users", strings.NewReader(input), Options{DryRun: true, BatchSize: 2})
+		if err != nil {
+			t.Fatalf("Dry run failed: %v", err)
+		}
+		if !stats.DryRun || stats.Lines != 6 || stats.Written != 2 || stats.Invalid != 4 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+
+		fields := map[int][]string{}
+		for _, e := range stats.Errors {
+			fields[e.Line] = append(fields[e.Line], e.Field)
+		}
+		if len(fields[2]) != 1 || len(fields[4]) != 1 || len(fields[6]) != 1 {
+			t.Errorf("Expected errors on lines 2, 4 and 6, got %+v", stats.Errors)
+		}
+		if strings.Join(fields[5], ",") != "username,email" {
+			t.Errorf("Expected field errors on line 5, got %+v", stats.Errors)
+		}
+
+		if got := listUsers(t, db); len(got) != 2 {
+			t.Errorf("Expected the dry run to write nothing, got %d users", len(got))
+		}
+	})
+
+	t.Run("stops at the first invalid record", func(t *testing.T) {
+		stats, err := Import(ctx, db, "users", strings.NewReader(input), Options{BatchSize: 1})
+		var lineErr *LineError
+		if !errors.As(err, &lineErr) || lineErr.Line != 2 || !errors.Is(err, database.ErrInvalid) {
+			t.Fatalf("Expected line 2 to be invalid, got %v", err)
+		}
+		if stats.Written != 1 {
+			t.Errorf("Expected the batch before the error to be written, got %+v", stats)
+		}
+		if got := listUsers(t, db); len(got) != 3 || got[2].Username != "erin" {
+			t.Errorf("Expected erin to be imported, got %+v", got)
+		}
+	})
+
+	t.Run("rejects unknown models and modes", func(t *testing.T) {
+		if _, err := Import(ctx, db, "widgets", strings.NewReader(""), Options{}); !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("Expected ErrUnknownModel, got %v", err)
+		}
+		if _, err := Export(ctx, db, "widgets", &bytes.Buffer{}, 0, nil); !errors.Is(err, ErrUnknownModel) {
+			t.Errorf("Expected ErrUnknownModel, got %v", err)
+		}
+		if _, err := Import(ctx, db, "users", strings.NewReader(""), Options{Mode: "merge"}); !errors.Is(err, database.ErrInvalid) {
+			t.Errorf("Expected ErrInvalid, got %v", err)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	if !Registered("users") || strings.Join(Models(), ",") != "users" {
+		t.Errorf("Expected only users to be registered, got %v", Models())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a duplicate registration to panic")
+		}
+	}()
+	Register[models.User]("users", nil)
+}
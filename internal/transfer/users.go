@@ -0,0 +1,16 @@
+package transfer
+
+import (
+	"github.com/tediscript/gostarterkit/internal/models"
+	"github.com/tediscript/gostarterkit/internal/validation"
+)
+
+func init() {
+	Register("users", validateUser)
+}
+
+// validateUser applies the rules users are created with through the API
+func validateUser(u *models.User) error {
+	req := validation.CreateUserRequest{Username: u.Username, Email: u.Email, Name: u.Name}
+	return req.Validate()
+}
@@ -207,3 +207,46 @@ func (r *UpdateProfileRequest) Validate() error {
 	}
 	return nil
 }
+
+// CreateUserRequest is the request struct for creating a user
+type CreateUserRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email"`
+	Name     string `json:"name,omitempty" validate:"omitempty,min=2,max=50"`
+}
+
+// usernamePattern allows letters, digits, dots, underscores and hyphens
+const usernamePattern = `^[a-zA-Z0-9._-]+$`
+
+// Validate implements the Validator interface for CreateUserRequest
+func (r *CreateUserRequest) Validate() error {
+	var errors ValidationErrors
+
+	// Validate Username
+	if err := Required(r.Username); err != nil {
+		errors = append(errors, ValidationError{Field: "username", Message: err.Error()})
+	} else if err := Length(3, 50)(r.Username); err != nil {
+		errors = append(errors, ValidationError{Field: "username", Message: err.Error()})
+	} else if err := Pattern(usernamePattern)(r.Username); err != nil {
+		errors = append(errors, ValidationError{Field: "username", Message: "may only contain letters, digits, '.', '_' and '-'"})
+	}
+
+	// Validate Email
+	if err := Required(r.Email); err != nil {
+		errors = append(errors, ValidationError{Field: "email", Message: err.Error()})
+	} else if err := Email(r.Email); err != nil {
+		errors = append(errors, ValidationError{Field: "email", Message: err.Error()})
+	}
+
+	// Validate Name (optional)
+	if r.Name != "" {
+		if err := Length(2, 50)(r.Name); err != nil {
+			errors = append(errors, ValidationError{Field: "name", Message: err.Error()})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
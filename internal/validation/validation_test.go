@@ -693,3 +693,42 @@ func TestNilValueValidation(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateUserRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    CreateUserRequest
+		wantFields []string
+	}{
+		{"valid", CreateUserRequest{Username: "john.doe", Email: "john@example.com", Name: "John Doe"}, nil},
+		{"valid without name", CreateUserRequest{Username: "john_doe-2", Email: "john@example.com"}, nil},
+		{"missing fields", CreateUserRequest{}, []string{"username", "email"}},
+		{"username too short", CreateUserRequest{Username: "jo", Email: "john@example.com"}, []string{"username"}},
+		{"username with spaces", CreateUserRequest{Username: "john doe", Email: "john@example.com"}, []string{"username"}},
+		{"invalid email and name", CreateUserRequest{Username: "john", Email: "invalid", Name: "J"}, []string{"email", "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate()
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Errorf("CreateUserRequest.Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			errs, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("CreateUserRequest.Validate() error = %v, want ValidationErrors", err)
+			}
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("CreateUserRequest.Validate() fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+-- Drop the users full-text document
+DROP INDEX IF EXISTS idx_users_search;
+ALTER TABLE users DROP COLUMN search;
+
+-- Drop the display name
+ALTER TABLE users DROP COLUMN name;
@@ -0,0 +1,14 @@
+-- Add a display name to users
+ALTER TABLE users ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+-- Full-text document over users, weighted username (A), name (B) and email
+-- (C). Punctuation is turned into spaces first so that, like SQLite's
+-- unicode61 tokenizer, "ada@example.com" is indexed as three words.
+ALTER TABLE users ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', regexp_replace(username, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', regexp_replace(name, '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+    setweight(to_tsvector('simple', regexp_replace(email, '[^[:alnum:]]+', ' ', 'g')), 'C')
+) STORED;
+
+-- Create index for full-text matches
+CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN (search);
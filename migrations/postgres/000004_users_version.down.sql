@@ -0,0 +1,2 @@
+-- Drop the optimistic locking version from users
+ALTER TABLE users DROP COLUMN version;
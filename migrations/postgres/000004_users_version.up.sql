@@ -0,0 +1,2 @@
+-- Add an optimistic locking version to users
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,16 @@
+-- Domain events written in the same transaction as the changes they describe
+-- and published by the outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    topic TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    delivered_at TIMESTAMPTZ
+);
+
+-- Create index for the relay's scan of due events
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next_attempt_at ON outbox(status, next_attempt_at);
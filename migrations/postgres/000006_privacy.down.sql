@@ -0,0 +1,6 @@
+-- Drop the privacy tables
+DROP INDEX IF EXISTS idx_privacy_audit_user_id;
+DROP TABLE IF EXISTS privacy_audit;
+DROP INDEX IF EXISTS idx_erasure_requests_pending_user_id;
+DROP INDEX IF EXISTS idx_erasure_requests_status_scheduled_at;
+DROP TABLE IF EXISTS erasure_requests;
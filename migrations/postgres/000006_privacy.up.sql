@@ -0,0 +1,32 @@
+-- Account erasure requests, carried out once their grace period has passed
+CREATE TABLE IF NOT EXISTS erasure_requests (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    requested_by TEXT NOT NULL DEFAULT '',
+    requested_at TIMESTAMPTZ NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    cancelled_by TEXT NOT NULL DEFAULT '',
+    cancelled_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+-- Create index for the eraser's scan of due requests
+CREATE INDEX IF NOT EXISTS idx_erasure_requests_status_scheduled_at ON erasure_requests(status, scheduled_at);
+
+-- Allow a single pending request per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_erasure_requests_pending_user_id ON erasure_requests(user_id) WHERE status = 'pending';
+
+-- Audit trail of personal data exports and erasures. Users are referred to by
+-- id only, so the trail survives the erasure it records.
+CREATE TABLE IF NOT EXISTS privacy_audit (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Create index for listing a user's audit trail
+CREATE INDEX IF NOT EXISTS idx_privacy_audit_user_id ON privacy_audit(user_id);
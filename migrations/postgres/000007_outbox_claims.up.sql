@@ -0,0 +1,3 @@
+-- Let relays claim due events so that each one is delivered by one relay
+ALTER TABLE outbox ADD COLUMN locked_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbox ADD COLUMN locked_until TIMESTAMPTZ;
@@ -0,0 +1,8 @@
+-- Drop the users full-text index
+DROP TRIGGER IF EXISTS users_fts_update;
+DROP TRIGGER IF EXISTS users_fts_delete;
+DROP TRIGGER IF EXISTS users_fts_insert;
+DROP TABLE IF EXISTS users_fts;
+
+-- Drop the display name
+ALTER TABLE users DROP COLUMN name;
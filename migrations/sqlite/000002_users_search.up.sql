@@ -0,0 +1,36 @@
+-- Add a display name to users
+ALTER TABLE users ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+-- Full-text index over users, stored as an external-content table so the
+-- text lives only in users
+CREATE VIRTUAL TABLE IF NOT EXISTS users_fts USING fts5(
+    username,
+    email,
+    name,
+    content='users',
+    content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2',
+    prefix='2 3'
+);
+
+-- Keep the index in sync with users; updates reindex a row only when they
+-- set one of its indexed columns
+CREATE TRIGGER IF NOT EXISTS users_fts_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_fts(rowid, username, email, name)
+    VALUES (new.id, new.username, new.email, new.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_fts_delete AFTER DELETE ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email, name)
+    VALUES ('delete', old.id, old.username, old.email, old.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS users_fts_update AFTER UPDATE OF username, email, name ON users BEGIN
+    INSERT INTO users_fts(users_fts, rowid, username, email, name)
+    VALUES ('delete', old.id, old.username, old.email, old.name);
+    INSERT INTO users_fts(rowid, username, email, name)
+    VALUES (new.id, new.username, new.email, new.name);
+END;
+
+-- Index existing users
+INSERT INTO users_fts(users_fts) VALUES ('rebuild');
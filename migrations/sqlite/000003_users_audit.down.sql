@@ -0,0 +1,5 @@
+-- Drop soft delete and audit columns from users
+DROP INDEX IF EXISTS idx_users_deleted_at;
+ALTER TABLE users DROP COLUMN updated_by;
+ALTER TABLE users DROP COLUMN created_by;
+ALTER TABLE users DROP COLUMN deleted_at;
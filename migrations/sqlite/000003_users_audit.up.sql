@@ -0,0 +1,7 @@
+-- Soft delete and audit columns for users
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+ALTER TABLE users ADD COLUMN created_by TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN updated_by TEXT NOT NULL DEFAULT '';
+
+-- Create index on deleted_at for scoping out soft-deleted users
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at);
@@ -0,0 +1,3 @@
+-- Drop the outbox
+DROP INDEX IF EXISTS idx_outbox_status_next_attempt_at;
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,3 @@
+-- Drop the relay claims from the outbox
+ALTER TABLE outbox DROP COLUMN locked_until;
+ALTER TABLE outbox DROP COLUMN locked_by;